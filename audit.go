@@ -0,0 +1,65 @@
+package lil
+
+import (
+	"context"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditShortUpdate  = "short.update"
+	AuditShortDisable = "short.disable"
+	AuditShortFlag    = "short.flag"
+	AuditShortEnable  = "short.enable"
+	AuditShortDelete  = "short.delete"
+
+	AuditUserUpdate      = "user.update"
+	AuditUserSuspend     = "user.suspend"
+	AuditUserUnsuspend   = "user.unsuspend"
+	AuditUserRotateKey   = "user.rotate-key"
+	AuditUserGrantAdmin  = "user.grant-admin"
+	AuditUserRevokeAdmin = "user.revoke-admin"
+	AuditUserDelete      = "user.delete"
+
+	AuditTokenRevoke        = "token.revoke"
+	AuditSessionRevoke      = "session.revoke"
+	AuditUserSessionsRevoke = "user.revoke-sessions"
+)
+
+// AuditEntry represents an action performed with administrator privileges.
+// Entries are recorded by the services and cannot be changed afterwards.
+type AuditEntry struct {
+	ID int `json:"id"`
+
+	// User who performed the action. Zero if the action was performed by a
+	// maintenance tool or if the user has since been deleted.
+	ActorID int   `json:"actorID,omitempty"`
+	Actor   *User `json:"actor,omitempty"`
+
+	// Action performed, such as AuditShortDisable, and the key or ID of the
+	// object it was performed on.
+	Action string `json:"action"`
+	Target string `json:"target"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditService represents a service for reading the audit log.
+type AuditService interface {
+	// Retrieves a list of entries by filter, newest first. Also returns the
+	// total count of matching entries which may differ from returned results
+	// if filter.Limit is specified. Returns EUNAUTHORIZED unless the caller
+	// is an administrator.
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, int, error)
+}
+
+// AuditFilter represents a filter passed to FindAuditEntries().
+type AuditFilter struct {
+	ActorID *int    `json:"actorID"`
+	Action  *string `json:"action"`
+	Target  *string `json:"target"`
+
+	// Restrict to subset of results.
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
@@ -14,6 +14,7 @@ import (
 const (
 	AuthSourceGitHub = "github"
 	AuthSourceGoogle = "google"
+	AuthSourceOIDC   = "oidc"
 )
 
 // Auth represents a set of OAuth credentials. These are linked to a User so a
@@ -32,7 +33,7 @@ type Auth struct {
 	User   *User `json:"user"`
 
 	// The authentication source & the source provider's user ID.
-	// Source can only be "github" currently.
+	// Source is one of the AuthSource constants.
 	Source   string `json:"source"`
 	SourceID string `json:"sourceID"`
 
@@ -42,6 +43,9 @@ type Auth struct {
 	RefreshToken string     `json:"-"`
 	Expiry       *time.Time `json:"-"`
 
+	// Avatar URL reported by the provider, if any.
+	Picture string `json:"picture"`
+
 	// Timestamps of creation & last update.
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
@@ -69,6 +73,10 @@ func (a *Auth) AvatarURL(size int) string {
 	case AuthSourceGitHub:
 		return fmt.Sprintf("https://avatars1.githubusercontent.com/u/%s?s=%d", a.SourceID, size)
 	case AuthSourceGoogle:
+		if a.Picture != "" {
+			return a.Picture
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 
@@ -84,6 +92,8 @@ func (a *Auth) AvatarURL(size int) string {
 			return ""
 		}
 		return c.Picture
+	case AuthSourceOIDC:
+		return a.Picture
 	default:
 		return ""
 	}
@@ -108,7 +118,8 @@ type AuthService interface {
 	CreateAuth(ctx context.Context, auth *Auth) error
 
 	// Permanently deletes an authentication object from the system by ID.
-	// The parent user object is not removed.
+	// The parent user object is not removed. Returns ECONFLICT if it is the
+	// last authentication object of the user.
 	DeleteAuth(ctx context.Context, id int) error
 }
 
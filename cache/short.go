@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Ensure service implements interface.
+var _ lil.ShortService = (*ShortService)(nil)
+
+// Default settings for the short cache.
+const (
+	DefaultShortCacheSize        = 10000
+	DefaultShortCacheTTL         = time.Minute
+	DefaultShortCacheNegativeTTL = 10 * time.Second
+)
+
+// Metrics of the short cache.
+var (
+	shortCacheHitCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lil_short_cache_hits_total",
+		Help: "Total number of short lookups served from the cache.",
+	})
+
+	shortCacheMissCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lil_short_cache_misses_total",
+		Help: "Total number of short lookups forwarded to the database.",
+	})
+)
+
+// ShortService wraps a ShortService to cache the results of SearchShort(),
+// which is called on every redirect.
+//
+// The cache is a bounded LRU. Shorts are cached for TTL and unknown keys for
+// NegativeTTL. Entries are invalidated when the short is changed through the
+// service. Other changes are only seen once the entry expires, such as those
+// made by another process sharing the database or by deleting users.
+type ShortService struct {
+	lil.ShortService
+
+	mu    sync.Mutex
+	ll    *list.List               // most recently used first
+	items map[string]*list.Element // by key
+	gen   uint64                   // incremented on every invalidation
+
+	// Maximum number of cached keys.
+	Size int
+
+	// Duration for which shorts & unknown keys are cached.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	// Returns the current time. Defaults to time.Now().
+	// Can be mocked for tests.
+	Now func() time.Time
+}
+
+// shortEntry represents a cached result of SearchShort().
+type shortEntry struct {
+	key       string
+	short     *lil.Short // nil if the short does not exist
+	expiresAt time.Time
+}
+
+// NewShortService returns a new instance of ShortService wrapping s.
+func NewShortService(s lil.ShortService) *ShortService {
+	return &ShortService{
+		ShortService: s,
+		ll:           list.New(),
+		items:        make(map[string]*list.Element),
+		Size:         DefaultShortCacheSize,
+		TTL:          DefaultShortCacheTTL,
+		NegativeTTL:  DefaultShortCacheNegativeTTL,
+		Now:          time.Now,
+	}
+}
+
+// SearchShort retrieves a single Short by Key from the cache, or from the
+// underlying service on a miss. Returns ENOTFOUND if the Short does not exist.
+// Returns ENOTACTIVE or EEXPIRED if the Short cannot be followed.
+func (s *ShortService) SearchShort(ctx context.Context, key string) (*lil.Short, error) {
+	now := s.Now()
+
+	s.mu.Lock()
+	ent, ok := s.get(key, now)
+	gen := s.gen
+	s.mu.Unlock()
+
+	if ok {
+		shortCacheHitCount.Inc()
+		if ent.short == nil {
+			return nil, lil.Errorf(lil.ENOTFOUND, "Short not found.")
+		} else if err := ent.short.CheckActive(now); err != nil {
+			return nil, err
+		}
+		other := *ent.short
+		return &other, nil
+	}
+	shortCacheMissCount.Inc()
+
+	// Only existing shorts which can be followed & unknown keys are cached.
+	// Other errors are returned without being cached.
+	short, err := s.ShortService.SearchShort(ctx, key)
+	if err != nil && lil.ErrorCode(err) != lil.ENOTFOUND {
+		return nil, err
+	}
+
+	ent = &shortEntry{key: key, expiresAt: now.Add(s.NegativeTTL)}
+	if short != nil {
+		other := *short
+		ent.short, ent.expiresAt = &other, now.Add(s.TTL)
+	}
+
+	// Skip the result if the short has been changed since the lookup began
+	// as the result may be stale.
+	s.mu.Lock()
+	if s.gen == gen {
+		s.add(ent)
+	}
+	s.mu.Unlock()
+
+	return short, err
+}
+
+// CreateShort creates a new Short and invalidates its key.
+func (s *ShortService) CreateShort(ctx context.Context, short *lil.Short) error {
+	err := s.ShortService.CreateShort(ctx, short)
+	s.Invalidate(short.Key)
+	return err
+}
+
+// UpdateShort updates an existing Short and invalidates its key.
+func (s *ShortService) UpdateShort(ctx context.Context, key string, upd lil.ShortUpdate) (*lil.Short, error) {
+	short, err := s.ShortService.UpdateShort(ctx, key, upd)
+	s.Invalidate(key)
+	return short, err
+}
+
+// DeleteShort permanently removes a Short and invalidates its key.
+func (s *ShortService) DeleteShort(ctx context.Context, key string) error {
+	err := s.ShortService.DeleteShort(ctx, key)
+	s.Invalidate(key)
+	return err
+}
+
+// Invalidate removes a key from the cache. Lookups in progress are not
+// cached once they complete.
+func (s *ShortService) Invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+}
+
+// Len returns the number of cached keys, including expired ones.
+func (s *ShortService) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// get returns the entry of key if it has not expired and marks it as the most
+// recently used. Expired entries are removed.
+func (s *ShortService) get(key string, now time.Time) (*shortEntry, bool) {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	ent := elem.Value.(*shortEntry)
+	if !now.Before(ent.expiresAt) {
+		s.remove(elem)
+		return nil, false
+	}
+
+	s.ll.MoveToFront(elem)
+	return ent, true
+}
+
+// add inserts or replaces an entry, evicting the least recently used entries
+// if the cache is full.
+func (s *ShortService) add(ent *shortEntry) {
+	if s.Size <= 0 {
+		return
+	}
+
+	if elem, ok := s.items[ent.key]; ok {
+		elem.Value = ent
+		s.ll.MoveToFront(elem)
+		return
+	}
+	s.items[ent.key] = s.ll.PushFront(ent)
+
+	for s.ll.Len() > s.Size {
+		s.remove(s.ll.Back())
+	}
+}
+
+// remove deletes an element from the list & the index.
+func (s *ShortService) remove(elem *list.Element) {
+	s.ll.Remove(elem)
+	delete(s.items, elem.Value.(*shortEntry).key)
+}
@@ -0,0 +1,181 @@
+package cache_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/cache"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestShortService_SearchShort(t *testing.T) {
+	// Ensure repeated lookups are served from the cache.
+	t.Run("OK", func(t *testing.T) {
+		s, counter, ctx := MustOpenShortService(t)
+		MustCreateShort(t, ctx, s, "example")
+
+		for i := 0; i < 3; i++ {
+			if short, err := s.SearchShort(context.Background(), "example"); err != nil {
+				t.Fatal(err)
+			} else if got, want := short.URL.String(), "https://example.com"; got != want {
+				t.Fatalf("URL=%v, want %v", got, want)
+			}
+		}
+		if got, want := counter.n, 1; got != want {
+			t.Fatalf("lookups=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure unknown keys are cached until the short is created.
+	t.Run("NotFound", func(t *testing.T) {
+		s, counter, ctx := MustOpenShortService(t)
+
+		for i := 0; i < 2; i++ {
+			if _, err := s.SearchShort(context.Background(), "example"); lil.ErrorCode(err) != lil.ENOTFOUND {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+		if got, want := counter.n, 1; got != want {
+			t.Fatalf("lookups=%v, want %v", got, want)
+		}
+
+		MustCreateShort(t, ctx, s, "example")
+		if _, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure entries are looked up again once expired.
+	t.Run("TTL", func(t *testing.T) {
+		s, counter, ctx := MustOpenShortService(t)
+		MustCreateShort(t, ctx, s, "example")
+
+		now := time.Now()
+		s.Now = func() time.Time { return now }
+		if _, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(s.TTL)
+		if _, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		} else if got, want := counter.n, 2; got != want {
+			t.Fatalf("lookups=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure a cached short stops redirecting once it expires.
+	t.Run("Expired", func(t *testing.T) {
+		s, _, ctx := MustOpenShortService(t)
+
+		expiresAt := time.Now().Add(time.Minute)
+		u, _ := url.Parse("https://example.com")
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "example", ExpiresAt: &expiresAt}); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		}
+
+		s.Now = func() time.Time { return expiresAt }
+		if _, err := s.SearchShort(context.Background(), "example"); lil.ErrorCode(err) != lil.EEXPIRED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure updates & deletions invalidate the cache.
+	t.Run("Invalidate", func(t *testing.T) {
+		s, _, ctx := MustOpenShortService(t)
+		MustCreateShort(t, ctx, s, "example")
+
+		if _, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse("https://example.org")
+		if _, err := s.UpdateShort(ctx, "example", lil.ShortUpdate{URL: u}); err != nil {
+			t.Fatal(err)
+		} else if short, err := s.SearchShort(context.Background(), "example"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.URL.String(), "https://example.org"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		if err := s.DeleteShort(ctx, "example"); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.SearchShort(context.Background(), "example"); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure the least recently used entries are evicted.
+	t.Run("Evict", func(t *testing.T) {
+		s, counter, ctx := MustOpenShortService(t)
+		s.Size = 2
+
+		for _, key := range []string{"aaa", "bbb", "ccc"} {
+			MustCreateShort(t, ctx, s, key)
+		}
+		for _, key := range []string{"aaa", "bbb", "aaa", "ccc"} {
+			if _, err := s.SearchShort(context.Background(), key); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got, want := s.Len(), 2; got != want {
+			t.Fatalf("Len=%v, want %v", got, want)
+		} else if got, want := counter.n, 3; got != want {
+			t.Fatalf("lookups=%v, want %v", got, want)
+		}
+
+		// "bbb" was the least recently used when "ccc" was added.
+		if _, err := s.SearchShort(context.Background(), "bbb"); err != nil {
+			t.Fatal(err)
+		} else if got, want := counter.n, 4; got != want {
+			t.Fatalf("lookups=%v, want %v", got, want)
+		}
+	})
+}
+
+// searchCounter wraps a ShortService to count the calls to SearchShort.
+type searchCounter struct {
+	lil.ShortService
+	n int
+}
+
+func (s *searchCounter) SearchShort(ctx context.Context, key string) (*lil.Short, error) {
+	s.n++
+	return s.ShortService.SearchShort(ctx, key)
+}
+
+// MustOpenShortService returns a cache in front of an in-memory SQLite
+// database, the counter of lookups reaching the database and the context of a
+// new user. The database is closed at the end of the test.
+func MustOpenShortService(tb testing.TB) (*cache.ShortService, *searchCounter, context.Context) {
+	tb.Helper()
+
+	db := sqlite.NewDB(":memory:")
+	if err := db.Open(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	user := &lil.User{Name: "susy"}
+	if err := sqlite.NewUserService(db).CreateUser(context.Background(), user); err != nil {
+		tb.Fatal(err)
+	}
+
+	counter := &searchCounter{ShortService: sqlite.NewShortService(db)}
+	return cache.NewShortService(counter), counter, lil.NewContextWithUser(context.Background(), user)
+}
+
+// MustCreateShort creates a short to "https://example.com". Fatal on error.
+func MustCreateShort(tb testing.TB, ctx context.Context, s lil.ShortService, key string) {
+	tb.Helper()
+	u, _ := url.Parse("https://example.com")
+	if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: key}); err != nil {
+		tb.Fatal(err)
+	}
+}
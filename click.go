@@ -0,0 +1,64 @@
+package lil
+
+import (
+	"context"
+	"time"
+)
+
+// User agent classes recorded on clicks.
+const (
+	UserAgentBrowser = "browser"
+	UserAgentMobile  = "mobile"
+	UserAgentBot     = "bot"
+	UserAgentCLI     = "cli"
+	UserAgentOther   = "other"
+)
+
+// Click represents a single redirect through a Short.
+type Click struct {
+	ID int `json:"id"`
+
+	// Key of the Short that was followed.
+	ShortKey string `json:"shortKey"`
+
+	// Host of the referring page, if any, and the class of the user agent.
+	Referrer  string `json:"referrer"`
+	UserAgent string `json:"userAgent"`
+
+	// Salted hash of the client IP. Used to count unique visitors without
+	// storing the address itself.
+	IPHash string `json:"-"`
+
+	// Time of the click.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ClickStats represents aggregated clicks for a single Short.
+type ClickStats struct {
+	ShortKey string `json:"shortKey"`
+
+	// Total number of clicks & number of distinct visitors.
+	Total  int `json:"total"`
+	Unique int `json:"unique"`
+
+	// Per-day counts, in chronological order. Days without clicks are omitted.
+	Days []*ClickDay `json:"days"`
+}
+
+// ClickDay represents the clicks received by a Short on a single UTC day.
+type ClickDay struct {
+	Date   time.Time `json:"date"`
+	Total  int       `json:"total"`
+	Unique int       `json:"unique"`
+}
+
+// ClickService represents a service for recording & aggregating clicks.
+type ClickService interface {
+	// Records a click. Implementations may write clicks asynchronously so
+	// a nil error does not guarantee the click has been persisted.
+	RecordClick(ctx context.Context, click *Click) error
+
+	// Returns the aggregated clicks of a Short. Returns ENOTFOUND if the
+	// Short does not exist or does not belong to the current user.
+	FindClickStats(ctx context.Context, key string) (*ClickStats, error)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/http"
+)
+
+const (
+	// DefaultConfigPath is the default path to the CLI configuration.
+	DefaultConfigPath = "~/.lil.toml"
+
+	// DefaultURL is the default lild server URL.
+	DefaultURL = "http://localhost:8082"
+)
+
+func main() {
+	// Setup signal handler and context.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// Execute program. Usage errors have already been printed by the flag set.
+	if err := Run(ctx, os.Args[1:]); err == flag.ErrHelp {
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Run executes the subcommand named by the first argument.
+func Run(ctx context.Context, args []string) error {
+	var cmd string
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "shorten":
+		return (&ShortenCommand{}).Run(ctx, args)
+	case "ls":
+		return (&ListCommand{}).Run(ctx, args)
+	case "rm":
+		return (&RemoveCommand{}).Run(ctx, args)
+	case "open":
+		return (&OpenCommand{}).Run(ctx, args)
+	case "", "help", "-h", "--help":
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(usage))
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("lil %s: unknown command", cmd)
+	}
+}
+
+const usage = `
+lil is a command line client for a lild server.
+
+Usage:
+
+	lil <command> [arguments]
+
+The commands are:
+
+	shorten <url>   create a new short
+	ls              list your shorts
+	rm <key>        delete a short
+	open <key>      open the target of a short in the browser,
+	                flagged shorts require -force
+
+Every command accepts -config to set the configuration path
+and -json to print machine-readable output.
+`
+
+// Config represents the CLI configuration file.
+type Config struct {
+	// Base URL of the lild server.
+	URL string `toml:"url"`
+
+	// API token or legacy API key used to authenticate as the user.
+	APIKey string `toml:"api-key"`
+}
+
+// DefaultConfig returns a new instance of Config with defaults set.
+func DefaultConfig() Config {
+	return Config{URL: DefaultURL}
+}
+
+// ReadConfigFile unmarshals config from filename. Tilde expansion is
+// performed on the path.
+func ReadConfigFile(filename string) (Config, error) {
+	config := DefaultConfig()
+
+	filename, err := expand(filename)
+	if err != nil {
+		return config, err
+	}
+
+	if buf, err := os.ReadFile(filename); os.IsNotExist(err) {
+		return config, fmt.Errorf("config file not found: %s", filename)
+	} else if err != nil {
+		return config, err
+	} else if err := toml.Unmarshal(buf, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Env represents the state shared by all commands once flags are parsed.
+type Env struct {
+	ConfigPath string
+	JSON       bool
+
+	Config Config
+}
+
+// RegisterFlags registers the flags shared by all commands.
+func (env *Env) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&env.ConfigPath, "config", DefaultConfigPath, "config path")
+	fs.BoolVar(&env.JSON, "json", false, "print output as JSON")
+}
+
+// Open reads the configuration file.
+func (env *Env) Open() (err error) {
+	env.Config, err = ReadConfigFile(env.ConfigPath)
+	return err
+}
+
+// Context returns ctx with the configured API key attached so the HTTP
+// client can authenticate requests.
+func (env *Env) Context(ctx context.Context) context.Context {
+	return lil.NewContextWithUser(ctx, &lil.User{APIKey: env.Config.APIKey})
+}
+
+// ShortService returns a client for the configured server.
+func (env *Env) ShortService() lil.ShortService {
+	return http.NewShortService(http.NewClient(env.Config.URL))
+}
+
+// expand returns path using tilde expansion. This means that a file path that
+// begins with the "~" will be expanded to prefix the user's home directory.
+func expand(path string) (string, error) {
+	// Ignore if path has no leading tilde.
+	if path != "~" && !strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		return path, nil
+	}
+
+	// Fetch the current user to determine the home path.
+	u, err := user.Current()
+	if err != nil {
+		return path, err
+	} else if u.HomeDir == "" {
+		return path, fmt.Errorf("home directory unset")
+	}
+
+	if path == "~" {
+		return u.HomeDir, nil
+	}
+	return filepath.Join(u.HomeDir, strings.TrimPrefix(path, "~"+string(os.PathSeparator))), nil
+}
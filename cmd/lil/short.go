@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"text/tabwriter"
+
+	"github.com/kriive/lil"
+)
+
+// ShortenCommand represents the "lil shorten" command.
+type ShortenCommand struct {
+	Env
+	Key string
+}
+
+// Run creates a new short for the URL passed as argument.
+func (c *ShortenCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lil-shorten", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	fs.StringVar(&c.Key, "key", "", "custom key, random if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lil shorten [-key KEY] <url>")
+	} else if err := c.Open(); err != nil {
+		return err
+	}
+
+	u, err := url.ParseRequestURI(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	short := &lil.Short{URL: *u, Key: c.Key}
+	if err := c.ShortService().CreateShort(c.Context(ctx), short); err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(short)
+	}
+	fmt.Println(c.Config.URL + "/s/" + short.Key)
+	return nil
+}
+
+// ListCommand represents the "lil ls" command.
+type ListCommand struct {
+	Env
+}
+
+// Run prints every short of the current user.
+func (c *ListCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lil-ls", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if err := c.Open(); err != nil {
+		return err
+	}
+
+	shorts, _, err := c.ShortService().FindShorts(c.Context(ctx), lil.ShortFilter{})
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(shorts)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tURL\tCREATED")
+	for _, short := range shorts {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", short.Key, short.URL.String(), short.CreatedAt.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+// RemoveCommand represents the "lil rm" command.
+type RemoveCommand struct {
+	Env
+}
+
+// Run deletes the shorts passed as arguments.
+func (c *RemoveCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lil-rm", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return fmt.Errorf("usage: lil rm <key>...")
+	} else if err := c.Open(); err != nil {
+		return err
+	}
+
+	deleted := make([]string, 0, fs.NArg())
+	for _, key := range fs.Args() {
+		if err := c.ShortService().DeleteShort(c.Context(ctx), key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		deleted = append(deleted, key)
+	}
+
+	if c.JSON {
+		return printJSON(struct {
+			Deleted []string `json:"deleted"`
+		}{deleted})
+	}
+	return nil
+}
+
+// OpenCommand represents the "lil open" command.
+type OpenCommand struct {
+	Env
+	Force bool
+}
+
+// Run resolves a short and opens its target in the default browser. Shorts
+// flagged by moderators are only opened with -force.
+func (c *OpenCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lil-open", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	fs.BoolVar(&c.Force, "force", false, "open flagged shorts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lil open [-force] <key>")
+	} else if err := c.Open(); err != nil {
+		return err
+	}
+
+	short, err := c.ShortService().SearchShort(c.Context(ctx), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		return printJSON(short)
+	}
+	fmt.Println(short.URL.String())
+
+	if short.Status == lil.ShortStatusFlagged {
+		fmt.Fprintf(os.Stderr, "warning: %s has been flagged by a moderator, the link may be harmful\n", short.Key)
+		if !c.Force {
+			return fmt.Errorf("not opening a flagged short, pass -force to open it anyway")
+		}
+	}
+	return openBrowser(short.URL.String())
+}
+
+// openBrowser opens u with the default browser of the platform.
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
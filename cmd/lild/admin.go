@@ -0,0 +1,822 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/importer"
+	"github.com/kriive/lil/postgres"
+	"github.com/kriive/lil/sqlite"
+)
+
+const adminUsage = `
+lild runs the lil server. When a command is given, it performs offline
+maintenance on the database instead.
+
+Usage:
+
+	lild [-config PATH]
+	lild [-config PATH] <command> [arguments]
+
+The commands are:
+
+	migrate status                       list migrations and their state
+	migrate up                           run pending migrations
+	backup <path>                        write a copy of the running database
+	restore <path>                       replace the database with a backup
+	export -user ID                      print the data of a user as json
+	import -format F -user ID <path>     import links, see -dry-run
+	user list                            list users
+	user create -name NAME [-email MAIL] create a user
+	user delete <id>                     delete a user and their shorts
+	user rotate-key <id>                 generate a new API key for a user
+	user revoke-sessions <id>            log a user out of every browser
+	user grant-admin <id>                make a user a site administrator
+	user revoke-admin <id>               remove the rights of an administrator
+	short list [-owner ID]               list shorts
+	short delete <key>                   delete a short
+
+Every command accepts -config to set the configuration path. Commands run
+against the configured db driver, except backup and restore which only
+support sqlite.
+`
+
+// RunCommand executes a maintenance command. Commands operate directly on
+// the database with administrator privileges, bypassing ownership checks.
+// The configuration path defaults to configPath, as passed before the command.
+func RunCommand(ctx context.Context, configPath, cmd string, args []string) error {
+	env := AdminEnv{ConfigPath: configPath}
+
+	// These commands have no subcommand.
+	switch cmd {
+	case "backup":
+		return (&BackupCommand{AdminEnv: env}).Run(ctx, args)
+	case "restore":
+		return (&RestoreCommand{AdminEnv: env}).Run(ctx, args)
+	case "export":
+		return (&ExportCommand{AdminEnv: env}).Run(lil.NewContextWithAdmin(ctx), args)
+	case "import":
+		return (&ImportCommand{AdminEnv: env}).Run(lil.NewContextWithAdmin(ctx), args)
+	}
+
+	var sub string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+
+	ctx = lil.NewContextWithAdmin(ctx)
+
+	switch cmd + " " + sub {
+	case "migrate status":
+		return (&MigrateStatusCommand{AdminEnv: env}).Run(ctx, args)
+	case "migrate up":
+		return (&MigrateUpCommand{AdminEnv: env}).Run(ctx, args)
+	case "user list":
+		return (&UserListCommand{AdminEnv: env}).Run(ctx, args)
+	case "user create":
+		return (&UserCreateCommand{AdminEnv: env}).Run(ctx, args)
+	case "user delete":
+		return (&UserDeleteCommand{AdminEnv: env}).Run(ctx, args)
+	case "user rotate-key":
+		return (&UserRotateKeyCommand{AdminEnv: env}).Run(ctx, args)
+	case "user grant-admin":
+		return (&UserAdminCommand{AdminEnv: env, Admin: true}).Run(ctx, args)
+	case "user revoke-admin":
+		return (&UserAdminCommand{AdminEnv: env, Admin: false}).Run(ctx, args)
+	case "user revoke-sessions":
+		return (&UserRevokeSessionsCommand{AdminEnv: env}).Run(ctx, args)
+	case "short list":
+		return (&ShortListCommand{AdminEnv: env}).Run(ctx, args)
+	case "short delete":
+		return (&ShortDeleteCommand{AdminEnv: env}).Run(ctx, args)
+	case "help ":
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(adminUsage))
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("lild %s: unknown command", strings.TrimSpace(cmd+" "+sub))
+	}
+}
+
+// AdminEnv represents the configuration & database shared by maintenance
+// commands.
+type AdminEnv struct {
+	ConfigPath string
+	Config     Config
+
+	// Databases of the configured driver. Only one of them is opened.
+	DB         *sqlite.DB
+	PostgresDB *postgres.DB
+
+	// Services of the opened database.
+	ShortService   lil.ShortService
+	UserService    lil.UserService
+	SessionService lil.SessionService
+}
+
+// RegisterFlags registers the flags shared by all commands. The config path
+// defaults to the one passed before the command.
+func (env *AdminEnv) RegisterFlags(fs *flag.FlagSet) {
+	if env.ConfigPath == "" {
+		env.ConfigPath = DefaultConfigPath
+	}
+	fs.StringVar(&env.ConfigPath, "config", env.ConfigPath, "config path")
+}
+
+// Open reads the configuration and opens the database of the configured
+// driver. If migrate is false, pending migrations are not executed.
+func (env *AdminEnv) Open(migrate bool) (err error) {
+	if err := env.ReadConfig(); err != nil {
+		return err
+	}
+
+	switch env.Config.DB.Driver {
+	case "", DBDriverSQLite:
+		dsn, err := env.SQLiteDSN()
+		if err != nil {
+			return err
+		}
+
+		env.DB = sqlite.NewDB(dsn)
+		env.DB.NoMigrate = !migrate
+		if err := env.DB.Open(); err != nil {
+			return fmt.Errorf("cannot open db: %w", err)
+		}
+
+		env.ShortService = sqlite.NewShortService(env.DB)
+		env.UserService = sqlite.NewUserService(env.DB)
+		env.SessionService = sqlite.NewSessionService(env.DB)
+
+	case DBDriverPostgres:
+		env.PostgresDB = postgres.NewDB(env.Config.DB.DSN)
+		env.PostgresDB.NoMigrate = !migrate
+		if err := env.PostgresDB.Open(); err != nil {
+			return fmt.Errorf("cannot open db: %w", err)
+		}
+
+		env.ShortService = postgres.NewShortService(env.PostgresDB)
+		env.UserService = postgres.NewUserService(env.PostgresDB)
+		env.SessionService = postgres.NewSessionService(env.PostgresDB)
+
+	default:
+		return fmt.Errorf("unknown db driver: %q", env.Config.DB.Driver)
+	}
+	return nil
+}
+
+// OpenReadOnly opens the database for commands which only read it. Pending
+// migrations are not executed, so an error is returned if there are any as
+// queries expect the current schema.
+func (env *AdminEnv) OpenReadOnly(ctx context.Context) error {
+	if err := env.Open(false); err != nil {
+		return err
+	}
+
+	migrations, err := env.Migrations(ctx)
+	if err != nil {
+		env.Close()
+		return err
+	}
+	for _, m := range migrations {
+		if !m.Applied {
+			env.Close()
+			return fmt.Errorf("database has pending migrations, run \"lild migrate up\" first")
+		}
+	}
+	return nil
+}
+
+// Migration represents a migration of the opened database & whether it has
+// been executed.
+type Migration struct {
+	Name    string
+	Applied bool
+}
+
+// Migrations returns every migration of the opened database in execution
+// order.
+func (env *AdminEnv) Migrations(ctx context.Context) ([]Migration, error) {
+	var a []Migration
+	if env.PostgresDB != nil {
+		migrations, err := env.PostgresDB.Migrations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range migrations {
+			a = append(a, Migration{Name: m.Name, Applied: m.Applied})
+		}
+		return a, nil
+	}
+
+	migrations, err := env.DB.Migrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range migrations {
+		a = append(a, Migration{Name: m.Name, Applied: m.Applied})
+	}
+	return a, nil
+}
+
+// ReadConfig reads the configuration without opening the database.
+func (env *AdminEnv) ReadConfig() (err error) {
+	configPath, err := expand(env.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	if env.Config, err = ReadConfigFile(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", env.ConfigPath)
+	}
+	return err
+}
+
+// SQLiteDSN returns the expanded DSN of the configured database. Returns an
+// error for other drivers, as backups are copies of the database file.
+func (env *AdminEnv) SQLiteDSN() (string, error) {
+	if d := env.Config.DB.Driver; d != "" && d != DBDriverSQLite {
+		return "", fmt.Errorf("the %q db driver has no backup support, use its own tools", d)
+	}
+
+	dsn, err := expandDSN(env.Config.DB.DSN)
+	if err != nil {
+		return "", fmt.Errorf("cannot expand dsn: %w", err)
+	}
+	return dsn, nil
+}
+
+// Close closes the database.
+func (env *AdminEnv) Close() error {
+	if env.PostgresDB != nil {
+		return env.PostgresDB.Close()
+	} else if env.DB != nil {
+		return env.DB.Close()
+	}
+	return nil
+}
+
+// parse parses args into fs, registering the shared flags first, and
+// ensures exactly nargs positional arguments were passed.
+func (env *AdminEnv) parse(fs *flag.FlagSet, args []string, nargs int, usage string) error {
+	env.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() != nargs {
+		return fmt.Errorf("usage: lild %s", usage)
+	}
+	return nil
+}
+
+// MigrateStatusCommand represents the "lild migrate status" command.
+type MigrateStatusCommand struct {
+	AdminEnv
+}
+
+// Run prints every migration and whether it has been applied.
+func (c *MigrateStatusCommand) Run(ctx context.Context, args []string) error {
+	if err := c.parse(flag.NewFlagSet("lild-migrate-status", flag.ContinueOnError), args, 0, "migrate status"); err != nil {
+		return err
+	} else if err := c.Open(false); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	migrations, err := c.Migrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tSTATUS")
+	for _, m := range migrations {
+		status := "pending"
+		if m.Applied {
+			status = "applied"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", m.Name, status)
+	}
+	return w.Flush()
+}
+
+// MigrateUpCommand represents the "lild migrate up" command.
+type MigrateUpCommand struct {
+	AdminEnv
+}
+
+// Run opens the database, executing any pending migration.
+func (c *MigrateUpCommand) Run(ctx context.Context, args []string) error {
+	if err := c.parse(flag.NewFlagSet("lild-migrate-up", flag.ContinueOnError), args, 0, "migrate up"); err != nil {
+		return err
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// BackupCommand represents the "lild backup" command.
+type BackupCommand struct {
+	AdminEnv
+}
+
+// Run writes a consistent copy of the database. The server can keep running.
+func (c *BackupCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-backup", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "backup <path>"); err != nil {
+		return err
+	} else if err := c.ReadConfig(); err != nil {
+		return err
+	} else if _, err := c.SQLiteDSN(); err != nil {
+		return err
+	} else if err := c.Open(false); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.DB.Backup(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Printf("backed up database: path=%s\n", fs.Arg(0))
+	return nil
+}
+
+// RestoreCommand represents the "lild restore" command.
+type RestoreCommand struct {
+	AdminEnv
+}
+
+// Run verifies a backup and swaps it in place of the database. The current
+// database and its WAL are kept next to it with an ".orig" suffix. Fails if
+// the database is open, as the server must be stopped beforehand.
+func (c *RestoreCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-restore", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "restore <path>"); err != nil {
+		return err
+	}
+
+	if err := c.ReadConfig(); err != nil {
+		return err
+	}
+	dsn, err := c.SQLiteDSN()
+	if err != nil {
+		return err
+	} else if dsn == ":memory:" {
+		return fmt.Errorf("cannot restore an in-memory database")
+	}
+
+	// Refuse to swap files from under a running server or command.
+	lock, err := sqlite.Lock(dsn)
+	if err == sqlite.ErrLocked {
+		return fmt.Errorf("database is in use, stop the server before restoring")
+	} else if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	// Never overwrite the database kept by a previous restore.
+	origPath := dsn + ".orig"
+	if _, err := os.Stat(origPath); err == nil {
+		return fmt.Errorf("%s already exists, move it away before restoring", origPath)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Verify a copy so that the backup itself is never modified.
+	tmpPath := dsn + ".restore"
+	if err := copyFile(fs.Arg(0), tmpPath); err != nil {
+		return err
+	}
+	defer removeDBFiles(tmpPath)
+
+	pending, err := verifyBackup(ctx, tmpPath)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	// Keep the current database aside along with its WAL, which may hold
+	// committed transactions that have not been checkpointed yet.
+	if err := moveDBFiles(dsn, origPath); err != nil {
+		return err
+	} else if err := os.Rename(tmpPath, dsn); err != nil {
+		return err
+	}
+
+	fmt.Printf("restored database: path=%s pending-migrations=%d\n", dsn, pending)
+	return nil
+}
+
+// verifyBackup checks the integrity & migration state of the database at
+// path. Returns the number of migrations to run when the server starts.
+func verifyBackup(ctx context.Context, path string) (pending int, err error) {
+	db := sqlite.NewDB(path)
+	db.NoMigrate = true
+	if err := db.Open(); err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := db.Verify(ctx); err != nil {
+		return 0, err
+	}
+
+	migrations, err := db.Migrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range migrations {
+		if !m.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// copyFile copies src to a new file at dst, replacing it if it exists.
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// removeDBFiles removes a database along with its WAL, shared memory & lock
+// files.
+func removeDBFiles(path string) error {
+	for _, name := range []string{path, path + "-wal", path + "-shm", path + ".lock"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDBFiles renames a database along with its WAL & shared memory files,
+// if they exist, so that they are kept together under the new path.
+func moveDBFiles(path, newPath string) error {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(path+suffix, newPath+suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportCommand represents the "lild export" command.
+type ExportCommand struct {
+	AdminEnv
+}
+
+// Run prints the data of a user in the export format, which can be imported
+// from the settings page of another instance.
+func (c *ExportCommand) Run(ctx context.Context, args []string) error {
+	var userID int
+	fs := flag.NewFlagSet("lild-export", flag.ContinueOnError)
+	fs.IntVar(&userID, "user", 0, "user id")
+	if err := c.parse(fs, args, 0, "export -user ID"); err != nil {
+		return err
+	} else if userID == 0 {
+		return fmt.Errorf("usage: lild export -user ID")
+	} else if err := c.OpenReadOnly(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	e, err := lil.ExportUserData(ctx, c.UserService, c.ShortService, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(e)
+}
+
+// ImportCommand represents the "lild import" command.
+type ImportCommand struct {
+	AdminEnv
+}
+
+// Run creates shorts owned by a user from the dump of another shortener and
+// prints a report of every link which was renamed or skipped. With -dry-run,
+// nothing is created.
+func (c *ImportCommand) Run(ctx context.Context, args []string) error {
+	var format string
+	var userID int
+	var dryRun bool
+	fs := flag.NewFlagSet("lild-import", flag.ContinueOnError)
+	fs.StringVar(&format, "format", importer.FormatCSV, "dump format: "+strings.Join(importer.Formats, ", "))
+	fs.IntVar(&userID, "user", 0, "id of the user owning the shorts")
+	fs.BoolVar(&dryRun, "dry-run", false, "report without creating shorts")
+	usage := "import -format F -user ID [-dry-run] <path>"
+	if err := c.parse(fs, args, 1, usage); err != nil {
+		return err
+	} else if userID == 0 {
+		return fmt.Errorf("usage: lild %s", usage)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rows, err := importer.Read(f, format)
+	if err != nil {
+		return err
+	}
+
+	// A dry run must leave the database untouched, migrations included.
+	if dryRun {
+		err = c.OpenReadOnly(ctx)
+	} else {
+		err = c.Open(true)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Shorts are created on behalf of the user so that they own them.
+	user, err := c.UserService.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	imp := importer.NewImporter(c.ShortService)
+	imp.KeyPolicy = keyPolicy(c.Config)
+	imp.KeyLength = c.Config.General.KeyLength
+	imp.Alphabet = c.Config.General.Alphabet
+	imp.DryRun = dryRun
+
+	report, err := imp.Import(lil.NewContextWithUser(ctx, user), rows)
+	if report != nil {
+		if err := printImportReport(report); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// printImportReport prints the rows of an import which were not created
+// with their original key, followed by a summary.
+func printImportReport(report *importer.Report) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tKEY\tSTATUS\tURL\tDETAIL")
+	for _, result := range report.Results {
+		if result.Status != importer.StatusCreated {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", result.Line, result.Key, result.Status, result.URL, result.Detail)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	prefix := "imported"
+	if report.DryRun {
+		prefix = "dry run"
+	}
+	fmt.Printf("%s: created=%d renamed=%d collisions=%d invalid=%d\n", prefix,
+		report.Count(importer.StatusCreated),
+		report.Count(importer.StatusRenamed),
+		report.Count(importer.StatusCollision),
+		report.Count(importer.StatusInvalid),
+	)
+	return nil
+}
+
+// UserListCommand represents the "lild user list" command.
+type UserListCommand struct {
+	AdminEnv
+}
+
+// Run prints every user.
+func (c *UserListCommand) Run(ctx context.Context, args []string) error {
+	if err := c.parse(flag.NewFlagSet("lild-user-list", flag.ContinueOnError), args, 0, "user list"); err != nil {
+		return err
+	} else if err := c.OpenReadOnly(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	users, _, err := c.UserService.FindUsers(ctx, lil.UserFilter{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tADMIN\tCREATED")
+	for _, user := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n", user.ID, user.Name, user.Email, user.Admin, user.CreatedAt.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+// UserCreateCommand represents the "lild user create" command.
+type UserCreateCommand struct {
+	AdminEnv
+}
+
+// Run creates a new user and prints its API key.
+func (c *UserCreateCommand) Run(ctx context.Context, args []string) error {
+	var user lil.User
+	fs := flag.NewFlagSet("lild-user-create", flag.ContinueOnError)
+	fs.StringVar(&user.Name, "name", "", "user name")
+	fs.StringVar(&user.Email, "email", "", "user email")
+	if err := c.parse(fs, args, 0, "user create -name NAME [-email EMAIL]"); err != nil {
+		return err
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// The administrator vouches for the email, so that logins are linked.
+	user.EmailVerified = user.Email != ""
+	if err := c.UserService.CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	fmt.Printf("created user: id=%d api-key=%s\n", user.ID, user.APIKey)
+	return nil
+}
+
+// UserDeleteCommand represents the "lild user delete" command.
+type UserDeleteCommand struct {
+	AdminEnv
+}
+
+// Run deletes a user along with their shorts.
+func (c *UserDeleteCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-user-delete", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "user delete <id>"); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid user id: %q", fs.Arg(0))
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.UserService.DeleteUser(ctx, id)
+}
+
+// UserRotateKeyCommand represents the "lild user rotate-key" command.
+type UserRotateKeyCommand struct {
+	AdminEnv
+}
+
+// Run replaces the API key of a user and prints the new one.
+func (c *UserRotateKeyCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-user-rotate-key", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "user rotate-key <id>"); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid user id: %q", fs.Arg(0))
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	user, err := c.UserService.RotateAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rotated api key: id=%d api-key=%s\n", user.ID, user.APIKey)
+	return nil
+}
+
+// UserAdminCommand represents the "lild user grant-admin" & "lild user
+// revoke-admin" commands.
+type UserAdminCommand struct {
+	AdminEnv
+
+	// Set to grant administrator rights, unset to revoke them.
+	Admin bool
+}
+
+// Run grants or revokes the administrator rights of a user.
+func (c *UserAdminCommand) Run(ctx context.Context, args []string) error {
+	name := "revoke-admin"
+	if c.Admin {
+		name = "grant-admin"
+	}
+
+	fs := flag.NewFlagSet("lild-user-"+name, flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "user "+name+" <id>"); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid user id: %q", fs.Arg(0))
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	user, err := c.UserService.UpdateUser(ctx, id, lil.UserUpdate{Admin: &c.Admin})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("updated user: id=%d admin=%t\n", user.ID, user.Admin)
+	return nil
+}
+
+// UserRevokeSessionsCommand represents the "lild user revoke-sessions" command.
+type UserRevokeSessionsCommand struct {
+	AdminEnv
+}
+
+// Run revokes every browser session of a user.
+func (c *UserRevokeSessionsCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-user-revoke-sessions", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "user revoke-sessions <id>"); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid user id: %q", fs.Arg(0))
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.SessionService.RevokeUserSessions(ctx, id)
+}
+
+// ShortListCommand represents the "lild short list" command.
+type ShortListCommand struct {
+	AdminEnv
+}
+
+// Run prints every short, optionally restricted to one owner.
+func (c *ShortListCommand) Run(ctx context.Context, args []string) error {
+	var ownerID int
+	fs := flag.NewFlagSet("lild-short-list", flag.ContinueOnError)
+	fs.IntVar(&ownerID, "owner", 0, "only list shorts of this user id")
+	if err := c.parse(fs, args, 0, "short list [-owner ID]"); err != nil {
+		return err
+	} else if err := c.OpenReadOnly(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var filter lil.ShortFilter
+	if ownerID != 0 {
+		filter.OwnerID = &ownerID
+	}
+
+	shorts, _, err := c.ShortService.FindShorts(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tOWNER\tURL\tCREATED")
+	for _, short := range shorts {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", short.Key, short.OwnerID, short.URL.String(), short.CreatedAt.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+// ShortDeleteCommand represents the "lild short delete" command.
+type ShortDeleteCommand struct {
+	AdminEnv
+}
+
+// Run deletes a short regardless of its owner.
+func (c *ShortDeleteCommand) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lild-short-delete", flag.ContinueOnError)
+	if err := c.parse(fs, args, 1, "short delete <key>"); err != nil {
+		return err
+	} else if err := c.Open(true); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.ShortService.DeleteShort(ctx, fs.Arg(0))
+}
@@ -4,18 +4,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/kriive/lil"
+	"github.com/kriive/lil/cache"
 	"github.com/kriive/lil/http"
 	"github.com/kriive/lil/http/html"
+	"github.com/kriive/lil/postgres"
 	"github.com/kriive/lil/sqlite"
 )
 
@@ -32,11 +34,36 @@ const (
 	// DefaultDSN is the default datasource name.
 	DefaultDSN = "~/.lild/db"
 
+	// Defaults for scheduled backups, which are disabled unless an interval
+	// is set.
+	DefaultBackupDir    = "~/.lild/backups"
+	DefaultBackupRetain = 7
+
+	// Database drivers. SQLite is used by default.
+	DBDriverSQLite   = "sqlite"
+	DBDriverPostgres = "postgres"
+
 	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 	DefaultKeyLength = 6
+
+	// Authentication modes. In dev mode, the login page lets anyone log in
+	// as any user, alongside the configured OAuth providers.
+	AuthModeOAuth = "oauth"
+	AuthModeDev   = "dev"
+
+	// Defaults for user-chosen (vanity) keys.
+	DefaultVanityAlphabet  = DefaultAlphabet + "-_"
+	DefaultVanityMinLength = 3
+	DefaultVanityMaxLength = 64
 )
 
+// DefaultReservedKeys lists the keys that users cannot request by default.
+var DefaultReservedKeys = []string{
+	"login", "logout", "oauth", "assets", "debug", "short", "new", "s", "admin",
+	"settings", "metrics",
+}
+
 func main() {
 	lil.Version = version
 	lil.Commit = commit
@@ -55,6 +82,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Execute a maintenance command instead of the server, if one is given.
+	if len(m.Args) > 0 {
+		if err := RunCommand(ctx, m.ConfigPath, m.Args[0], m.Args[1:]); err == flag.ErrHelp {
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Execute program.
 	if err := m.Run(ctx); err != nil {
 		m.Close()
@@ -77,24 +115,98 @@ type Main struct {
 	Config     Config
 	ConfigPath string
 
-	// SQLite database used by SQLite service implementations.
-	DB *sqlite.DB
+	// Maintenance command & its arguments left after the flags, if any.
+	Args []string
+
+	// Databases used by the service implementations. Only the one of the
+	// configured driver is opened.
+	DB         *sqlite.DB
+	PostgresDB *postgres.DB
+
+	// Click service, closed before the database to flush pending clicks.
+	ClickService interface {
+		lil.ClickService
+		Open() error
+		Close() error
+	}
 
 	// HTTP server for handling HTTP communication.
-	// SQLite services are attached to it before running.
+	// Database services are attached to it before running.
 	HTTPServer *http.Server
 }
 
 func (m *Main) Run(ctx context.Context) (err error) {
-	// Expand the DSN (in case it is in the user home directory ("~")).
-	// Then open the database. This will instantiate the SQLite connection
-	// and execute any pending migration files.
-	if m.DB.DSN, err = expandDSN(m.Config.DB.DSN); err != nil {
-		return fmt.Errorf("cannot expand dsn: %w", err)
-	}
+	var (
+		shortService   lil.ShortService
+		authService    lil.AuthService
+		userService    lil.UserService
+		teamService    lil.TeamService
+		auditService   lil.AuditService
+		tokenService   lil.TokenService
+		sessionService lil.SessionService
+	)
+
+	// Open the database of the configured driver. This will instantiate the
+	// connection and execute any pending migration files.
+	switch m.Config.DB.Driver {
+	case "", DBDriverSQLite:
+		// Expand the DSN (in case it is in the user home directory ("~")).
+		if m.DB.DSN, err = expandDSN(m.Config.DB.DSN); err != nil {
+			return fmt.Errorf("cannot expand dsn: %w", err)
+		}
+
+		m.DB.SweepInterval = m.Config.DB.SweepInterval
+		m.DB.SweepGracePeriod = m.Config.DB.SweepGracePeriod
+		m.DB.SweepArchive = m.Config.DB.SweepArchive
+
+		if m.DB.BackupDir, err = expand(m.Config.Backup.Dir); err != nil {
+			return fmt.Errorf("cannot expand backup dir: %w", err)
+		}
+		m.DB.BackupInterval = m.Config.Backup.Interval
+		m.DB.BackupRetain = m.Config.Backup.Retain
+
+		if err := m.DB.Open(); err != nil {
+			return fmt.Errorf("cannot open db: %w", err)
+		}
+
+		shortService = sqlite.NewShortService(m.DB)
+		authService = sqlite.NewAuthService(m.DB)
+		userService = sqlite.NewUserService(m.DB)
+		teamService = sqlite.NewTeamService(m.DB)
+		auditService = sqlite.NewAuditService(m.DB)
+		tokenService = sqlite.NewTokenService(m.DB)
+		sessionService = sqlite.NewSessionService(m.DB)
+
+		clickService := sqlite.NewClickService(m.DB)
+		clickService.BufferSize = m.Config.Clicks.BufferSize
+		clickService.FlushInterval = m.Config.Clicks.FlushInterval
+		m.ClickService = clickService
+
+	case DBDriverPostgres:
+		m.PostgresDB.DSN = m.Config.DB.DSN
+		m.PostgresDB.SweepInterval = m.Config.DB.SweepInterval
+		m.PostgresDB.SweepGracePeriod = m.Config.DB.SweepGracePeriod
+		m.PostgresDB.SweepArchive = m.Config.DB.SweepArchive
+
+		if err := m.PostgresDB.Open(); err != nil {
+			return fmt.Errorf("cannot open db: %w", err)
+		}
 
-	if err := m.DB.Open(); err != nil {
-		return fmt.Errorf("cannot open db: %w", err)
+		shortService = postgres.NewShortService(m.PostgresDB)
+		authService = postgres.NewAuthService(m.PostgresDB)
+		userService = postgres.NewUserService(m.PostgresDB)
+		teamService = postgres.NewTeamService(m.PostgresDB)
+		auditService = postgres.NewAuditService(m.PostgresDB)
+		tokenService = postgres.NewTokenService(m.PostgresDB)
+		sessionService = postgres.NewSessionService(m.PostgresDB)
+
+		clickService := postgres.NewClickService(m.PostgresDB)
+		clickService.BufferSize = m.Config.Clicks.BufferSize
+		clickService.FlushInterval = m.Config.Clicks.FlushInterval
+		m.ClickService = clickService
+
+	default:
+		return fmt.Errorf("unknown db driver: %q", m.Config.DB.Driver)
 	}
 
 	htmlEngine, err := html.NewEngine(html.FS)
@@ -122,31 +234,124 @@ func (m *Main) Run(ctx context.Context) (err error) {
 		return err
 	}
 
+	interstitialView, err := htmlEngine.InterstitialView()
+	if err != nil {
+		return err
+	}
+
 	indexView, err := htmlEngine.IndexView()
 	if err != nil {
 		return err
 	}
 
-	shortService := sqlite.NewShortService(m.DB)
-	authService := sqlite.NewAuthService(m.DB)
-	userService := sqlite.NewUserService(m.DB)
+	errorView, err := htmlEngine.ErrorView()
+	if err != nil {
+		return err
+	}
+
+	settingsView, err := htmlEngine.SettingsView()
+	if err != nil {
+		return err
+	}
+
+	deleteAccountView, err := htmlEngine.DeleteAccountView()
+	if err != nil {
+		return err
+	}
+
+	sessionsView, err := htmlEngine.SessionsView()
+	if err != nil {
+		return err
+	}
+
+	adminShortsView, err := htmlEngine.AdminShortsView()
+	if err != nil {
+		return err
+	}
+
+	adminUsersView, err := htmlEngine.AdminUsersView()
+	if err != nil {
+		return err
+	}
+
+	adminAuditView, err := htmlEngine.AdminAuditView()
+	if err != nil {
+		return err
+	}
+
+	// Cache the shorts looked up by redirects, unless disabled.
+	if m.Config.Cache.Size > 0 {
+		shortCache := cache.NewShortService(shortService)
+		shortCache.Size = m.Config.Cache.Size
+		shortCache.TTL = m.Config.Cache.TTL
+		shortCache.NegativeTTL = m.Config.Cache.NegativeTTL
+		shortService = shortCache
+	}
+
+	if err := m.ClickService.Open(); err != nil {
+		return fmt.Errorf("cannot open click service: %w", err)
+	}
 
 	m.HTTPServer.Addr = m.Config.HTTP.Addr
 	m.HTTPServer.Domain = m.Config.HTTP.Domain
 	m.HTTPServer.Alphabet = m.Config.General.Alphabet
 	m.HTTPServer.KeyLength = m.Config.General.KeyLength
+	m.HTTPServer.KeyPolicy = keyPolicy(m.Config)
 
 	m.HTTPServer.HashKey = m.Config.HTTP.HashKey
 	m.HTTPServer.BlockKey = m.Config.HTTP.BlockKey
 
-	m.HTTPServer.GitHubClientID = m.Config.GitHub.ClientID
-	m.HTTPServer.GitHubClientSecret = m.Config.GitHub.ClientSecret
-	m.HTTPServer.GoogleClientID = m.Config.Google.ClientID
-	m.HTTPServer.GoogleClientSecret = m.Config.Google.ClientSecret
+	m.HTTPServer.MetricsToken = m.Config.Metrics.Token
+	m.HTTPServer.MetricsAddr = m.Config.Metrics.Addr
+
+	// Register the configured login providers in the order shown on the
+	// login page. A provider is enabled as soon as any of its settings is set
+	// so that incomplete credentials are reported on startup.
+	if m.Config.Google.ClientID != "" || m.Config.Google.ClientSecret != "" {
+		m.HTTPServer.OAuthProviders = append(m.HTTPServer.OAuthProviders, &http.GoogleProvider{
+			ClientID:     m.Config.Google.ClientID,
+			ClientSecret: m.Config.Google.ClientSecret,
+		})
+	}
+	if m.Config.GitHub.ClientID != "" || m.Config.GitHub.ClientSecret != "" {
+		m.HTTPServer.OAuthProviders = append(m.HTTPServer.OAuthProviders, &http.GitHubProvider{
+			ClientID:     m.Config.GitHub.ClientID,
+			ClientSecret: m.Config.GitHub.ClientSecret,
+		})
+	}
+
+	// Enable the generic OpenID Connect provider if an issuer is configured.
+	if m.Config.OIDC.Issuer != "" {
+		m.HTTPServer.OAuthProviders = append(m.HTTPServer.OAuthProviders, &http.OIDCProvider{
+			Name:         m.Config.OIDC.Name,
+			Issuer:       m.Config.OIDC.Issuer,
+			ClientID:     m.Config.OIDC.ClientID,
+			ClientSecret: m.Config.OIDC.ClientSecret,
+			Scopes:       m.Config.OIDC.Scopes,
+			NameClaim:    m.Config.OIDC.NameClaim,
+			EmailClaim:   m.Config.OIDC.EmailClaim,
+			PictureClaim: m.Config.OIDC.PictureClaim,
+		})
+	}
 
 	m.HTTPServer.AuthService = authService
 	m.HTTPServer.ShortService = shortService
 	m.HTTPServer.UserService = userService
+	m.HTTPServer.TeamService = teamService
+	m.HTTPServer.AuditService = auditService
+	m.HTTPServer.TokenService = tokenService
+	m.HTTPServer.SessionService = sessionService
+	m.HTTPServer.ClickService = m.ClickService
+	m.HTTPServer.ClickSalt = m.Config.Clicks.Salt
+
+	switch m.Config.Auth.Mode {
+	case "", AuthModeOAuth:
+	case AuthModeDev:
+		m.HTTPServer.DevLogin = true
+		log.Printf("dev login enabled: anyone can log in as any user")
+	default:
+		return fmt.Errorf("unknown auth mode: %q", m.Config.Auth.Mode)
+	}
 
 	// Attach all the views
 	m.HTTPServer.Views.LoginView = loginView
@@ -154,6 +359,14 @@ func (m *Main) Run(ctx context.Context) (err error) {
 	m.HTTPServer.Views.NewShort = newShort
 	m.HTTPServer.Views.IndexView = indexView
 	m.HTTPServer.Views.ShortsIndexView = shortIndexView
+	m.HTTPServer.Views.InterstitialView = interstitialView
+	m.HTTPServer.Views.ErrorView = errorView
+	m.HTTPServer.Views.SettingsView = settingsView
+	m.HTTPServer.Views.DeleteAccountView = deleteAccountView
+	m.HTTPServer.Views.SessionsView = sessionsView
+	m.HTTPServer.Views.AdminShortsView = adminShortsView
+	m.HTTPServer.Views.AdminUsersView = adminUsersView
+	m.HTTPServer.Views.AdminAuditView = adminAuditView
 
 	// Start the HTTP server.
 	if err := m.HTTPServer.Open(); err != nil {
@@ -167,7 +380,12 @@ func (m *Main) Run(ctx context.Context) (err error) {
 		}()
 	}
 
-	log.Printf("running: url=%q dsn=%q", m.HTTPServer.URL(), m.Config.DB.DSN)
+	// PostgreSQL DSNs may hold a password so they are not logged.
+	if m.PostgresDB.DSN != "" {
+		log.Printf("running: url=%q driver=%q", m.HTTPServer.URL(), DBDriverPostgres)
+	} else {
+		log.Printf("running: url=%q dsn=%q", m.HTTPServer.URL(), m.Config.DB.DSN)
+	}
 
 	return nil
 }
@@ -179,6 +397,7 @@ func NewMain() *Main {
 		ConfigPath: DefaultConfigPath,
 
 		DB:         sqlite.NewDB(""),
+		PostgresDB: postgres.NewDB(""),
 		HTTPServer: http.NewServer(),
 	}
 }
@@ -190,11 +409,21 @@ func (m *Main) Close() error {
 			return err
 		}
 	}
+	if m.ClickService != nil {
+		if err := m.ClickService.Close(); err != nil {
+			return err
+		}
+	}
 	if m.DB != nil {
 		if err := m.DB.Close(); err != nil {
 			return err
 		}
 	}
+	if m.PostgresDB != nil {
+		if err := m.PostgresDB.Close(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -210,6 +439,11 @@ func (m *Main) ParseFlags(ctx context.Context, args []string) error {
 		return err
 	}
 
+	// Maintenance commands read the configuration themselves.
+	if m.Args = fs.Args(); len(m.Args) > 0 {
+		return nil
+	}
+
 	// The expand() function is here to automatically expand "~" to the user's
 	// home directory. This is a common task as configuration files are typing
 	// under the home directory during local development.
@@ -233,9 +467,25 @@ func (m *Main) ParseFlags(ctx context.Context, args []string) error {
 // Config represents the CLI configuration file.
 type Config struct {
 	DB struct {
-		DSN string `toml:"dsn"`
+		// Either "sqlite" (default) or "postgres". The DSN is a file path
+		// for SQLite and a connection string for PostgreSQL.
+		Driver string `toml:"driver"`
+		DSN    string `toml:"dsn"`
+
+		// Removal of expired shorts. Disabled if the interval is zero.
+		SweepInterval    time.Duration `toml:"sweep-interval"`
+		SweepGracePeriod time.Duration `toml:"sweep-grace-period"`
+		SweepArchive     bool          `toml:"sweep-archive"`
 	} `toml:"db"`
 
+	Backup struct {
+		// Scheduled backups of the SQLite database. Disabled if the
+		// interval is zero. Zero retain keeps every backup.
+		Dir      string        `toml:"dir"`
+		Interval time.Duration `toml:"interval"`
+		Retain   int           `toml:"retain"`
+	} `toml:"backup"`
+
 	HTTP struct {
 		Addr     string `toml:"addr"`
 		Domain   string `toml:"domain"`
@@ -243,6 +493,19 @@ type Config struct {
 		BlockKey string `toml:"block-key"`
 	} `toml:"http"`
 
+	Metrics struct {
+		// Bearer token required to read "/metrics" on the main address.
+		Token string `toml:"token"`
+
+		// Separate address serving metrics without authentication.
+		Addr string `toml:"addr"`
+	} `toml:"metrics"`
+
+	Auth struct {
+		// Either "oauth" (default) or "dev".
+		Mode string `toml:"mode"`
+	} `toml:"auth"`
+
 	GitHub struct {
 		ClientID     string `toml:"client-id"`
 		ClientSecret string `toml:"client-secret"`
@@ -253,25 +516,81 @@ type Config struct {
 		ClientSecret string `toml:"client-secret"`
 	} `toml:"google"`
 
+	OIDC struct {
+		Name         string   `toml:"name"`
+		Issuer       string   `toml:"issuer"`
+		ClientID     string   `toml:"client-id"`
+		ClientSecret string   `toml:"client-secret"`
+		Scopes       []string `toml:"scopes"`
+
+		// Claims mapped onto the user.
+		NameClaim    string `toml:"name-claim"`
+		EmailClaim   string `toml:"email-claim"`
+		PictureClaim string `toml:"picture-claim"`
+	} `toml:"oidc"`
+
 	General struct {
 		Alphabet  string `toml:"alphabet"`
 		KeyLength int    `toml:"key-length"`
 	} `toml:"general"`
+
+	Clicks struct {
+		// Secret used to hash client IPs. Required, the server refuses to
+		// start without it.
+		Salt          string        `toml:"salt"`
+		BufferSize    int           `toml:"buffer-size"`
+		FlushInterval time.Duration `toml:"flush-interval"`
+	} `toml:"clicks"`
+
+	Cache struct {
+		// Maximum number of shorts cached for redirects. Zero, the default,
+		// disables the cache as cached shorts can be stale for up to TTL.
+		Size        int           `toml:"size"`
+		TTL         time.Duration `toml:"ttl"`
+		NegativeTTL time.Duration `toml:"negative-ttl"`
+	} `toml:"cache"`
+
+	Vanity struct {
+		Alphabet  string   `toml:"alphabet"`
+		MinLength int      `toml:"min-length"`
+		MaxLength int      `toml:"max-length"`
+		Reserved  []string `toml:"reserved"`
+	} `toml:"vanity"`
 }
 
 // DefaultConfig returns a new instance of Config with defaults set.
 func DefaultConfig() Config {
 	var config Config
 	config.DB.DSN = DefaultDSN
+	config.Backup.Dir = DefaultBackupDir
+	config.Backup.Retain = DefaultBackupRetain
 	config.General.Alphabet = DefaultAlphabet
 	config.General.KeyLength = DefaultKeyLength
+	config.Clicks.BufferSize = sqlite.DefaultClickBufferSize
+	config.Clicks.FlushInterval = sqlite.DefaultClickFlushInterval
+	config.Cache.TTL = cache.DefaultShortCacheTTL
+	config.Cache.NegativeTTL = cache.DefaultShortCacheNegativeTTL
+	config.Vanity.Alphabet = DefaultVanityAlphabet
+	config.Vanity.MinLength = DefaultVanityMinLength
+	config.Vanity.MaxLength = DefaultVanityMaxLength
+	config.Vanity.Reserved = DefaultReservedKeys
 	return config
 }
 
+// keyPolicy returns the policy of vanity keys set by config.
+func keyPolicy(config Config) lil.KeyPolicy {
+	return lil.KeyPolicy{
+		Alphabet:  config.Vanity.Alphabet,
+		MinLength: config.Vanity.MinLength,
+		MaxLength: config.Vanity.MaxLength,
+		Reserved:  config.Vanity.Reserved,
+	}
+}
+
 // ReadConfigFile unmarshals config from
 func ReadConfigFile(filename string) (Config, error) {
 	config := DefaultConfig()
-	if buf, err := ioutil.ReadFile(filename); err != nil {
+	if buf, err := os.ReadFile(filename); err != nil {
 		return config, err
 	} else if err := toml.Unmarshal(buf, &config); err != nil {
 		return config, err
@@ -19,6 +19,16 @@ const (
 	// related but both the "http" and "http/html" packages use it so it is
 	// easier to move it to the root.
 	flashContextKey
+
+	// Marks the context as belonging to an administrator. Ownership checks
+	// are bypassed for such contexts.
+	adminContextKey
+
+	// Stores the API token authenticating the current request, if any.
+	tokenContextKey
+
+	// Stores the browser session authenticating the current request, if any.
+	sessionContextKey
 )
 
 // NewContextWithUser returns a new context with the given user.
@@ -51,3 +61,41 @@ func FlashFromContext(ctx context.Context) string {
 	v, _ := ctx.Value(flashContextKey).(string)
 	return v
 }
+
+// NewContextWithAdmin returns a new context with administrator privileges.
+// This is used by maintenance tools which act on behalf of every user.
+func NewContextWithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminContextKey, true)
+}
+
+// AdminFromContext returns true if the context has administrator privileges.
+func AdminFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(adminContextKey).(bool)
+	return v
+}
+
+// NewContextWithToken returns a new context with the API token used to
+// authenticate the request.
+func NewContextWithToken(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, tokenContextKey, token)
+}
+
+// TokenFromContext returns the API token of the current request. Returns nil
+// if the request is not authenticated by a token.
+func TokenFromContext(ctx context.Context) *Token {
+	token, _ := ctx.Value(tokenContextKey).(*Token)
+	return token
+}
+
+// NewContextWithSession returns a new context with the browser session used
+// to authenticate the request.
+func NewContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext returns the browser session of the current request.
+// Returns nil if the request is not authenticated by a session.
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionContextKey).(*Session)
+	return session
+}
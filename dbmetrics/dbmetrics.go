@@ -0,0 +1,57 @@
+// Package dbmetrics holds the metrics shared by the database implementations.
+// They are registered once here as both implementations are linked into lild.
+package dbmetrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ShortCreateCount counts the shorts created since startup.
+var ShortCreateCount = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "lil_shorts_created_total",
+	Help: "Total number of shorts created.",
+})
+
+// Metrics of the connection pool, updated by UpdateStats.
+var (
+	dbConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lil_db_connections",
+		Help: "Number of database connections by state.",
+	}, []string{"state"})
+
+	dbMaxOpenConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "lil_db_max_open_connections",
+		Help: "Maximum number of open database connections.",
+	})
+
+	dbWaitCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "lil_db_wait_count",
+		Help: "Total number of connections waited for.",
+	})
+
+	dbWaitSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "lil_db_wait_duration_seconds",
+		Help: "Total time blocked waiting for a new connection.",
+	})
+
+	dbClosedConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lil_db_closed_connections",
+		Help: "Total number of connections closed by reason.",
+	}, []string{"reason"})
+)
+
+// UpdateStats publishes the statistics of a connection pool as metrics.
+func UpdateStats(stats sql.DBStats) {
+	dbConnections.WithLabelValues("open").Set(float64(stats.OpenConnections))
+	dbConnections.WithLabelValues("in_use").Set(float64(stats.InUse))
+	dbConnections.WithLabelValues("idle").Set(float64(stats.Idle))
+	dbMaxOpenConnections.Set(float64(stats.MaxOpenConnections))
+	dbWaitCount.Set(float64(stats.WaitCount))
+	dbWaitSeconds.Set(stats.WaitDuration.Seconds())
+	dbClosedConnections.WithLabelValues("max_idle").Set(float64(stats.MaxIdleClosed))
+	dbClosedConnections.WithLabelValues("max_idle_time").Set(float64(stats.MaxIdleTimeClosed))
+	dbClosedConnections.WithLabelValues("max_lifetime").Set(float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,80 @@
+package dbtest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestAuditService_FindAuditEntries(t *testing.T, open OpenFunc) {
+	// Ensure admin actions are recorded, newest first.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		admin, adminCtx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "admin"})
+		adminCtx = lil.NewContextWithAdmin(adminCtx)
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		status := lil.ShortStatusDisabled
+		if _, err := s.UpdateShort(adminCtx, "12345", lil.ShortUpdate{Status: &status}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Maintenance tools are recorded without an actor.
+		if err := s.DeleteShort(lil.NewContextWithAdmin(context.Background()), "12345"); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, n, err := db.AuditService.FindAuditEntries(adminCtx, lil.AuditFilter{})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := entries[0].Action, lil.AuditShortDelete; got != want {
+			t.Fatalf("Action=%v, want %v", got, want)
+		} else if got, want := entries[0].ActorID, 0; got != want {
+			t.Fatalf("ActorID=%v, want %v", got, want)
+		} else if got, want := entries[1].Action, lil.AuditShortDisable; got != want {
+			t.Fatalf("Action=%v, want %v", got, want)
+		} else if got, want := entries[1].Target, "12345"; got != want {
+			t.Fatalf("Target=%v, want %v", got, want)
+		} else if entries[1].Actor == nil || entries[1].Actor.ID != admin.ID {
+			t.Fatalf("unexpected actor: %#v", entries[1].Actor)
+		}
+	})
+
+	// Ensure the actions of regular users are not recorded.
+	t.Run("NotAdmin", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+		if err := db.ShortService.DeleteShort(ctx, "12345"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, n, err := db.AuditService.FindAuditEntries(lil.NewContextWithAdmin(ctx), lil.AuditFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure only administrators can read the audit log.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		if _, _, err := db.AuditService.FindAuditEntries(ctx, lil.AuditFilter{}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
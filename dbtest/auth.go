@@ -0,0 +1,159 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestAuthService_CreateAuth(t *testing.T, open OpenFunc) {
+	// Ensure logins are not linked to users who set the email themselves.
+	// The email is given to the new user instead.
+	t.Run("UnverifiedEmail", func(t *testing.T) {
+		db := open(t)
+
+		s := db.AuthService
+		github := &lil.Auth{
+			Source:      lil.AuthSourceGitHub,
+			SourceID:    "1",
+			AccessToken: "ACCESSX",
+			User:        &lil.User{Name: "jane", Email: "jane@gmail.com"},
+		}
+		if err := s.CreateAuth(context.Background(), github); err != nil {
+			t.Fatal(err)
+		} else if !github.User.EmailVerified {
+			t.Fatal("expected provider email to be verified")
+		}
+
+		email := "susy@gmail.com"
+		ctx := lil.NewContextWithUser(context.Background(), github.User)
+		if _, err := db.UserService.UpdateUser(ctx, github.UserID, lil.UserUpdate{Email: &email}); err != nil {
+			t.Fatal(err)
+		}
+
+		google := &lil.Auth{
+			Source:      lil.AuthSourceGoogle,
+			SourceID:    "2",
+			AccessToken: "ACCESSY",
+			User:        &lil.User{Name: "susy", Email: "susy@gmail.com"},
+		}
+		if err := s.CreateAuth(context.Background(), google); err != nil {
+			t.Fatal(err)
+		} else if google.UserID == github.UserID {
+			t.Fatal("expected auths to be linked to different users")
+		} else if got, want := google.User.Email, "susy@gmail.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		}
+
+		if user, err := db.UserService.FindUserByID(ctx, github.UserID); err != nil {
+			t.Fatal(err)
+		} else if got, want := user.Email, ""; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure logging in again verifies the email if the provider reports it.
+	t.Run("VerifyEmail", func(t *testing.T) {
+		db := open(t)
+
+		s := db.AuthService
+		auth := &lil.Auth{
+			Source:      lil.AuthSourceGitHub,
+			SourceID:    "1",
+			AccessToken: "ACCESSX",
+			User:        &lil.User{Name: "susy", Email: "susy@gmail.com"},
+		}
+		if err := s.CreateAuth(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+
+		email := "susy@example.com"
+		ctx := lil.NewContextWithUser(context.Background(), auth.User)
+		if _, err := db.UserService.UpdateUser(ctx, auth.UserID, lil.UserUpdate{Email: &email}); err != nil {
+			t.Fatal(err)
+		}
+
+		again := &lil.Auth{
+			Source:      lil.AuthSourceGitHub,
+			SourceID:    "1",
+			AccessToken: "ACCESSY",
+			User:        &lil.User{Name: "susy", Email: "susy@example.com"},
+		}
+		if err := s.CreateAuth(context.Background(), again); err != nil {
+			t.Fatal(err)
+		} else if !again.User.EmailVerified {
+			t.Fatal("expected email to be verified")
+		}
+
+		if user, err := db.UserService.FindUserByID(ctx, auth.UserID); err != nil {
+			t.Fatal(err)
+		} else if !user.EmailVerified {
+			t.Fatal("expected email to be verified")
+		}
+	})
+}
+
+func TestAuthService_DeleteAuth(t *testing.T, open OpenFunc) {
+	// Ensure a login provider can be unlinked unless it is the last one.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.AuthService
+		github := &lil.Auth{
+			Source:      lil.AuthSourceGitHub,
+			SourceID:    "1",
+			AccessToken: "ACCESSX",
+			User:        &lil.User{Name: "susy", Email: "susy@gmail.com"},
+		}
+		if err := s.CreateAuth(context.Background(), github); err != nil {
+			t.Fatal(err)
+		}
+
+		google := &lil.Auth{
+			Source:      lil.AuthSourceGoogle,
+			SourceID:    "2",
+			AccessToken: "ACCESSY",
+			User:        &lil.User{Name: "susy", Email: "susy@gmail.com"},
+		}
+		if err := s.CreateAuth(context.Background(), google); err != nil {
+			t.Fatal(err)
+		} else if google.UserID != github.UserID {
+			t.Fatal("expected auths to be linked to the same user")
+		}
+
+		ctx := lil.NewContextWithUser(context.Background(), github.User)
+		if err := s.DeleteAuth(ctx, github.ID); err != nil {
+			t.Fatal(err)
+		} else if err := s.DeleteAuth(ctx, google.ID); lil.ErrorCode(err) != lil.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if _, n, err := s.FindAuths(ctx, lil.AuthFilter{UserID: &github.UserID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure the auths of other users cannot be unlinked.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		s := db.AuthService
+		auth := &lil.Auth{
+			Source:      lil.AuthSourceGitHub,
+			SourceID:    "1",
+			AccessToken: "ACCESSX",
+			User:        &lil.User{Name: "susy"},
+		}
+		if err := s.CreateAuth(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+		if err := s.DeleteAuth(ctx, auth.ID); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
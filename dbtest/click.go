@@ -0,0 +1,88 @@
+package dbtest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+func TestClickService_FindClickStats(t *testing.T, open OpenFunc) {
+	// Ensure recorded clicks are aggregated in total and per day.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 12, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ClickService
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		MustRecordClick(t, s, &lil.Click{ShortKey: "12345", IPHash: "a"})
+		MustRecordClick(t, s, &lil.Click{ShortKey: "12345", IPHash: "a"})
+		MustRecordClick(t, s, &lil.Click{ShortKey: "12345", IPHash: "b"})
+
+		now = now.Add(24 * time.Hour)
+		MustRecordClick(t, s, &lil.Click{ShortKey: "12345", IPHash: "a"})
+
+		// Clicks on unknown shorts are discarded.
+		MustRecordClick(t, s, &lil.Click{ShortKey: "00000", IPHash: "a"})
+
+		// Closing flushes pending clicks.
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := s.FindClickStats(ctx, "12345")
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := stats.Total, 4; got != want {
+			t.Fatalf("Total=%v, want %v", got, want)
+		} else if got, want := stats.Unique, 2; got != want {
+			t.Fatalf("Unique=%v, want %v", got, want)
+		} else if got, want := len(stats.Days), 2; got != want {
+			t.Fatalf("len(Days)=%v, want %v", got, want)
+		} else if got, want := stats.Days[0].Date, time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+			t.Fatalf("Date=%v, want %v", got, want)
+		} else if got, want := stats.Days[0].Total, 3; got != want {
+			t.Fatalf("Days[0].Total=%v, want %v", got, want)
+		} else if got, want := stats.Days[0].Unique, 2; got != want {
+			t.Fatalf("Days[0].Unique=%v, want %v", got, want)
+		} else if got, want := stats.Days[1].Total, 1; got != want {
+			t.Fatalf("Days[1].Total=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure stats are only available to the owner.
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ClickService
+		if _, err := s.FindClickStats(ctx1, "12345"); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// MustRecordClick records a click. Fatal on error.
+func MustRecordClick(tb testing.TB, s ClickService, click *lil.Click) {
+	tb.Helper()
+	if err := s.RecordClick(context.Background(), click); err != nil {
+		tb.Fatal(err)
+	}
+}
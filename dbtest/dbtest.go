@@ -0,0 +1,96 @@
+// Package dbtest implements the tests shared by the database implementations
+// so that every implementation is held to the same behavior.
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+// DB represents the services of a database implementation under test.
+type DB struct {
+	AuditService   lil.AuditService
+	AuthService    lil.AuthService
+	ClickService   ClickService
+	SessionService lil.SessionService
+	ShortService   lil.ShortService
+	TeamService    lil.TeamService
+	TokenService   lil.TokenService
+	UserService    lil.UserService
+
+	// Replaces the function returning the current time of the database.
+	SetNow func(now func() time.Time)
+
+	// Removes the shorts which expired before the grace period, archiving
+	// them if archive is set. Returns the number of removed shorts.
+	SweepShorts func(ctx context.Context, grace time.Duration, archive bool) (int, error)
+
+	// Returns the shorts moved to the archive by SweepShorts, ordered by key,
+	// along with the number of archived clicks.
+	FindArchivedShorts func(ctx context.Context) ([]*ArchivedShort, error)
+}
+
+// ArchivedShort represents a short moved to the archive by SweepShorts.
+type ArchivedShort struct {
+	lil.Short
+	ArchivedAt time.Time
+	Clicks     int
+}
+
+// ClickService represents a service recording clicks in the background.
+type ClickService interface {
+	lil.ClickService
+
+	// Starts recording clicks.
+	Open() error
+
+	// Flushes pending clicks & stops recording.
+	Close() error
+}
+
+// OpenFunc returns a new, empty database which is closed at the end of the
+// test. Fatal on error.
+type OpenFunc func(tb testing.TB) *DB
+
+// Run runs every shared test against the databases returned by open.
+func Run(t *testing.T, open OpenFunc) {
+	for _, tt := range []struct {
+		name string
+		fn   func(*testing.T, OpenFunc)
+	}{
+		{"AuditService_FindAuditEntries", TestAuditService_FindAuditEntries},
+		{"AuthService_CreateAuth", TestAuthService_CreateAuth},
+		{"AuthService_DeleteAuth", TestAuthService_DeleteAuth},
+		{"ClickService_FindClickStats", TestClickService_FindClickStats},
+		{"SessionService_CreateSession", TestSessionService_CreateSession},
+		{"SessionService_RevokeSession", TestSessionService_RevokeSession},
+		{"SessionService_RevokeUserSessions", TestSessionService_RevokeUserSessions},
+		{"ShortService_CreateShort", TestShortService_CreateShort},
+		{"ShortsService_DeleteShorts", TestShortsService_DeleteShorts},
+		{"ShortService_UpdateShort", TestShortService_UpdateShort},
+		{"ShortService_FindShorts", TestShortService_FindShorts},
+		{"ShortService_ForEachShort", TestShortService_ForEachShort},
+		{"ShortService_SearchShort", TestShortService_SearchShort},
+		{"DB_SweepShorts", TestDB_SweepShorts},
+		{"TeamService_CreateTeam", TestTeamService_CreateTeam},
+		{"TeamService_FindTeams", TestTeamService_FindTeams},
+		{"TeamService_SetTeamMember", TestTeamService_SetTeamMember},
+		{"TeamService_RemoveTeamMember", TestTeamService_RemoveTeamMember},
+		{"TeamService_DeleteTeam", TestTeamService_DeleteTeam},
+		{"TokenService_CreateToken", TestTokenService_CreateToken},
+		{"TokenService_FindTokenBySecret", TestTokenService_FindTokenBySecret},
+		{"TokenService_RevokeToken", TestTokenService_RevokeToken},
+		{"UserService_CreateUser", TestUserService_CreateUser},
+		{"UserService_UpdateUser", TestUserService_UpdateUser},
+		{"UserService_DeleteUser", TestUserService_DeleteUser},
+		{"UserService_RotateAPIKey", TestUserService_RotateAPIKey},
+		{"UserService_FindUser", TestUserService_FindUser},
+		{"UserService_FindUsers", TestUserService_FindUsers},
+	} {
+		fn := tt.fn
+		t.Run(tt.name, func(t *testing.T) { fn(t, open) })
+	}
+}
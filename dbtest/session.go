@@ -0,0 +1,126 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+func TestSessionService_CreateSession(t *testing.T, open OpenFunc) {
+	// Ensure a session can be created and later found by its secret.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.SessionService
+		user, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		session := &lil.Session{UserID: user.ID, IPAddress: "127.0.0.1", UserAgent: "curl"}
+		if err := s.CreateSession(context.Background(), session); err != nil {
+			t.Fatal(err)
+		} else if session.ID == 0 || session.Secret == "" {
+			t.Fatalf("unexpected session: %#v", session)
+		}
+
+		if other, err := s.FindSessionBySecret(context.Background(), session.Secret); err != nil {
+			t.Fatal(err)
+		} else if other.ID != session.ID || other.User == nil || other.User.ID != user.ID {
+			t.Fatalf("unexpected session: %#v", other)
+		} else if got, want := other.UserAgent, "curl"; got != want {
+			t.Fatalf("UserAgent=%v, want %v", got, want)
+		} else if other.CSRFToken == "" || other.CSRFToken != session.CSRFToken {
+			t.Fatalf("unexpected csrf token: %q", other.CSRFToken)
+		}
+
+		if sessions, n, err := s.FindSessions(ctx, lil.SessionFilter{}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || sessions[0].ID != session.ID {
+			t.Fatalf("unexpected sessions: %#v", sessions)
+		}
+	})
+
+	// Ensure idle sessions expire unless they are used.
+	t.Run("Expiry", func(t *testing.T) {
+		db := open(t)
+
+		s := db.SessionService
+		user, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		now := time.Now()
+		db.SetNow(func() time.Time { return now })
+
+		session := &lil.Session{UserID: user.ID}
+		if err := s.CreateSession(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(lil.SessionIdleTimeout - time.Hour)
+		if err := s.TouchSession(context.Background(), session.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(lil.SessionIdleTimeout - time.Hour)
+		if _, err := s.FindSessionBySecret(context.Background(), session.Secret); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(2 * time.Hour)
+		if _, err := s.FindSessionBySecret(context.Background(), session.Secret); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestSessionService_RevokeSession(t *testing.T, open OpenFunc) {
+	// Ensure a session can only be revoked by its user.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.SessionService
+		user, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+
+		session := &lil.Session{UserID: user.ID}
+		if err := s.CreateSession(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.RevokeSession(ctx1, session.ID); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.RevokeSession(ctx0, session.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindSessionBySecret(context.Background(), session.Secret); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestSessionService_RevokeUserSessions(t *testing.T, open OpenFunc) {
+	// Ensure administrators can log a user out of every browser.
+	t.Run("Admin", func(t *testing.T) {
+		db := open(t)
+
+		s := db.SessionService
+		user, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		_, otherCtx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+
+		for i := 0; i < 2; i++ {
+			if err := s.CreateSession(context.Background(), &lil.Session{UserID: user.ID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.RevokeUserSessions(otherCtx, user.ID); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.RevokeUserSessions(lil.NewContextWithAdmin(context.Background()), user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, n, err := s.FindSessions(ctx, lil.SessionFilter{}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("n=%v, want 0", n)
+		}
+	})
+}
@@ -0,0 +1,820 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+func TestShortService_CreateShort(t *testing.T, open OpenFunc) {
+	// Ensure a short can be created.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+
+		u, _ := url.Parse("https://example.com")
+		key := "12345"
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		short := &lil.Short{URL: *u, Key: key}
+		if err := s.CreateShort(ctx, short); err != nil {
+			t.Fatal(err)
+		} else if short.CreatedAt.IsZero() {
+			t.Fatal("expected CreatedAt")
+		} else if short.UpdatedAt.IsZero() {
+			t.Fatal("expected UpdatedAt")
+		} else if short.Owner == nil {
+			t.Fatal("expected owner")
+		}
+
+		// Fetch short from database and compare.
+		if other, err := s.FindShortByKey(ctx, key); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(short, other) {
+			t.Fatalf("mismatch: %#v != %#v", short, other)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+		url, _ := url.Parse("https://google.com")
+		if err := s.CreateShort(context.Background(), &lil.Short{
+			URL: *url,
+			Key: "12345",
+		}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED || lil.ErrorMessage(err) != "You must be logged in to create a short." {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrMissingURL", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		short := &lil.Short{}
+		if err := s.CreateShort(ctx, short); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != "Missing URL." {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrMissingKey", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+
+		u, _ := url.Parse("https://example.com")
+		short := &lil.Short{URL: *u}
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		if err := s.CreateShort(ctx, short); err == nil {
+			t.Fatal("expected err")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != "Missing Key." {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrDuplicateShort", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		u, _ := url.Parse("https://example.com")
+		short := &lil.Short{URL: *u, Key: "12345"}
+
+		MustCreateShort(t, ctx, db, short)
+
+		if err := s.CreateShort(ctx, short); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.ECONFLICT || lil.ErrorMessage(err) != "Short with the same key already exists." {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrInvalidURLScheme", func(t *testing.T) {
+		db := open(t)
+
+		s := db.ShortService
+
+		u, err := url.ParseRequestURI("invalid-scheme://www.google.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		if err := s.CreateShort(ctx, &lil.Short{
+			URL: *u,
+			Key: "12345",
+		}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != "Invalid URL scheme. Only http and https are supported." {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestShortsService_DeleteShorts(t *testing.T, open OpenFunc) {
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		url, _ := url.Parse("https://1.example.com")
+		key := "12345"
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *url, Key: key})
+
+		s := db.ShortService
+		if err := s.DeleteShort(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.FindShortByKey(ctx, key); err == nil {
+			t.Fatalf("expected err")
+		} else if lil.ErrorCode(err) != lil.ENOTFOUND || lil.ErrorMessage(err) != "Short not found." {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		_, ctx2 := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "unauthorized",
+			Email: "unauthorized",
+		})
+
+		url, _ := url.Parse("https://1.example.com")
+		key := "12345"
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *url, Key: key})
+
+		s := db.ShortService
+		if err := s.DeleteShort(ctx2, key); err == nil {
+			t.Fatal("expected err")
+		} else if lil.ErrorCode(err) != lil.ENOTFOUND || lil.ErrorMessage(err) != "Short not found." {
+			t.Fatal(err)
+		}
+
+	})
+}
+
+func TestShortService_UpdateShort(t *testing.T, open OpenFunc) {
+	// Ensure a short can be retargeted by its owner.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		db.SetNow(func() time.Time { return now.Add(time.Hour) })
+
+		s := db.ShortService
+		newURL, _ := url.Parse("https://2.example.com")
+		short, err := s.UpdateShort(ctx, "12345", lil.ShortUpdate{URL: newURL})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := short.URL.String(), "https://2.example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		} else if got, want := short.UpdatedAt, now.Add(time.Hour); !got.Equal(want) {
+			t.Fatalf("UpdatedAt=%v, want %v", got, want)
+		}
+
+		// Fetch short from database & compare.
+		if other, err := s.FindShortByKey(ctx, "12345"); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(short, other) {
+			t.Fatalf("mismatch: %#v != %#v", short, other)
+		}
+	})
+
+	// Ensure an invalid URL cannot be set.
+	t.Run("ErrInvalidURLScheme", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		newURL, _ := url.Parse("ftp://2.example.com")
+		if _, err := s.UpdateShort(ctx, "12345", lil.ShortUpdate{URL: newURL}); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure another user cannot update the short.
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		newURL, _ := url.Parse("https://2.example.com")
+		if _, err := s.UpdateShort(ctx1, "12345", lil.ShortUpdate{URL: newURL}); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure team editors can update the team shorts but viewers cannot.
+	t.Run("TeamRoles", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+		user2, ctx2 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME2"})
+
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleEditor)
+		MustSetTeamMember(t, ctx0, db, team.ID, user2.ID, lil.TeamRoleViewer)
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: "12345", TeamID: &team.ID})
+
+		s := db.ShortService
+		newURL, _ := url.Parse("https://2.example.com")
+		if short, err := s.UpdateShort(ctx1, "12345", lil.ShortUpdate{URL: newURL}); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.URL.String(), "https://2.example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		if _, err := s.UpdateShort(ctx2, "12345", lil.ShortUpdate{URL: newURL}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.DeleteShort(ctx2, "12345"); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure owners can change the status of their shorts until an
+	// administrator moderates it.
+	t.Run("Status", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		flagged, disabled, active := lil.ShortStatusFlagged, lil.ShortStatusDisabled, lil.ShortStatusActive
+		if short, err := s.UpdateShort(ctx, "12345", lil.ShortUpdate{Status: &flagged}); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.Status, lil.ShortStatusFlagged; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if short.ModeratedAt != nil {
+			t.Fatal("unexpected ModeratedAt")
+		}
+
+		if short, err := s.UpdateShort(lil.NewContextWithAdmin(ctx), "12345", lil.ShortUpdate{Status: &disabled}); err != nil {
+			t.Fatal(err)
+		} else if short.ModeratedAt == nil {
+			t.Fatal("expected ModeratedAt")
+		}
+
+		// Moderated shorts can no longer be changed nor deleted by owners.
+		newURL, _ := url.Parse("https://2.example.com")
+		if _, err := s.UpdateShort(ctx, "12345", lil.ShortUpdate{Status: &active}); err != lil.ErrShortModerated {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.UpdateShort(ctx, "12345", lil.ShortUpdate{URL: newURL}); err != lil.ErrShortModerated {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.DeleteShort(ctx, "12345"); err != lil.ErrShortModerated {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.SearchShort(context.Background(), "12345"); err != lil.ErrShortDisabled {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if a, n, err := s.FindShorts(ctx, lil.ShortFilter{Status: &disabled}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "12345"; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure an unknown status is rejected.
+	t.Run("ErrInvalidStatus", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		status := "deleted"
+		if _, err := db.ShortService.UpdateShort(ctx, "12345", lil.ShortUpdate{Status: &status}); err != lil.ErrInvalidStatus {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure a short cannot be moved to a team the user cannot edit.
+	t.Run("ErrTeamUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleViewer)
+
+		u, _ := url.Parse("https://1.example.com")
+		MustCreateShort(t, ctx1, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		if _, err := s.UpdateShort(ctx1, "12345", lil.ShortUpdate{TeamID: &team.ID}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED || lil.ErrorMessage(err) != "You are not allowed to add shorts to this team." {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestShortService_FindShorts(t *testing.T, open OpenFunc) {
+	t.Run("Key", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		url1, _ := url.Parse("https://1.example.com")
+		key1 := "12345"
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *url1, Key: key1})
+
+		url2, _ := url.Parse("https://2.example.com")
+		key2 := "23456"
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *url2, Key: key2})
+
+		url3, _ := url.Parse("https://3.example.com")
+		key3 := "34567"
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *url3, Key: key3})
+
+		s := db.ShortService
+
+		if a, n, err := s.FindShorts(ctx, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 3; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := a[0].Key, key1; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if got, want := a[1].Key, key2; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if got, want := a[2].Key, key3; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if got, want := n, 3; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("OnlyOwnedShorts", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test",
+			Email: "Test",
+		})
+
+		_, ctx2 := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:  "Test1",
+			Email: "Test1",
+		})
+
+		url1, _ := url.Parse("https://1.example.com")
+		key1 := "12345"
+		MustCreateShort(t, ctx1, db, &lil.Short{URL: *url1, Key: key1})
+
+		url2, _ := url.Parse("https://2.example.com")
+		key2 := "23456"
+		MustCreateShort(t, ctx2, db, &lil.Short{URL: *url2, Key: key2})
+
+		s := db.ShortService
+
+		if a, n, err := s.FindShorts(ctx1, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := a[0].Key, key1; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if b, n, err := s.FindShorts(ctx2, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(b), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := b[0].Key, key2; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure administrators can list the shorts of every user and filter by owner.
+	t.Run("Admin", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		user2, ctx2 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx1, db, &lil.Short{URL: *u, Key: "12345"})
+		MustCreateShort(t, ctx2, db, &lil.Short{URL: *u, Key: "23456"})
+
+		s := db.ShortService
+		ctx := lil.NewContextWithAdmin(context.Background())
+
+		if _, n, err := s.FindShorts(ctx, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if a, n, err := s.FindShorts(ctx, lil.ShortFilter{OwnerID: &user2.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "23456"; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		}
+
+		if err := s.DeleteShort(ctx, "23456"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure shorts can be searched by destination host.
+	t.Run("Host", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		for key, rawURL := range map[string]string{
+			"aaa": "https://example.com",
+			"bbb": "https://EXAMPLE.com:8080/path",
+			"ccc": "http://example.com?q=1",
+			"ddd": "https://example.com.evil.net/",
+			"eee": "https://sub.example.com/",
+			"fff": "https://evil.net/?next=https://example.com",
+			"ggg": "https://evil.net/https://example.com/",
+			"hhh": "https://user@example.com/",
+		} {
+			u, _ := url.Parse(rawURL)
+			MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: key})
+		}
+
+		host := "example.com"
+		if a, n, err := db.ShortService.FindShorts(ctx, lil.ShortFilter{Host: &host}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 4; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else {
+			for _, short := range a {
+				if short.Key != "aaa" && short.Key != "bbb" && short.Key != "ccc" && short.Key != "hhh" {
+					t.Fatalf("unexpected short: %s", short.Key)
+				}
+			}
+		}
+
+		// The host follows updates of the URL.
+		u, _ := url.Parse("https://example.com/moved")
+		if _, err := db.ShortService.UpdateShort(ctx, "eee", lil.ShortUpdate{URL: u}); err != nil {
+			t.Fatal(err)
+		} else if _, n, err := db.ShortService.FindShorts(ctx, lil.ShortFilter{Host: &host}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 5; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure team members can list the shorts of their teams.
+	t.Run("Team", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+		_, ctx2 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "mary"})
+
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleViewer)
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: "12345", TeamID: &team.ID})
+		MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: "23456"})
+		MustCreateShort(t, ctx1, db, &lil.Short{URL: *u, Key: "34567"})
+
+		s := db.ShortService
+		if a, n, err := s.FindShorts(ctx1, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "12345"; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		} else if a[0].Team == nil || a[0].Team.Name != "marketing" {
+			t.Fatalf("expected team: %#v", a[0].Team)
+		}
+
+		if a, n, err := s.FindShorts(ctx1, lil.ShortFilter{TeamID: &team.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "12345"; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		}
+
+		// Users outside the team cannot see its shorts.
+		if _, n, err := s.FindShorts(ctx2, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestShortService_ForEachShort(t *testing.T, open OpenFunc) {
+	// Ensure every owned short is visited in key order, across pages.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		u, _ := url.Parse("https://example.com")
+		const n = 1201
+		for i := n - 1; i >= 0; i-- {
+			MustCreateShort(t, ctx0, db, &lil.Short{URL: *u, Key: fmt.Sprintf("k%04d", i)})
+		}
+		MustCreateShort(t, ctx1, db, &lil.Short{URL: *u, Key: "k0000a"})
+
+		var keys []string
+		if err := db.ShortService.ForEachShort(ctx0, lil.ShortFilter{Limit: 1}, func(short *lil.Short) error {
+			keys = append(keys, short.Key)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(keys), n; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+		for i, key := range keys {
+			if want := fmt.Sprintf("k%04d", i); key != want {
+				t.Fatalf("keys[%d]=%v, want %v", i, key, want)
+			}
+		}
+	})
+
+	// Ensure iteration starts after the given key & stops at the first error.
+	t.Run("After", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+
+		u, _ := url.Parse("https://example.com")
+		for _, key := range []string{"ccc", "aaa", "bbb"} {
+			MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: key})
+		}
+
+		after, errStop := "aaa", errors.New("stop")
+		var keys []string
+		if err := db.ShortService.ForEachShort(ctx, lil.ShortFilter{After: &after}, func(short *lil.Short) error {
+			if keys = append(keys, short.Key); len(keys) == 1 {
+				return errStop
+			}
+			return nil
+		}); err != errStop {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if !reflect.DeepEqual(keys, []string{"bbb"}) {
+			t.Fatalf("keys=%v", keys)
+		} else if got, want := after, "aaa"; got != want {
+			t.Fatalf("after=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestShortService_SearchShort(t *testing.T, open OpenFunc) {
+	// Ensure a short can be searched by another user.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345"})
+
+		s := db.ShortService
+		if short, err := s.SearchShort(context.Background(), "12345"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.URL.String(), "https://example.com"; got != want {
+			t.Fatalf("url=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure an expired short cannot be followed.
+	t.Run("ErrExpired", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		expiresAt := now.Add(time.Hour)
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345", ExpiresAt: &expiresAt})
+
+		db.SetNow(func() time.Time { return expiresAt })
+
+		s := db.ShortService
+		if _, err := s.SearchShort(context.Background(), "12345"); lil.ErrorCode(err) != lil.EEXPIRED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure a short cannot be followed before its activation.
+	t.Run("ErrNotActive", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		activatesAt := now.Add(time.Hour)
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345", ActivatesAt: &activatesAt})
+
+		s := db.ShortService
+		if _, err := s.SearchShort(context.Background(), "12345"); lil.ErrorCode(err) != lil.ENOTACTIVE {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		db.SetNow(func() time.Time { return activatesAt })
+		if _, err := s.SearchShort(context.Background(), "12345"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestDB_SweepShorts(t *testing.T, open OpenFunc) {
+	// Ensure only shorts expired before the grace period are removed.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		user, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		expiresAt1, expiresAt2 := now.Add(time.Hour), now.Add(3*time.Hour)
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345", ExpiresAt: &expiresAt1})
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "23456", ExpiresAt: &expiresAt2})
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "34567"})
+
+		if err := db.ClickService.Open(); err != nil {
+			t.Fatal(err)
+		}
+		MustRecordClick(t, db.ClickService, &lil.Click{ShortKey: "12345", IPHash: "a"})
+		MustRecordClick(t, db.ClickService, &lil.Click{ShortKey: "12345", IPHash: "b"})
+		MustRecordClick(t, db.ClickService, &lil.Click{ShortKey: "23456", IPHash: "a"})
+		if err := db.ClickService.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		sweptAt := now.Add(4 * time.Hour)
+		db.SetNow(func() time.Time { return sweptAt })
+
+		if n, err := db.SweepShorts(context.Background(), 2*time.Hour, true); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		s := db.ShortService
+		if a, _, err := s.FindShorts(ctx, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 2; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "23456"; got != want {
+			t.Fatalf("key=%v, want %v", got, want)
+		}
+
+		// The removed short is archived as it was, along with the sweep time.
+		if a, err := db.FindArchivedShorts(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := a[0].Key, "12345"; got != want {
+			t.Fatalf("Key=%v, want %v", got, want)
+		} else if got, want := a[0].URL.String(), "https://example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		} else if got, want := a[0].OwnerID, user.ID; got != want {
+			t.Fatalf("OwnerID=%v, want %v", got, want)
+		} else if a[0].TeamID != nil {
+			t.Fatalf("unexpected TeamID: %v", *a[0].TeamID)
+		} else if a[0].ExpiresAt == nil || !a[0].ExpiresAt.Equal(expiresAt1) {
+			t.Fatalf("ExpiresAt=%v, want %v", a[0].ExpiresAt, expiresAt1)
+		} else if got, want := a[0].Status, lil.ShortStatusActive; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if !a[0].CreatedAt.Equal(now) {
+			t.Fatalf("CreatedAt=%v, want %v", a[0].CreatedAt, now)
+		} else if !a[0].ArchivedAt.Equal(sweptAt) {
+			t.Fatalf("ArchivedAt=%v, want %v", a[0].ArchivedAt, sweptAt)
+		} else if got, want := a[0].Clicks, 2; got != want {
+			t.Fatalf("Clicks=%v, want %v", got, want)
+		}
+
+		// The clicks of the remaining shorts are untouched.
+		if stats, err := db.ClickService.FindClickStats(ctx, "23456"); err != nil {
+			t.Fatal(err)
+		} else if got, want := stats.Total, 1; got != want {
+			t.Fatalf("Total=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure expired shorts are discarded unless archiving is enabled.
+	t.Run("NoArchive", func(t *testing.T) {
+		db := open(t)
+
+		now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db.SetNow(func() time.Time { return now })
+
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Test"})
+
+		u, _ := url.Parse("https://example.com")
+		expiresAt := now.Add(time.Hour)
+		MustCreateShort(t, ctx, db, &lil.Short{URL: *u, Key: "12345", ExpiresAt: &expiresAt})
+
+		db.SetNow(func() time.Time { return now.Add(4 * time.Hour) })
+
+		if n, err := db.SweepShorts(context.Background(), 2*time.Hour, false); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if a, err := db.FindArchivedShorts(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 0; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+	})
+}
+
+func MustCreateShort(tb testing.TB, ctx context.Context, db *DB, short *lil.Short) *lil.Short {
+	tb.Helper()
+	if err := db.ShortService.CreateShort(ctx, short); err != nil {
+		tb.Fatal(err)
+	}
+	return short
+}
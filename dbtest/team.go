@@ -0,0 +1,219 @@
+package dbtest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestTeamService_CreateTeam(t *testing.T, open OpenFunc) {
+	// Ensure a team can be created and its creator becomes the owner.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		user, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+
+		team := &lil.Team{Name: "marketing"}
+		if err := s.CreateTeam(ctx, team); err != nil {
+			t.Fatal(err)
+		} else if team.ID == 0 {
+			t.Fatal("expected ID")
+		} else if got, want := team.MemberRole(user.ID), lil.TeamRoleOwner; got != want {
+			t.Fatalf("role=%q, want %q", got, want)
+		}
+
+		if other, err := s.FindTeamByID(ctx, team.ID); err != nil {
+			t.Fatal(err)
+		} else if other.Name != "marketing" || len(other.Members) != 1 {
+			t.Fatalf("unexpected team: %#v", other)
+		} else if other.Members[0].User == nil || other.Members[0].User.ID != user.ID {
+			t.Fatal("expected member user")
+		}
+	})
+
+	// Ensure an error is returned if the team name is missing.
+	t.Run("ErrNameRequired", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		if err := s.CreateTeam(ctx, &lil.Team{}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != "Team name required." {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure anonymous users cannot create teams.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		if err := s.CreateTeam(context.Background(), &lil.Team{Name: "marketing"}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestTeamService_FindTeams(t *testing.T, open OpenFunc) {
+	// Ensure only the teams of the current user are returned.
+	t.Run("Membership", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Bob", Email: "bob@example.com"})
+
+		MustCreateTeam(t, ctx0, db, &lil.Team{Name: "a"})
+		MustCreateTeam(t, ctx1, db, &lil.Team{Name: "b"})
+
+		if teams, n, err := s.FindTeams(ctx0, lil.TeamFilter{}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || len(teams) != 1 || teams[0].Name != "a" {
+			t.Fatalf("unexpected teams: n=%d %#v", n, teams)
+		}
+
+		// Teams of other users are not visible.
+		if _, err := s.FindTeamByID(ctx1, 1); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTeamService_SetTeamMember(t *testing.T, open OpenFunc) {
+	// Ensure owners can add members and change their role.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Bob", Email: "bob@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+
+		if m, err := s.SetTeamMember(ctx0, team.ID, user1.ID, lil.TeamRoleViewer); err != nil {
+			t.Fatal(err)
+		} else if m.Role != lil.TeamRoleViewer {
+			t.Fatalf("unexpected role: %q", m.Role)
+		}
+
+		// The new member can now see the team, but cannot manage it.
+		if _, err := s.FindTeamByID(ctx1, team.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.SetTeamMember(ctx1, team.ID, user1.ID, lil.TeamRoleOwner); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if m, err := s.SetTeamMember(ctx0, team.ID, user1.ID, lil.TeamRoleEditor); err != nil {
+			t.Fatal(err)
+		} else if m.Role != lil.TeamRoleEditor {
+			t.Fatalf("unexpected role: %q", m.Role)
+		}
+	})
+
+	// Ensure the last owner cannot be demoted.
+	t.Run("ErrLastOwner", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+
+		if _, err := s.SetTeamMember(ctx0, team.ID, user0.ID, lil.TeamRoleViewer); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != "A team needs at least one owner." {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure an invalid role is rejected.
+	t.Run("ErrInvalidRole", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		user1, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Bob", Email: "bob@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+
+		if _, err := s.SetTeamMember(ctx0, team.ID, user1.ID, "admin"); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTeamService_RemoveTeamMember(t *testing.T, open OpenFunc) {
+	// Ensure members can leave a team by themselves.
+	t.Run("Leave", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Bob", Email: "bob@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleEditor)
+
+		if err := s.RemoveTeamMember(ctx1, team.ID, user1.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindTeamByID(ctx1, team.ID); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTeamService_DeleteTeam(t *testing.T, open OpenFunc) {
+	// Ensure deleting a team gives its shorts back to their owners.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{Key: "abc", URL: *u, TeamID: &team.ID})
+
+		if err := s.DeleteTeam(ctx0, team.ID); err != nil {
+			t.Fatal(err)
+		} else if short, err := db.ShortService.FindShortByKey(ctx0, "abc"); err != nil {
+			t.Fatal(err)
+		} else if short.TeamID != nil || short.Team != nil {
+			t.Fatalf("expected personal short: %#v", short)
+		}
+	})
+
+	// Ensure only owners can delete a team.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TeamService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Alice", Email: "alice@example.com"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "Bob", Email: "bob@example.com"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleEditor)
+
+		if err := s.DeleteTeam(ctx1, team.ID); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func MustCreateTeam(tb testing.TB, ctx context.Context, db *DB, team *lil.Team) *lil.Team {
+	tb.Helper()
+	if err := db.TeamService.CreateTeam(ctx, team); err != nil {
+		tb.Fatal(err)
+	}
+	return team
+}
+
+func MustSetTeamMember(tb testing.TB, ctx context.Context, db *DB, teamID, userID int, role string) *lil.TeamMember {
+	tb.Helper()
+	m, err := db.TeamService.SetTeamMember(ctx, teamID, userID, role)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
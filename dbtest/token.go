@@ -0,0 +1,128 @@
+package dbtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+func TestTokenService_CreateToken(t *testing.T, open OpenFunc) {
+	// Ensure a token can be created and later found by its secret.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TokenService
+		user, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		token := &lil.Token{Name: "ci", Scopes: []string{lil.ScopeShortsRead, lil.ScopeShortsWrite}}
+		if err := s.CreateToken(ctx, token); err != nil {
+			t.Fatal(err)
+		} else if token.ID == 0 {
+			t.Fatal("expected ID")
+		} else if !strings.HasPrefix(token.Secret, lil.TokenPrefix) {
+			t.Fatalf("unexpected secret: %q", token.Secret)
+		}
+
+		if other, err := s.FindTokenBySecret(context.Background(), token.Secret); err != nil {
+			t.Fatal(err)
+		} else if other.ID != token.ID || other.User == nil || other.User.ID != user.ID {
+			t.Fatalf("unexpected token: %#v", other)
+		} else if other.Secret != "" {
+			t.Fatal("expected secret not to be returned")
+		} else if !other.HasScope(lil.ScopeShortsWrite) || other.HasScope(lil.ScopeAdmin) {
+			t.Fatalf("unexpected scopes: %v", other.Scopes)
+		}
+	})
+
+	// Ensure unknown scopes are rejected.
+	t.Run("ErrInvalidScope", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TokenService
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		if err := s.CreateToken(ctx, &lil.Token{Name: "ci", Scopes: []string{"everything"}}); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure a token cannot grant scopes it has not been granted itself.
+	t.Run("ErrScopeEscalation", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TokenService
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		token := &lil.Token{Name: "ci", Scopes: []string{lil.ScopeUserWrite}}
+		if err := s.CreateToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx = lil.NewContextWithToken(ctx, token)
+		if err := s.CreateToken(ctx, &lil.Token{Name: "more", Scopes: []string{lil.ScopeAdmin}}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestTokenService_FindTokenBySecret(t *testing.T, open OpenFunc) {
+	// Ensure expired tokens cannot be used.
+	t.Run("ErrExpired", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TokenService
+		_, ctx := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		expiresAt := time.Now().Add(time.Hour)
+		token := &lil.Token{Name: "ci", Scopes: []string{lil.ScopeShortsRead}, ExpiresAt: &expiresAt}
+		if err := s.CreateToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		db.SetNow(func() time.Time { return expiresAt.Add(time.Second) })
+		if _, err := s.FindTokenBySecret(context.Background(), token.Secret); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure unknown secrets are rejected.
+	t.Run("ErrUnknown", func(t *testing.T) {
+		db := open(t)
+
+		if _, err := db.TokenService.FindTokenBySecret(context.Background(), lil.TokenPrefix+"nope"); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestTokenService_RevokeToken(t *testing.T, open OpenFunc) {
+	// Ensure a token can be revoked by its user only.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+
+		s := db.TokenService
+		_, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "jane"})
+
+		token := &lil.Token{Name: "ci", Scopes: []string{lil.ScopeShortsRead}}
+		if err := s.CreateToken(ctx0, token); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.RevokeToken(ctx1, token.ID); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.RevokeToken(ctx0, token.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.FindTokenBySecret(context.Background(), token.Secret); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, n, err := s.FindTokens(ctx0, lil.TokenFilter{}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("n=%v, want 0", n)
+		}
+	})
+}
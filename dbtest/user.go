@@ -0,0 +1,387 @@
+package dbtest
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestUserService_CreateUser(t *testing.T, open OpenFunc) {
+	// Ensure user can be created.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+
+		u := &lil.User{
+			Name:  "susy",
+			Email: "susy@gmail.com",
+		}
+
+		// Create new user & verify ID and timestamps are set.
+		if err := s.CreateUser(context.Background(), u); err != nil {
+			t.Fatal(err)
+		} else if got, want := u.ID, 1; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		} else if u.CreatedAt.IsZero() {
+			t.Fatal("expected created at")
+		} else if u.UpdatedAt.IsZero() {
+			t.Fatal("expected updated at")
+		}
+
+		// Create second user with email.
+		u2 := &lil.User{Name: "jane"}
+		if err := s.CreateUser(context.Background(), u2); err != nil {
+			t.Fatal(err)
+		} else if got, want := u2.ID, 2; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		}
+
+		// Fetch user from database & compare. The API key is only returned on
+		// creation as only its hash is stored.
+		if u.APIKey == "" {
+			t.Fatal("expected api key")
+		}
+		u.APIKey = ""
+		if other, err := s.FindUserByID(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(u, other) {
+			t.Fatalf("mismatch: %#v != %#v", u, other)
+		}
+	})
+
+	// Ensure an error is returned if user name is not set.
+	t.Run("ErrNameRequired", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		if err := s.CreateUser(context.Background(), &lil.User{}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EINVALID || lil.ErrorMessage(err) != `User name required.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_UpdateUser(t *testing.T, open OpenFunc) {
+	// Ensure user name & email can be updated by current user.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{
+			Name:          "susy",
+			Email:         "susy@gmail.com",
+			EmailVerified: true,
+		})
+
+		// Update user. The new email has not been verified.
+		newName, newEmail := "jill", "jill@gmail.com"
+		uu, err := s.UpdateUser(ctx0, user0.ID, lil.UserUpdate{
+			Name:  &newName,
+			Email: &newEmail,
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := uu.Name, "jill"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		} else if got, want := uu.Email, "jill@gmail.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if uu.EmailVerified {
+			t.Fatal("expected email to be unverified")
+		}
+
+		// Fetch user from database & compare.
+		if other, err := s.FindUserByID(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(uu, other) {
+			t.Fatalf("mismatch: %#v != %#v", uu, other)
+		}
+	})
+
+	// Ensure updating a user is restricted only to the current user.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		// Update user as another user.
+		newName := "NEWNAME"
+		if _, err := s.UpdateUser(ctx1, user0.ID, lil.UserUpdate{Name: &newName}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED || lil.ErrorMessage(err) != `You are not allowed to update this user.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure administrators can suspend & reinstate a user.
+	t.Run("Suspend", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		ctx := lil.NewContextWithAdmin(context.Background())
+
+		suspended := true
+		if _, err := s.UpdateUser(ctx0, user0.ID, lil.UserUpdate{Suspended: &suspended}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if uu, err := s.UpdateUser(ctx, user0.ID, lil.UserUpdate{Suspended: &suspended}); err != nil {
+			t.Fatal(err)
+		} else if !uu.IsSuspended() {
+			t.Fatal("expected suspended user")
+		} else if other, err := s.FindUserByID(ctx, user0.ID); err != nil {
+			t.Fatal(err)
+		} else if !other.IsSuspended() {
+			t.Fatal("expected suspended user")
+		}
+
+		suspended = false
+		if uu, err := s.UpdateUser(ctx, user0.ID, lil.UserUpdate{Suspended: &suspended}); err != nil {
+			t.Fatal(err)
+		} else if uu.IsSuspended() {
+			t.Fatal("expected active user")
+		}
+	})
+
+	// Ensure only administrators can grant administrator rights.
+	t.Run("Admin", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		ctx := lil.NewContextWithAdmin(context.Background())
+
+		admin := true
+		if _, err := s.UpdateUser(ctx0, user0.ID, lil.UserUpdate{Admin: &admin}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if _, err := s.UpdateUser(ctx, user0.ID, lil.UserUpdate{Admin: &admin}); err != nil {
+			t.Fatal(err)
+		} else if other, err := s.FindUserByID(ctx, user0.ID); err != nil {
+			t.Fatal(err)
+		} else if !other.Admin {
+			t.Fatal("expected administrator")
+		}
+	})
+}
+
+func TestUserService_DeleteUser(t *testing.T, open OpenFunc) {
+	// Ensure user can delete self.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "john"})
+
+		// Delete user & ensure it is actually gone.
+		if err := s.DeleteUser(ctx0, user0.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindUserByID(ctx0, user0.ID); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure an error is returned if deleting a non-existent user.
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		if err := s.DeleteUser(context.Background(), 1); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure deleting a user is restricted only to the current user.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		if err := s.DeleteUser(ctx1, user0.ID); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED || lil.ErrorMessage(err) != `You are not allowed to delete this user.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure team shorts are handed over to another owner of the team while
+	// the other shorts of the user are deleted.
+	t.Run("TeamShorts", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		user1, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+		user2, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME2"})
+
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user2.ID, lil.TeamRoleEditor)
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleOwner)
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{Key: "team", URL: *u, TeamID: &team.ID})
+		MustCreateShort(t, ctx0, db, &lil.Short{Key: "mine", URL: *u})
+
+		if err := s.DeleteUser(ctx0, user0.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		shortService := db.ShortService
+		if short, err := shortService.FindShortByKey(ctx1, "team"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.OwnerID, user1.ID; got != want {
+			t.Fatalf("OwnerID=%v, want %v", got, want)
+		}
+		if _, err := shortService.SearchShort(context.Background(), "mine"); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure the last owner of a team with other members cannot be deleted.
+	t.Run("ErrLastTeamOwner", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		user1, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+		MustSetTeamMember(t, ctx0, db, team.ID, user1.ID, lil.TeamRoleEditor)
+
+		u, _ := url.Parse("https://example.com")
+		MustCreateShort(t, ctx0, db, &lil.Short{Key: "team", URL: *u, TeamID: &team.ID})
+
+		if err := s.DeleteUser(ctx0, user0.ID); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := db.ShortService.SearchShort(context.Background(), "team"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure the teams of which the user is the only member are deleted.
+	t.Run("OnlyMember", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		team := MustCreateTeam(t, ctx0, db, &lil.Team{Name: "marketing"})
+
+		if err := s.DeleteUser(ctx0, user0.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := db.TeamService.FindTeamByID(lil.NewContextWithAdmin(context.Background()), team.ID); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_RotateAPIKey(t *testing.T, open OpenFunc) {
+	// Ensure a user can replace their API key.
+	t.Run("OK", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+		oldKey := user0.APIKey
+
+		user, err := s.RotateAPIKey(ctx0, user0.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if user.APIKey == "" || user.APIKey == oldKey {
+			t.Fatalf("unexpected api key: %q", user.APIKey)
+		}
+
+		// The previous key must no longer match any user.
+		if _, n, err := s.FindUsers(ctx0, lil.UserFilter{APIKey: &oldKey}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("n=%v, want 0", n)
+		}
+
+		// The new key matches the user but cannot be read back as only its
+		// hash is stored.
+		if users, _, err := s.FindUsers(ctx0, lil.UserFilter{APIKey: &user.APIKey}); err != nil {
+			t.Fatal(err)
+		} else if len(users) != 1 || users[0].ID != user0.ID {
+			t.Fatalf("unexpected users: %#v", users)
+		} else if users[0].APIKey != "" {
+			t.Fatalf("APIKey=%q, want blank", users[0].APIKey)
+		}
+	})
+
+	// Ensure administrators can rotate the key of any user.
+	t.Run("Admin", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		if _, err := s.RotateAPIKey(lil.NewContextWithAdmin(context.Background()), user0.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure rotating a key is restricted only to the current user.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, _ := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME0"})
+		_, ctx1 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "NAME1"})
+
+		if _, err := s.RotateAPIKey(ctx1, user0.ID); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure API tokens lacking a scope cannot be used to rotate the key.
+	t.Run("ErrToken", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		user0, ctx0 := MustCreateUser(t, context.Background(), db, &lil.User{Name: "susy"})
+
+		ctx0 = lil.NewContextWithToken(ctx0, &lil.Token{Scopes: []string{lil.ScopeUserWrite}})
+		if _, err := s.RotateAPIKey(ctx0, user0.ID); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_FindUser(t *testing.T, open OpenFunc) {
+	// Ensure an error is returned if fetching a non-existent user.
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+		if _, err := s.FindUserByID(context.Background(), 1); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService_FindUsers(t *testing.T, open OpenFunc) {
+	// Ensure users can be fetched by email address.
+	t.Run("Email", func(t *testing.T) {
+		db := open(t)
+		s := db.UserService
+
+		ctx := context.Background()
+		MustCreateUser(t, ctx, db, &lil.User{Name: "john", Email: "john@gmail.com"})
+		MustCreateUser(t, ctx, db, &lil.User{Name: "jane", Email: "jane@gmail.com"})
+		MustCreateUser(t, ctx, db, &lil.User{Name: "frank", Email: "frank@gmail.com"})
+		MustCreateUser(t, ctx, db, &lil.User{Name: "sue", Email: "sue@gmail.com"})
+
+		email := "jane@gmail.com"
+		if a, n, err := s.FindUsers(ctx, lil.UserFilter{Email: &email}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(a), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := a[0].Name, "jane"; got != want {
+			t.Fatalf("name=%v, want %v", got, want)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+// MustCreateUser creates a user in the database. Fatal on error.
+func MustCreateUser(tb testing.TB, ctx context.Context, db *DB, user *lil.User) (*lil.User, context.Context) {
+	tb.Helper()
+	if err := db.UserService.CreateUser(ctx, user); err != nil {
+		tb.Fatal(err)
+	}
+	return user, lil.NewContextWithUser(ctx, user)
+}
@@ -12,8 +12,10 @@ import (
 // these should be expanded as needed (or introduce subcodes).
 const (
 	ECONFLICT       = "conflict"
+	EEXPIRED        = "expired"
 	EINTERNAL       = "internal"
 	EINVALID        = "invalid"
+	ENOTACTIVE      = "not_active"
 	ENOTFOUND       = "not_found"
 	ENOTIMPLEMENTED = "not_implemented"
 	EUNAUTHORIZED   = "unauthorized"
@@ -0,0 +1,122 @@
+package lil
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// ExportVersion is the version of the export format. It is incremented
+// whenever the format changes in a way older versions cannot read.
+const ExportVersion = 1
+
+// Export represents the data of a user in a portable format. It gives users a
+// copy of their data and moves shorts between instances.
+type Export struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+
+	User   ExportUser     `json:"user"`
+	Auths  []*ExportAuth  `json:"auths"`
+	Shorts []*ExportShort `json:"shorts"`
+}
+
+// ExportUser represents the profile of an exported user.
+type ExportUser struct {
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExportAuth represents a login provider linked to an exported user. Tokens
+// are never exported.
+type ExportAuth struct {
+	Source    string    `json:"source"`
+	SourceID  string    `json:"sourceID"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExportShort represents a short owned by an exported user.
+type ExportShort struct {
+	Key         string     `json:"key"`
+	URL         string     `json:"url"`
+	Status      string     `json:"status"`
+	ActivatesAt *time.Time `json:"activatesAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// Validate returns an error if the export cannot be read by this version.
+func (e *Export) Validate() error {
+	if e.Version != ExportVersion {
+		return Errorf(EINVALID, "Unsupported export version %d.", e.Version)
+	}
+	return nil
+}
+
+// Short returns the short to create when importing s. The owner and the
+// timestamps are set on creation. The status is not imported, as it may have
+// been set by administrators, so imported shorts are active.
+func (s *ExportShort) Short() (*Short, error) {
+	u, err := url.ParseRequestURI(s.URL)
+	if err != nil {
+		return nil, Errorf(EINVALID, "Invalid URL passed.")
+	}
+	return &Short{
+		Key:         s.Key,
+		URL:         *u,
+		Status:      ShortStatusActive,
+		ActivatesAt: s.ActivatesAt,
+		ExpiresAt:   s.ExpiresAt,
+	}, nil
+}
+
+// ExportUserData returns the profile, linked login providers and shorts of a
+// user. Shorts shared with the user's teams are only exported by their owner.
+func ExportUserData(ctx context.Context, users UserService, shorts ShortService, userID int, now time.Time) (*Export, error) {
+	user, err := users.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Export{
+		Version:    ExportVersion,
+		ExportedAt: now.UTC().Truncate(time.Second),
+		User: ExportUser{
+			Name:      user.Name,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		Auths:  make([]*ExportAuth, 0, len(user.Auths)),
+		Shorts: make([]*ExportShort, 0),
+	}
+
+	for _, auth := range user.Auths {
+		e.Auths = append(e.Auths, &ExportAuth{
+			Source:    auth.Source,
+			SourceID:  auth.SourceID,
+			CreatedAt: auth.CreatedAt,
+			UpdatedAt: auth.UpdatedAt,
+		})
+	}
+
+	if err := shorts.ForEachShort(ctx, ShortFilter{OwnerID: &userID}, func(short *Short) error {
+		e.Shorts = append(e.Shorts, &ExportShort{
+			Key:         short.Key,
+			URL:         short.URL.String(),
+			Status:      short.Status,
+			ActivatesAt: short.ActivatesAt,
+			ExpiresAt:   short.ExpiresAt,
+			CreatedAt:   short.CreatedAt,
+			UpdatedAt:   short.UpdatedAt,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
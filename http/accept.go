@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaTypes lists the media types the server can respond with. The first
+// one is used when a client accepts several of them equally, or none at all.
+var mediaTypes = []string{"text/html", "application/json", "text/csv"}
+
+// Negotiate returns the media type of the response preferred by the Accept
+// header of r. Quality values are honored & exact media ranges take
+// precedence over wildcards. Defaults to HTML for browsers & requests without
+// an Accept header.
+func Negotiate(r *http.Request) string {
+	return negotiate(parseAccept(r.Header.Values("Accept")), mediaTypes)
+}
+
+// mediaRange represents a single media range of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity returns how closely the range matches mediaType: 2 for an exact
+// match, 1 for a "type/*" match, 0 for "*/*" and -1 if it does not match.
+func (mr mediaRange) specificity(mediaType string) int {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	switch {
+	case mr.typ == "*" && mr.subtype == "*":
+		return 0
+	case mr.typ != typ:
+		return -1
+	case mr.subtype == "*":
+		return 1
+	case mr.subtype == subtype:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// negotiate returns the offer with the highest quality value. Each offer is
+// weighted by its most specific matching range. Returns the first offer if
+// there are no ranges or none of them is acceptable.
+func negotiate(ranges []mediaRange, offers []string) string {
+	best, bestQ, bestSpec := offers[0], 0.0, -1
+	for _, offer := range offers {
+		q, spec := 0.0, -1
+		for _, mr := range ranges {
+			if s := mr.specificity(offer); s > spec {
+				q, spec = mr.q, s
+			}
+		}
+
+		if q > bestQ || (q == bestQ && q > 0 && spec > bestSpec) {
+			best, bestQ, bestSpec = offer, q, spec
+		}
+	}
+	return best
+}
+
+// parseAccept parses the values of Accept headers. Malformed ranges are
+// skipped.
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			params := strings.Split(s, ";")
+
+			typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+			if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+				continue
+			}
+
+			mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+			for _, param := range params[1:] {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(k, "q") {
+					continue
+				}
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+					mr.q = q
+				} else {
+					ok = false
+				}
+			}
+			if ok {
+				ranges = append(ranges, mr)
+			}
+		}
+	}
+	return ranges
+}
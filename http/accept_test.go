@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/html"},
+		{"*/*", "text/html"},
+		{"application/json", "application/json"},
+		{"text/csv", "text/csv"},
+		{"application/json, text/plain, */*", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html"},
+		{"text/html;q=0.5, application/json", "application/json"},
+		{"application/*;q=0.9, text/*;q=0.1", "application/json"},
+		{"text/*, text/html;q=0", "text/csv"},
+		{"image/png", "text/html"},
+		{"APPLICATION/JSON; charset=utf-8", "application/json"},
+		{"application/json;q=2, text/csv", "text/csv"},
+	} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := Negotiate(r); got != tt.want {
+			t.Errorf("Negotiate(%q)=%v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kriive/lil"
+)
+
+// adminPageSize is the number of rows listed on each admin page.
+const adminPageSize = 50
+
+// registerAdminRoutes is a helper function to register routes to a router.
+// These routes are only available to site administrators and act on every
+// user's data. Each change is recorded in the audit log by the services.
+func (s *Server) registerAdminRoutes(r chi.Router) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.requireAdmin)
+		r.Use(s.requireScope(lil.ScopeAdmin))
+		r.Get("/", s.handleAdmin)
+		r.Get("/shorts", s.handleAdminShorts)
+		r.Patch("/shorts/{key}", s.handleAdminShortUpdate)
+		r.Delete("/shorts/{key}", s.handleAdminShortDelete)
+		r.Get("/users", s.handleAdminUsers)
+		r.Patch("/users/{id}", s.handleAdminUserUpdate)
+		r.Delete("/users/{id}", s.handleAdminUserDelete)
+		r.Delete("/users/{id}/sessions", s.handleAdminUserSessionsRevoke)
+		r.Get("/audit", s.handleAdminAudit)
+	})
+}
+
+// requireAdmin is middleware for requiring a site administrator. The request
+// context is given administrator privileges so ownership checks are bypassed.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := lil.UserFromContext(r.Context()); user == nil || !user.Admin {
+			Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "You must be an administrator."))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(lil.NewContextWithAdmin(r.Context())))
+	})
+}
+
+// handleAdmin handles the "GET /admin" route. It redirects to the shorts.
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/admin/shorts", http.StatusFound)
+}
+
+// handleAdminShorts handles the "GET /admin/shorts" route. It lists the
+// shorts of every user, optionally filtered by destination host, owner or
+// status.
+func (s *Server) handleAdminShorts(w http.ResponseWriter, r *http.Request) {
+	var filter lil.ShortFilter
+	switch r.Header.Get("Content-type") {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		q := r.URL.Query()
+		filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+		filter.Limit = adminPageSize
+		if v := q.Get("host"); v != "" {
+			filter.Host = &v
+		}
+		if ownerID, err := strconv.Atoi(q.Get("owner")); err == nil {
+			filter.OwnerID = &ownerID
+		}
+		if v := q.Get("status"); v != "" {
+			filter.Status = &v
+		}
+	}
+
+	shorts, n, err := s.ShortService.FindShorts(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Shorts []*lil.Short `json:"shorts"`
+			N      int          `json:"n"`
+		}{
+			Shorts: shorts,
+			N:      n,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		var status string
+		if filter.Status != nil {
+			status = *filter.Status
+		}
+
+		if err := s.Views.AdminShortsView.Render(w, r, struct {
+			Shorts     []*lil.Short
+			N          int
+			Filter     lil.ShortFilter
+			Status     string
+			NextOffset int
+		}{
+			Shorts:     shorts,
+			N:          n,
+			Filter:     filter,
+			Status:     status,
+			NextOffset: nextOffset(filter.Offset, len(shorts), n),
+		}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+}
+
+// handleAdminShortUpdate handles the "PATCH /admin/shorts/{key}" route. It is
+// used to change the status of shorts.
+func (s *Server) handleAdminShortUpdate(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	var upd lil.ShortUpdate
+	switch Negotiate(r) {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		status := r.PostFormValue("status")
+		upd.Status = &status
+	}
+
+	short, err := s.ShortService.UpdateShort(r.Context(), key, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(short); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		SetFlash(w, "Successfully updated short "+key+".")
+		http.Redirect(w, r, adminReturnURL(r, "/admin/shorts"), http.StatusFound)
+	}
+}
+
+// handleAdminShortDelete handles the "DELETE /admin/shorts/{key}" route.
+func (s *Server) handleAdminShortDelete(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if err := s.ShortService.DeleteShort(r.Context(), key); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully deleted short "+key+".")
+		http.Redirect(w, r, adminReturnURL(r, "/admin/shorts"), http.StatusFound)
+	}
+}
+
+// handleAdminUsers handles the "GET /admin/users" route. It lists every user.
+func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	var filter lil.UserFilter
+	switch r.Header.Get("Content-type") {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		q := r.URL.Query()
+		filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+		filter.Limit = adminPageSize
+		if v := q.Get("email"); v != "" {
+			filter.Email = &v
+		}
+	}
+
+	users, n, err := s.UserService.FindUsers(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Users []*lil.User `json:"users"`
+			N     int         `json:"n"`
+		}{
+			Users: users,
+			N:     n,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		if err := s.Views.AdminUsersView.Render(w, r, struct {
+			Users      []*lil.User
+			N          int
+			Filter     lil.UserFilter
+			NextOffset int
+		}{
+			Users:      users,
+			N:          n,
+			Filter:     filter,
+			NextOffset: nextOffset(filter.Offset, len(users), n),
+		}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+}
+
+// handleAdminUserUpdate handles the "PATCH /admin/users/{id}" route. It is
+// used to suspend & reinstate users.
+func (s *Server) handleAdminUserUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd lil.UserUpdate
+	switch Negotiate(r) {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		suspended, err := strconv.ParseBool(r.PostFormValue("suspended"))
+		if err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid suspended value."))
+			return
+		}
+		upd.Suspended = &suspended
+	}
+
+	// Administrators cannot lock themselves out.
+	if upd.Suspended != nil && *upd.Suspended && id == lil.UserIDFromContext(r.Context()) {
+		Error(w, r, lil.Errorf(lil.EINVALID, "You cannot suspend yourself."))
+		return
+	}
+
+	user, err := s.UserService.UpdateUser(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		SetFlash(w, "Successfully updated user "+user.Name+".")
+		http.Redirect(w, r, adminReturnURL(r, "/admin/users"), http.StatusFound)
+	}
+}
+
+// handleAdminUserDelete handles the "DELETE /admin/users/{id}" route. The
+// shorts of the user are deleted along with it.
+func (s *Server) handleAdminUserDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.UserService.DeleteUser(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully deleted user.")
+		http.Redirect(w, r, adminReturnURL(r, "/admin/users"), http.StatusFound)
+	}
+}
+
+// handleAdminAudit handles the "GET /admin/audit" route. It lists the audit
+// log, newest entries first.
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	var filter lil.AuditFilter
+	switch r.Header.Get("Content-type") {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+		filter.Limit = adminPageSize
+	}
+
+	entries, n, err := s.AuditService.FindAuditEntries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Entries []*lil.AuditEntry `json:"entries"`
+			N       int               `json:"n"`
+		}{
+			Entries: entries,
+			N:       n,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		if err := s.Views.AdminAuditView.Render(w, r, struct {
+			Entries    []*lil.AuditEntry
+			N          int
+			NextOffset int
+		}{
+			Entries:    entries,
+			N:          n,
+			NextOffset: nextOffset(filter.Offset, len(entries), n),
+		}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+}
+
+// nextOffset returns the offset of the next page, or zero if the current page
+// is the last one.
+func nextOffset(offset, count, total int) int {
+	if offset+count < total {
+		return offset + count
+	}
+	return 0
+}
+
+// adminReturnURL returns the admin page the form was submitted from so that
+// filters are kept after an action. Defaults to fallback.
+func adminReturnURL(r *http.Request, fallback string) string {
+	if v := r.PostFormValue("return"); strings.HasPrefix(v, "/admin/") {
+		return v
+	}
+	return fallback
+}
+
+// handleAdminUserSessionsRevoke handles the "DELETE /admin/users/{id}/sessions"
+// route. It logs the user out of every browser.
+func (s *Server) handleAdminUserSessionsRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.SessionService.RevokeUserSessions(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully revoked the sessions of the user.")
+		http.Redirect(w, r, adminReturnURL(r, "/admin/users"), http.StatusFound)
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestServer_Admin(t *testing.T) {
+	// Ensure administrators can disable the shorts of other users and that
+	// the action is recorded in the audit log.
+	t.Run("DisableShort", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		adminCtx := MustCreateUser(t, db, &lil.User{Name: "admin", Admin: true})
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		s := NewShortService(NewClient(ts.URL))
+		u, _ := url.Parse("https://malware.example.com/payload")
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "abuse"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp := MustDoJSON(t, adminCtx, "PATCH", ts.URL+"/admin/shorts/abuse", `{"status":"disabled"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusOK)
+		}
+
+		if _, err := s.SearchShort(context.Background(), "abuse"); lil.ErrorCode(err) != lil.ENOTACTIVE {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		resp := MustDoJSON(t, adminCtx, "GET", ts.URL+"/admin/audit", "")
+		var body struct {
+			Entries []*lil.AuditEntry `json:"entries"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(body.Entries), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := body.Entries[0].Action, lil.AuditShortDisable; got != want {
+			t.Fatalf("Action=%v, want %v", got, want)
+		} else if got, want := body.Entries[0].Target, "abuse"; got != want {
+			t.Fatalf("Target=%v, want %v", got, want)
+		} else if got, want := body.Entries[0].ActorID, 1; got != want {
+			t.Fatalf("ActorID=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure suspended users cannot use their API key anymore.
+	t.Run("SuspendUser", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		adminCtx := MustCreateUser(t, db, &lil.User{Name: "admin", Admin: true})
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		if resp := MustDoJSON(t, adminCtx, "PATCH", ts.URL+"/admin/users/2", `{"suspended":true}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusOK)
+		}
+
+		s := NewShortService(NewClient(ts.URL))
+		if _, _, err := s.FindShorts(ctx, lil.ShortFilter{}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.EUNAUTHORIZED || lil.ErrorMessage(err) != "Your account has been suspended." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure the admin pages are restricted to administrators.
+	t.Run("ErrNotAdmin", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy", Email: "susy@example.com"})
+		if resp := MustDoJSON(t, ctx, "GET", ts.URL+"/admin/shorts", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+}
+
+// MustDoJSON sends a JSON request authenticated with the API key of the
+// context user. The response body is closed with the test. Fatal on error.
+func MustDoJSON(tb testing.TB, ctx context.Context, method, u, body string) *http.Response {
+	tb.Helper()
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+lil.UserFromContext(ctx).APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -7,36 +7,58 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/google/go-github/v45/github"
 	"github.com/kriive/lil"
-	"golang.org/x/oauth2"
-	google "google.golang.org/api/oauth2/v2"
-	"google.golang.org/api/option"
 )
 
 // registerAuthRoutes is a helper function to register routes to a router.
 func (s *Server) registerAuthRoutes(r chi.Router) {
 	r.Get("/login", s.handleLogin)
+	r.Post("/login/dev", s.handleDevLogin)
 	r.Delete("/logout", s.handleLogout)
-	r.Get("/oauth/github", s.handleOAuthGitHub)
-	r.Get("/oauth/github/callback", s.handleOAuthGitHubCallback)
-	r.Get("/oauth/google", s.handleOAuthGoogle)
-	r.Get("/oauth/google/callback", s.handleOAuthGoogleCallback)
+	r.Get("/oauth/{provider}", s.handleOAuth)
+	r.Get("/oauth/{provider}/callback", s.handleOAuthCallback)
 }
 
-// handleLogin handles the "GET /login" route. It simply renders an HTML login form.
+// handleLogin handles the "GET /login" route. It renders an HTML login form
+// listing the enabled providers and, in dev mode, the existing users.
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	if err := s.Views.LoginView.Render(w, r, nil); err != nil {
+	var users []*lil.User
+	if s.DevLogin {
+		var err error
+		if users, _, err = s.UserService.FindUsers(r.Context(), lil.UserFilter{}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	if err := s.Views.LoginView.Render(w, r, struct {
+		Providers []OAuthProvider
+		DevLogin  bool
+		Users     []*lil.User
+	}{
+		Providers: s.OAuthProviders,
+		DevLogin:  s.DevLogin,
+		Users:     users,
+	}); err != nil {
 		Error(w, r, err)
 		return
 	}
 }
 
-// handleLogout handles the "DELETE /logout" route. It clears the session
-// cookie and redirects the user to the home page.
+// handleLogout handles the "DELETE /logout" route. It revokes the current
+// session, clears the session cookie and redirects the user to the home page.
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// Revoke the server-side session so the cookie cannot be reused.
+	if sess := lil.SessionFromContext(r.Context()); sess != nil {
+		if err := s.SessionService.RevokeSession(r.Context(), sess.ID); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
 	// Clear session cookie on HTTP response.
 	if err := s.setSession(w, Session{}); err != nil {
 		Error(w, r, err)
@@ -47,38 +69,70 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-func (s *Server) handleOAuthGoogle(w http.ResponseWriter, r *http.Request) {
+// handleDevLogin handles the "POST /login/dev" route. It logs in as an
+// existing user, or creates a new one if a name is given, without any
+// authentication. Only available when the server runs in dev mode.
+func (s *Server) handleDevLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.DevLogin {
+		Error(w, r, lil.Errorf(lil.ENOTFOUND, "Development login is not enabled."))
+		return
+	}
+
 	session, err := s.session(r)
 	if err != nil {
-		Error(w, r, err)
+		Error(w, r, fmt.Errorf("cannot read session: %s", err))
 		return
 	}
 
-	state := make([]byte, 64)
-	if _, err := io.ReadFull(rand.Reader, state); err != nil {
+	// Create a new user if a name is given, otherwise use the chosen one.
+	var user *lil.User
+	if name := strings.TrimSpace(r.PostFormValue("name")); name != "" {
+		user = &lil.User{Name: name, Email: strings.TrimSpace(r.PostFormValue("email"))}
+		if err := s.UserService.CreateUser(r.Context(), user); err != nil {
+			Error(w, r, err)
+			return
+		}
+	} else if id, err := strconv.Atoi(r.PostFormValue("userID")); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Choose a user or enter a name."))
+		return
+	} else if user, err = s.UserService.FindUserByID(r.Context(), id); err != nil {
 		Error(w, r, err)
 		return
 	}
-	session.State = hex.EncodeToString(state)
 
-	// Store the state to the session in the response cookie.
-	if err := s.setSession(w, session); err != nil {
-		Error(w, r, err)
+	if user.IsSuspended() {
+		Error(w, r, lil.ErrUserSuspended)
 		return
 	}
 
-	// Redirect to OAuth2 provider.
-	http.Redirect(w, r, s.OAuth2Config(lil.AuthSourceGoogle).AuthCodeURL(session.State,
-		oauth2.SetAuthURLParam("redirect_uri", s.URL()+"/oauth/google/callback")),
-		http.StatusFound)
+	// Restore redirect URL stored on login.
+	redirectURL := session.RedirectURL
+
+	// Start a new session for the user.
+	if err := s.login(w, r, user.ID, "dev"); err != nil {
+		Error(w, r, fmt.Errorf("cannot start session: %w", err))
+		return
+	}
+
+	// Redirect to stored URL or, if not available, to the home page.
+	if redirectURL == "" {
+		redirectURL = "/"
+	}
+	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
 
-// handleOAuthGitHub handles the "GET /oauth/github" route. It generates a
-// random state variable and redirects the user to the GitHub OAuth endpoint.
+// handleOAuth handles the "GET /oauth/{provider}" route. It generates a
+// random state & nonce and redirects the user to the provider's consent page.
 //
 // After authentication, user will be redirected back to the callback page
 // where we can store the returned OAuth tokens.
-func (s *Server) handleOAuthGitHub(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleOAuth(w http.ResponseWriter, r *http.Request) {
+	provider := s.OAuthProvider(chi.URLParam(r, "provider"))
+	if provider == nil {
+		Error(w, r, lil.Errorf(lil.ENOTFOUND, "Login provider not found."))
+		return
+	}
+
 	// Read session from request's cookies.
 	session, err := s.session(r)
 	if err != nil {
@@ -86,13 +140,15 @@ func (s *Server) handleOAuthGitHub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate new OAuth state for the session to prevent CSRF attacks.
-	state := make([]byte, 64)
-	if _, err := io.ReadFull(rand.Reader, state); err != nil {
+	// Generate new OAuth state for the session to prevent CSRF attacks and a
+	// nonce to bind ID tokens to the session.
+	buf := make([]byte, 64)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
 		Error(w, r, err)
 		return
 	}
-	session.State = hex.EncodeToString(state)
+	session.State = hex.EncodeToString(buf[:32])
+	session.Nonce = hex.EncodeToString(buf[32:])
 
 	// Store the state to the session in the response cookie.
 	if err := s.setSession(w, session); err != nil {
@@ -101,95 +157,20 @@ func (s *Server) handleOAuthGitHub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Redirect to OAuth2 provider.
-	http.Redirect(w, r, s.OAuth2Config(lil.AuthSourceGitHub).AuthCodeURL(session.State), http.StatusFound)
+	http.Redirect(w, r, provider.AuthCodeURL(session.State, session.Nonce, s.oauthRedirectURL(provider)), http.StatusFound)
 }
 
-func (s *Server) handleOAuthGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	state, code := r.FormValue("state"), r.FormValue("code")
-
-	session, err := s.session(r)
-	if err != nil {
-		Error(w, r, fmt.Errorf("cannot read session: %s", err))
-		return
-	}
-
-	if state != session.State {
-		Error(w, r, fmt.Errorf("oauth state mismatch"))
-		return
-	}
-
-	tok, err := s.OAuth2Config(lil.AuthSourceGoogle).Exchange(r.Context(),
-		code,
-		oauth2.SetAuthURLParam("redirect_uri", s.URL()+"/oauth/google/callback"))
-	if err != nil {
-		Error(w, r, fmt.Errorf("oauth exchange error: %s", err))
-		return
-	}
-
-	g, err := google.NewService(r.Context(), option.WithTokenSource(oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: tok.AccessToken},
-	)))
-	if err != nil {
-		Error(w, r, fmt.Errorf("google client creation error: %s", err))
-		return
-	}
-
-	c, err := google.NewUserinfoV2Service(g).Me.Get().Do()
-	if err != nil {
-		Error(w, r, fmt.Errorf("cannot fetch google user: %s", err))
-		return
-	} else if c.Id == "" {
-		Error(w, r, fmt.Errorf("user ID not returned by Google, cannot authenticate user."))
-		return
-	}
-
-	// Create an authentication object with an associated user.
-	auth := &lil.Auth{
-		Source:       lil.AuthSourceGitHub,
-		SourceID:     c.Id,
-		AccessToken:  tok.AccessToken,
-		RefreshToken: tok.RefreshToken,
-		User: &lil.User{
-			Name:  c.Name,
-			Email: c.Email,
-		},
-	}
-	if !tok.Expiry.IsZero() {
-		auth.Expiry = &tok.Expiry
-	}
-
-	// Create the "Auth" object in the database. The AuthService will lookup
-	// the user by email if they already exist. Otherwise, a new user will be
-	// created and the user's ID will be set to auth.UserID.
-	if err := s.AuthService.CreateAuth(r.Context(), auth); err != nil {
-		Error(w, r, fmt.Errorf("cannot create auth: %s", err))
-		return
-	}
-
-	// Restore redirect URL stored on login.
-	redirectURL := session.RedirectURL
-
-	// Update browser session to store the user's ID and clear OAuth state.
-	session.UserID = auth.UserID
-	session.RedirectURL = ""
-	session.State = ""
-	if err := s.setSession(w, session); err != nil {
-		Error(w, r, fmt.Errorf("cannot set session cookie: %s", err))
+// handleOAuthCallback handles the "GET /oauth/{provider}/callback" route.
+// It validates the returned OAuth state that we generated previously, looks up
+// the current user's information, and creates an "Auth" object in the database.
+func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider := s.OAuthProvider(chi.URLParam(r, "provider"))
+	if provider == nil {
+		Error(w, r, lil.Errorf(lil.ENOTFOUND, "Login provider not found."))
 		return
 	}
 
-	// Redirect to stored URL or, if not available, to the home page.
-	if redirectURL == "" {
-		redirectURL = "/"
-	}
-	http.Redirect(w, r, redirectURL, http.StatusFound)
-}
-
-// handleOAuthGitHubCallback handles the "GET /oauth/github/callback" route.
-// It validates the returned OAuth state that we generated previously, looks up
-// the current user's information, and creates an "Auth" object in the database.
-func (s *Server) handleOAuthGitHubCallback(w http.ResponseWriter, r *http.Request) {
-	// Read form variables passed in from GitHub.
+	// Read form variables passed in from the provider.
 	state, code := r.FormValue("state"), r.FormValue("code")
 
 	// Read session from request.
@@ -200,57 +181,44 @@ func (s *Server) handleOAuthGitHubCallback(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Validate that state matches session state.
-	if state != session.State {
+	if state == "" || state != session.State {
 		Error(w, r, fmt.Errorf("oauth state mismatch"))
 		return
 	}
 
 	// Exchange code for OAuth tokens.
-	tok, err := s.OAuth2Config(lil.AuthSourceGitHub).Exchange(r.Context(), code)
+	tok, err := provider.Exchange(r.Context(), code, s.oauthRedirectURL(provider))
 	if err != nil {
 		Error(w, r, fmt.Errorf("oauth exchange error: %s", err))
 		return
 	}
 
-	// Create a new GitHub API client.
-	client := github.NewClient(oauth2.NewClient(r.Context(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: tok.AccessToken},
-	)))
-
 	// Fetch user information for the currently authenticated user.
-	// Require that we at least receive a user ID from GitHub.
-	u, _, err := client.Users.Get(r.Context(), "")
+	profile, err := provider.Profile(r.Context(), tok, session.Nonce)
 	if err != nil {
-		Error(w, r, fmt.Errorf("cannot fetch github user: %s", err))
-		return
-	} else if u.ID == nil {
-		Error(w, r, fmt.Errorf("user ID not returned by GitHub, cannot authenticate user"))
+		Error(w, r, err)
 		return
 	}
 
-	// Email is not necessarily available for all accounts. If it is, store it
-	// so we can link together multiple OAuth providers in the future
-	// (e.g. GitHub, Google, etc).
-	var name string
-	if u.Name != nil {
-		name = *u.Name
-	} else if u.Login != nil {
-		name = *u.Login
+	// Fall back to the email address if the provider does not return a name.
+	name := profile.Name
+	if name == "" {
+		name = profile.Email
 	}
-	var email string
-	if u.Email != nil {
-		email = *u.Email
+	if name == "" {
+		name = profile.ID
 	}
 
 	// Create an authentication object with an associated user.
 	auth := &lil.Auth{
-		Source:       lil.AuthSourceGitHub,
-		SourceID:     strconv.FormatInt(*u.ID, 10),
+		Source:       provider.Source(),
+		SourceID:     profile.ID,
 		AccessToken:  tok.AccessToken,
 		RefreshToken: tok.RefreshToken,
+		Picture:      profile.Picture,
 		User: &lil.User{
 			Name:  name,
-			Email: email,
+			Email: profile.Email,
 		},
 	}
 	if !tok.Expiry.IsZero() {
@@ -263,17 +231,17 @@ func (s *Server) handleOAuthGitHubCallback(w http.ResponseWriter, r *http.Reques
 	if err := s.AuthService.CreateAuth(r.Context(), auth); err != nil {
 		Error(w, r, fmt.Errorf("cannot create auth: %s", err))
 		return
+	} else if auth.User != nil && auth.User.IsSuspended() {
+		Error(w, r, lil.ErrUserSuspended)
+		return
 	}
 
 	// Restore redirect URL stored on login.
 	redirectURL := session.RedirectURL
 
-	// Update browser session to store the user's ID and clear OAuth state.
-	session.UserID = auth.UserID
-	session.RedirectURL = ""
-	session.State = ""
-	if err := s.setSession(w, session); err != nil {
-		Error(w, r, fmt.Errorf("cannot set session cookie: %s", err))
+	// Start a new session for the user, which also clears the OAuth state.
+	if err := s.login(w, r, auth.UserID, provider.Source()); err != nil {
+		Error(w, r, fmt.Errorf("cannot start session: %w", err))
 		return
 	}
 
@@ -283,3 +251,8 @@ func (s *Server) handleOAuthGitHubCallback(w http.ResponseWriter, r *http.Reques
 	}
 	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
+
+// oauthRedirectURL returns the callback URL registered with the provider.
+func (s *Server) oauthRedirectURL(provider OAuthProvider) string {
+	return s.URL() + "/oauth/" + provider.Source() + "/callback"
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil/http/html"
+)
+
+func TestServer_DevLogin(t *testing.T) {
+	// Ensure a user can be created & logged in without any provider.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t, func(s *Server) { s.DevLogin = true })
+		defer MustCloseServer(t, ts, db)
+
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err := client.PostForm(ts.URL+"/login/dev", url.Values{"name": {"susy"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusFound; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+
+		// The session cookie now authenticates API requests.
+		req, _ := http.NewRequest("GET", ts.URL+"/user/1", nil)
+		req.Header.Set("Accept", "application/json")
+		if resp, err = client.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure the dev login is unavailable unless enabled.
+	t.Run("ErrNotEnabled", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		resp, err := http.Post(ts.URL+"/login/dev", "application/x-www-form-urlencoded", strings.NewReader("name=susy"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure the server refuses to start in dev mode on a public domain.
+	t.Run("ErrDomain", func(t *testing.T) {
+		s := NewServer()
+		s.DevLogin = true
+		s.Domain = "lil.example.com"
+		if err := s.Open(); err == nil {
+			s.Close()
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestServer_CSRF(t *testing.T) {
+	// Ensure forms posted with the CSRF token of the session are accepted, and
+	// that the session cookie cannot be reused after logging out.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t, withDevLoginViews(t))
+		defer MustCloseServer(t, ts, db)
+
+		cookies := MustLoginDev(t, ts, "susy")
+		for _, c := range cookies {
+			if c.Name == SessionCookieName && c.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("SameSite=%v, want %v", c.SameSite, http.SameSiteLaxMode)
+			}
+		}
+		token := MustReadCSRFToken(t, ts, cookies)
+
+		form := url.Values{"_method": {"DELETE"}, CSRFFormName: {token}}
+		if got, want := MustDoCookie(t, ts, cookies, "POST", "/logout", form), http.StatusFound; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+
+		// Replay the cookie of the revoked session.
+		if got, want := MustDoCookie(t, ts, cookies, "GET", "/user/1", nil), http.StatusUnauthorized; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure forms posted without a valid token are rejected.
+	t.Run("ErrInvalidToken", func(t *testing.T) {
+		ts, db := MustOpenServer(t, withDevLoginViews(t))
+		defer MustCloseServer(t, ts, db)
+
+		cookies := MustLoginDev(t, ts, "susy")
+		for _, token := range []string{"", "invalid"} {
+			form := url.Values{"_method": {"DELETE"}, CSRFFormName: {token}}
+			if got, want := MustDoCookie(t, ts, cookies, "POST", "/logout", form), http.StatusUnauthorized; got != want {
+				t.Fatalf("StatusCode=%v, want %v", got, want)
+			}
+		}
+
+		// The session is still valid.
+		if got, want := MustDoCookie(t, ts, cookies, "GET", "/user/1", nil), http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+}
+
+// withDevLoginViews enables the dev login & the settings page, which is used
+// to read the CSRF token of a session.
+func withDevLoginViews(tb testing.TB) func(*Server) {
+	return func(s *Server) {
+		s.DevLogin = true
+
+		engine, err := html.NewEngine(html.FS)
+		if err != nil {
+			tb.Fatal(err)
+		} else if s.Views.SettingsView, err = engine.SettingsView(); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// MustLoginDev logs in through the dev login and returns the cookies of the
+// new session. Fatal on error.
+func MustLoginDev(tb testing.TB, ts *httptest.Server, name string) []*http.Cookie {
+	tb.Helper()
+	req, _ := http.NewRequest("POST", ts.URL+"/login/dev", strings.NewReader(url.Values{"name": {name}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.Cookies()
+}
+
+// MustReadCSRFToken returns the CSRF token rendered in the settings page.
+// Fatal on error.
+func MustReadCSRFToken(tb testing.TB, ts *httptest.Server, cookies []*http.Cookie) string {
+	tb.Helper()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/settings", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	m := regexp.MustCompile(`name="` + CSRFFormName + `" value="([^"]+)"`).FindSubmatch(body)
+	if m == nil {
+		tb.Fatalf("csrf token not found: %s", body)
+	}
+	return string(m[1])
+}
+
+// MustDoCookie sends a request authenticated by cookies, without following
+// redirects, and returns its status code. Fatal on error.
+func MustDoCookie(tb testing.TB, ts *httptest.Server, cookies []*http.Cookie, method, path string, form url.Values) int {
+	tb.Helper()
+
+	req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// recordClick records a redirect through the short identified by key.
+// Failures are only logged so they never prevent the redirect.
+func (s *Server) recordClick(r *http.Request, key string) {
+	if s.ClickService == nil {
+		return
+	}
+
+	click := &lil.Click{
+		ShortKey:  key,
+		Referrer:  referrerHost(r),
+		UserAgent: userAgentClass(r.UserAgent()),
+		IPHash:    s.hashIP(r),
+	}
+	if err := s.ClickService.RecordClick(r.Context(), click); err != nil {
+		LogError(r, err)
+	}
+}
+
+// hashIP returns the salted SHA-256 hash of the client IP address.
+func (s *Server) hashIP(r *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(s.ClickSalt))
+	h.Write([]byte(clientIP(r)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// clientIP returns the IP address of the client, without the port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// referrerHost returns the host of the referring page, if any.
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// userAgentClass returns a coarse classification of a User-Agent header.
+// The full header is not stored as it is mostly noise and fingerprintable.
+func userAgentClass(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return lil.UserAgentOther
+	case strings.Contains(ua, "bot"), strings.Contains(ua, "crawl"),
+		strings.Contains(ua, "spider"), strings.Contains(ua, "preview"):
+		return lil.UserAgentBot
+	case strings.HasPrefix(ua, "curl/"), strings.HasPrefix(ua, "wget/"),
+		strings.HasPrefix(ua, "httpie/"), strings.HasPrefix(ua, "go-http-client/"):
+		return lil.UserAgentCLI
+	case strings.Contains(ua, "mobile"), strings.Contains(ua, "android"),
+		strings.Contains(ua, "iphone"):
+		return lil.UserAgentMobile
+	case strings.HasPrefix(ua, "mozilla/"):
+		return lil.UserAgentBrowser
+	default:
+		return lil.UserAgentOther
+	}
+}
@@ -0,0 +1,328 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// clientPageSize is the number of shorts fetched per request by ForEachShort.
+const clientPageSize = 100
+
+// Client represents an HTTP client for a remote lild server.
+//
+// Requests are authenticated with the API key of the user attached to the
+// request context, if any. The key can either be an API token or the legacy
+// API key of the user.
+type Client struct {
+	// Base URL of the server, e.g. "https://lil.example.com".
+	URL string
+
+	// Underlying HTTP client. Redirects are never followed so that the
+	// targets of shorts can be read.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a new instance of Client.
+func NewClient(u string) *Client {
+	return &Client{
+		URL: strings.TrimSuffix(u, "/"),
+		HTTPClient: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// newRequest returns a new HTTP request with the API key & JSON headers set.
+// If body is not nil, it is encoded as JSON.
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set API key in header.
+	if user := lil.UserFromContext(ctx); user != nil && user.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+user.APIKey)
+	}
+
+	// Default to JSON format.
+	req.Header.Set("Content-type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// do sends a request and decodes the JSON response into v, if v is not nil.
+// Non-2xx responses are returned as application errors.
+func (c *Client) do(req *http.Request, v any) error {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseResponseError(resp)
+	} else if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseResponseError parses an JSON-formatted error response.
+func parseResponseError(resp *http.Response) error {
+	// Read the response body so we can reuse it for the error message if it
+	// fails to decode as JSON.
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Parse JSON formatted error response.
+	// If not JSON, use the response body as the error message.
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(buf, &errorResponse); err != nil {
+		message := strings.TrimSpace(string(buf))
+		if message == "" {
+			message = "Empty response from server."
+		}
+		return lil.Errorf(FromErrorStatusCode(resp.StatusCode), message)
+	}
+	return lil.Errorf(FromErrorStatusCode(resp.StatusCode), errorResponse.Error)
+}
+
+// Ensure service implements interface.
+var _ lil.ShortService = (*ShortService)(nil)
+
+// ShortService implements the lil.ShortService over the HTTP protocol.
+type ShortService struct {
+	Client *Client
+}
+
+// NewShortService returns a new instance of ShortService.
+func NewShortService(client *Client) *ShortService {
+	return &ShortService{Client: client}
+}
+
+// FindShortByKey retrieves a single Short by Key. Returns ENOTFOUND if the
+// Short does not exist or does not belong to the current user.
+func (s *ShortService) FindShortByKey(ctx context.Context, key string) (*lil.Short, error) {
+	shorts, _, err := s.FindShorts(ctx, lil.ShortFilter{Key: &key})
+	if err != nil {
+		return nil, err
+	} else if len(shorts) == 0 {
+		return nil, lil.Errorf(lil.ENOTFOUND, "Short not found.")
+	}
+	return shorts[0], nil
+}
+
+// SearchShort retrieves a single Short by Key, regardless of its owner.
+// Only the key, URL & status are returned. Unlike following the short, the
+// lookup is not recorded as a click.
+func (s *ShortService) SearchShort(ctx context.Context, key string) (*lil.Short, error) {
+	req, err := s.Client.newRequest(ctx, "GET", "/s/"+url.PathEscape(key)+"/info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info shortInfoResponse
+	if err := s.Client.do(req, &info); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(info.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &lil.Short{Key: info.Key, URL: *u, Status: info.Status}, nil
+}
+
+// FindShorts retrieves a list of the current user's Shorts based on a filter.
+func (s *ShortService) FindShorts(ctx context.Context, filter lil.ShortFilter) ([]*lil.Short, int, error) {
+	req, err := s.Client.newRequest(ctx, "GET", "/short", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var resp struct {
+		Shorts []*lil.Short `json:"shorts"`
+		N      int          `json:"n"`
+	}
+	if err := s.Client.do(req, &resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Shorts, resp.N, nil
+}
+
+// ForEachShort calls fn for every Short matching filter, ordered by key.
+// Pages are fetched with separate requests, so shorts changed in the
+// meantime may be missed.
+func (s *ShortService) ForEachShort(ctx context.Context, filter lil.ShortFilter, fn func(*lil.Short) error) error {
+	var after string
+	if filter.After != nil {
+		after = *filter.After
+	}
+	filter.After, filter.Offset, filter.Limit = &after, 0, clientPageSize
+
+	for {
+		shorts, _, err := s.FindShorts(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, short := range shorts {
+			if err := fn(short); err != nil {
+				return err
+			}
+		}
+
+		if len(shorts) < filter.Limit {
+			return nil
+		}
+		after = shorts[len(shorts)-1].Key
+	}
+}
+
+// CreateShort creates a new Short. A random key is assigned by the server
+// unless short.Key is set.
+func (s *ShortService) CreateShort(ctx context.Context, short *lil.Short) error {
+	req, err := s.Client.newRequest(ctx, "POST", "/short/new", short)
+	if err != nil {
+		return err
+	}
+	return s.Client.do(req, short)
+}
+
+// UpdateShort updates an existing Short.
+func (s *ShortService) UpdateShort(ctx context.Context, key string, upd lil.ShortUpdate) (*lil.Short, error) {
+	req, err := s.Client.newRequest(ctx, "PATCH", "/s/"+url.PathEscape(key), upd)
+	if err != nil {
+		return nil, err
+	}
+
+	var short lil.Short
+	if err := s.Client.do(req, &short); err != nil {
+		return nil, err
+	}
+	return &short, nil
+}
+
+// DeleteShort permanently removes a Short.
+func (s *ShortService) DeleteShort(ctx context.Context, key string) error {
+	req, err := s.Client.newRequest(ctx, "DELETE", "/s/"+url.PathEscape(key), nil)
+	if err != nil {
+		return err
+	}
+	return s.Client.do(req, nil)
+}
+
+// Ensure service implements interface.
+var _ lil.UserService = (*UserService)(nil)
+
+// UserService implements the lil.UserService over the HTTP protocol.
+//
+// The server only exposes the current user so lookups of other users
+// return EUNAUTHORIZED.
+type UserService struct {
+	Client *Client
+}
+
+// NewUserService returns a new instance of UserService.
+func NewUserService(client *Client) *UserService {
+	return &UserService{Client: client}
+}
+
+// FindUserByID retrieves a user by ID along with their associated auth objects.
+func (s *UserService) FindUserByID(ctx context.Context, id int) (*lil.User, error) {
+	req, err := s.Client.newRequest(ctx, "GET", "/user/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user lil.User
+	if err := s.Client.do(req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUsers retrieves a list of users by filter. Only the current user
+// can be returned by the server.
+func (s *UserService) FindUsers(ctx context.Context, filter lil.UserFilter) ([]*lil.User, int, error) {
+	req, err := s.Client.newRequest(ctx, "GET", "/user", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var resp struct {
+		Users []*lil.User `json:"users"`
+		N     int         `json:"n"`
+	}
+	if err := s.Client.do(req, &resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Users, resp.N, nil
+}
+
+// CreateUser is not supported remotely as users are created through OAuth.
+func (s *UserService) CreateUser(ctx context.Context, user *lil.User) error {
+	return lil.Errorf(lil.ENOTIMPLEMENTED, "Users can only be created by logging in.")
+}
+
+// UpdateUser updates the current user.
+func (s *UserService) UpdateUser(ctx context.Context, id int, upd lil.UserUpdate) (*lil.User, error) {
+	req, err := s.Client.newRequest(ctx, "PATCH", "/user/"+strconv.Itoa(id), upd)
+	if err != nil {
+		return nil, err
+	}
+
+	var user lil.User
+	if err := s.Client.do(req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RotateAPIKey replaces the API key of the current user. The new key is set
+// on the returned user.
+func (s *UserService) RotateAPIKey(ctx context.Context, id int) (*lil.User, error) {
+	req, err := s.Client.newRequest(ctx, "POST", "/user/"+strconv.Itoa(id)+"/api-key", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		User   *lil.User `json:"user"`
+		APIKey string    `json:"apiKey"`
+	}
+	if err := s.Client.do(req, &resp); err != nil {
+		return nil, err
+	}
+	resp.User.APIKey = resp.APIKey
+	return resp.User, nil
+}
+
+// DeleteUser is not supported. Deleting an account must be confirmed with the
+// user name through "DELETE /settings".
+func (s *UserService) DeleteUser(ctx context.Context, id int) error {
+	return lil.Errorf(lil.ENOTIMPLEMENTED, "Accounts can only be deleted from the settings.")
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestShortService(t *testing.T) {
+	// Ensure shorts can be managed remotely by their owner.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		s := NewShortService(NewClient(ts.URL))
+
+		u, _ := url.Parse("https://example.com")
+		short := &lil.Short{URL: *u, Key: "q3-roadmap"}
+		if err := s.CreateShort(ctx, short); err != nil {
+			t.Fatal(err)
+		} else if short.CreatedAt.IsZero() {
+			t.Fatal("expected CreatedAt")
+		}
+
+		if other, err := s.FindShortByKey(ctx, "q3-roadmap"); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.URL.String(), "https://example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		newURL, _ := url.Parse("https://example.com/moved")
+		if other, err := s.UpdateShort(ctx, "q3-roadmap", lil.ShortUpdate{URL: newURL}); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.URL.String(), "https://example.com/moved"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		// Redirects are readable without authentication.
+		if other, err := s.SearchShort(context.Background(), "q3-roadmap"); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.URL.String(), "https://example.com/moved"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		if shorts, n, err := s.FindShorts(ctx, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(shorts), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if err := s.DeleteShort(ctx, "q3-roadmap"); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindShortByKey(ctx, "q3-roadmap"); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure a random key is assigned if none is requested.
+	t.Run("RandomKey", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		s := NewShortService(NewClient(ts.URL))
+
+		u, _ := url.Parse("https://example.com")
+		short := &lil.Short{URL: *u}
+		if err := s.CreateShort(ctx, short); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(short.Key), 6; got != want {
+			t.Fatalf("len(Key)=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure server errors are decoded into application errors.
+	t.Run("ErrConflict", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		s := NewShortService(NewClient(ts.URL))
+
+		u, _ := url.Parse("https://example.com")
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "taken"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "taken"}); err == nil {
+			t.Fatal("expected error")
+		} else if lil.ErrorCode(err) != lil.ECONFLICT || lil.ErrorMessage(err) != "Short with the same key already exists." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure reserved keys cannot be requested.
+	t.Run("ErrReservedKey", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		s := NewShortService(NewClient(ts.URL))
+
+		u, _ := url.Parse("https://example.com")
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "Login"}); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure requests without an API key are rejected.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		s := NewShortService(NewClient(ts.URL))
+		if _, _, err := s.FindShorts(context.Background(), lil.ShortFilter{}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestUserService(t *testing.T) {
+	// Ensure the current user can be read & updated remotely.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy", Email: "susy@gmail.com"})
+		s := NewUserService(NewClient(ts.URL))
+
+		if user, err := s.FindUserByID(ctx, 1); err != nil {
+			t.Fatal(err)
+		} else if got, want := user.Email, "susy@gmail.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		}
+
+		newName := "jill"
+		if user, err := s.UpdateUser(ctx, 1, lil.UserUpdate{Name: &newName}); err != nil {
+			t.Fatal(err)
+		} else if got, want := user.Name, "jill"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		}
+
+		if users, n, err := s.FindUsers(ctx, lil.UserFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := users[0].Name, "jill"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure other users cannot be read.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		MustCreateUser(t, db, &lil.User{Name: "susy"})
+		ctx := MustCreateUser(t, db, &lil.User{Name: "jane"})
+		s := NewUserService(NewClient(ts.URL))
+
+		if _, err := s.FindUserByID(ctx, 1); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// MustOpenServer returns a test server backed by a new database. Any opts are
+// applied to the server before it starts. Fatal on error.
+func MustOpenServer(tb testing.TB, opts ...func(*Server)) (*httptest.Server, *sqlite.DB) {
+	tb.Helper()
+
+	db := sqlite.NewDB(filepath.Join(tb.TempDir(), "db"))
+	if err := db.Open(); err != nil {
+		tb.Fatal(err)
+	}
+
+	s := NewServer()
+	s.HashKey = strings.Repeat("00", 32)
+	s.BlockKey = strings.Repeat("00", 32)
+	if err := s.openSecureCookie(); err != nil {
+		tb.Fatal(err)
+	}
+
+	s.KeyLength = 6
+	s.Alphabet = "abcdefghijklmnopqrstuvwxyz"
+	s.KeyPolicy = lil.KeyPolicy{
+		Alphabet:  "abcdefghijklmnopqrstuvwxyz0123456789-",
+		MinLength: 3,
+		MaxLength: 32,
+		Reserved:  []string{"login"},
+	}
+
+	s.AuthService = sqlite.NewAuthService(db)
+	s.ShortService = sqlite.NewShortService(db)
+	s.UserService = sqlite.NewUserService(db)
+	s.TeamService = sqlite.NewTeamService(db)
+	s.AuditService = sqlite.NewAuditService(db)
+	s.TokenService = sqlite.NewTokenService(db)
+	s.SessionService = sqlite.NewSessionService(db)
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return httptest.NewServer(s), db
+}
+
+// MustCloseServer closes the test server and its database. Fatal on error.
+func MustCloseServer(tb testing.TB, ts *httptest.Server, db *sqlite.DB) {
+	tb.Helper()
+	ts.Close()
+	if err := db.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// MustCreateUser creates a user in the database and returns a context
+// carrying its API key. Fatal on error.
+func MustCreateUser(tb testing.TB, db *sqlite.DB, user *lil.User) context.Context {
+	tb.Helper()
+	if err := sqlite.NewUserService(db).CreateUser(context.Background(), user); err != nil {
+		tb.Fatal(err)
+	}
+	return lil.NewContextWithUser(context.Background(), &lil.User{APIKey: user.APIKey})
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+// CSVFlushSize is the number of rows written before flushing a CSV response
+// to the client. Large result sets are never loaded into memory at once.
+const CSVFlushSize = 500
+
+// MaxImportSize is the maximum size of an uploaded import file, in bytes.
+const MaxImportSize = 10 << 20
+
+// shortCSVHeader is the header of the CSV representation of shorts. The
+// same format is accepted by the CSV import endpoint.
+var shortCSVHeader = []string{"key", "url", "activates_at", "expires_at", "created_at", "updated_at"}
+
+// csvWriter writes a CSV attachment to an HTTP response and flushes each
+// page to the client as soon as it has been written.
+type csvWriter struct {
+	*csv.Writer
+	w http.ResponseWriter
+}
+
+// newCSVWriter sets the CSV response headers, using filename as the
+// suggested download name, and returns a writer for the response body.
+func newCSVWriter(w http.ResponseWriter, filename string) *csvWriter {
+	w.Header().Set("Content-type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return &csvWriter{Writer: csv.NewWriter(w), w: w}
+}
+
+// FlushPage flushes buffered rows to the client.
+func (cw *csvWriter) FlushPage() error {
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// writeShortsCSV streams every short matching filter as CSV, ordered by key.
+func (s *Server) writeShortsCSV(w http.ResponseWriter, r *http.Request, filter lil.ShortFilter) {
+	// Headers are only sent along with the first row so that errors reading
+	// the first page can still be reported with a proper status code.
+	var cw *csvWriter
+	start := func() error {
+		if cw != nil {
+			return nil
+		}
+		cw = newCSVWriter(w, "shorts.csv")
+		return cw.Write(shortCSVHeader)
+	}
+
+	var n int
+	if err := s.ShortService.ForEachShort(r.Context(), filter, func(short *lil.Short) error {
+		if err := start(); err != nil {
+			return err
+		} else if err := cw.Write(shortCSVRecord(short)); err != nil {
+			return err
+		}
+
+		if n++; n%CSVFlushSize == 0 {
+			return cw.FlushPage()
+		}
+		return nil
+	}); err != nil && cw == nil {
+		Error(w, r, err)
+		return
+	} else if err != nil {
+		// Headers have already been sent so the error can only be logged.
+		LogError(r, err)
+		return
+	}
+
+	if err := start(); err != nil {
+		LogError(r, err)
+		return
+	} else if err := cw.FlushPage(); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// readUpload reads the file of an import request, sent either as a body of
+// the given media type or as the "file" field of a multipart form. name
+// describes the file in errors. Files larger than MaxImportSize are rejected.
+func readUpload(w http.ResponseWriter, r *http.Request, mediaType, name string) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+
+	var body io.Reader = r.Body
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-type")); mt != mediaType {
+		f, _, err := r.FormFile("file")
+		if err == http.ErrMissingFile || errors.Is(err, http.ErrNotMultipart) {
+			return nil, lil.Errorf(lil.EINVALID, "Missing %s.", name)
+		} else if err != nil {
+			return nil, lil.Errorf(lil.EINVALID, "Cannot read the %s, it must be smaller than %d MB.", name, MaxImportSize>>20)
+		}
+		defer f.Close()
+		body = f
+	}
+
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Cannot read the %s, it must be smaller than %d MB.", name, MaxImportSize>>20)
+	}
+	return buf, nil
+}
+
+// shortCSVRecord returns the CSV row for a short.
+func shortCSVRecord(short *lil.Short) []string {
+	return []string{
+		short.Key,
+		short.URL.String(),
+		formatCSVTime(short.ActivatesAt),
+		formatCSVTime(short.ExpiresAt),
+		formatCSVTime(&short.CreatedAt),
+		formatCSVTime(&short.UpdatedAt),
+	}
+}
+
+// formatCSVTime formats an optional timestamp in RFC 3339.
+func formatCSVTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseCSVTime parses an optional RFC 3339 timestamp.
+func parseCSVTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid date %q.", v)
+	}
+	return &t, nil
+}
+
+// shortCSVRow represents a row read from a CSV import. Err is set if the
+// row could not be parsed.
+type shortCSVRow struct {
+	Line  int
+	Short *lil.Short
+	Err   error
+}
+
+// readShortsCSV reads shorts from CSV data in the format written by
+// writeShortsCSV. Columns are matched by the header row, so only "url" is
+// required and unknown columns are ignored. Rows that cannot be parsed are
+// returned with an error rather than failing the whole import.
+func readShortsCSV(rd io.Reader) ([]shortCSVRow, error) {
+	cr := csv.NewReader(rd)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, lil.Errorf(lil.EINVALID, "Empty CSV file.")
+	} else if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid CSV file.")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, lil.Errorf(lil.EINVALID, "CSV file must have a \"url\" column.")
+	}
+
+	// field returns the named column of a record, or blank if missing.
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rows := make([]shortCSVRow, 0)
+	for {
+		var row shortCSVRow
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if e, ok := err.(*csv.ParseError); ok {
+			row.Line, row.Err = e.StartLine, lil.Errorf(lil.EINVALID, "Invalid CSV row.")
+		} else if err != nil {
+			return nil, err
+		} else {
+			row.Line, _ = cr.FieldPos(0)
+			row.Short, row.Err = parseShortCSVRecord(record, field)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseShortCSVRecord builds a short from a CSV row.
+func parseShortCSVRecord(record []string, field func([]string, string) string) (_ *lil.Short, err error) {
+	short := &lil.Short{Key: field(record, "key")}
+
+	u, err := url.ParseRequestURI(field(record, "url"))
+	if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid URL passed.")
+	}
+	short.URL = *u
+
+	if short.ActivatesAt, err = parseCSVTime(field(record, "activates_at")); err != nil {
+		return nil, err
+	} else if short.ExpiresAt, err = parseCSVTime(field(record, "expires_at")); err != nil {
+		return nil, err
+	}
+	return short, nil
+}
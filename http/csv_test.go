@@ -0,0 +1,139 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestServer_ShortsCSV(t *testing.T) {
+	ts, db := MustOpenServer(t)
+	defer MustCloseServer(t, ts, db)
+
+	ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+	s := NewShortService(NewClient(ts.URL))
+
+	// Ensure every short is exported, ordered by key.
+	t.Run("Export", func(t *testing.T) {
+		u, _ := url.Parse("https://example.com/a,b")
+		for _, key := range []string{"bbb", "aaa"} {
+			if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: key}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp := MustDoCSV(t, ctx, "GET", ts.URL+"/short.csv", "text/csv", nil)
+		body, _ := io.ReadAll(resp.Body)
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if got, want := resp.Header.Get("Content-type"), "text/csv; charset=utf-8"; got != want {
+			t.Fatalf("Content-type=%v, want %v", got, want)
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		if got, want := len(lines), 3; got != want {
+			t.Fatalf("len=%v, want %v: %s", got, want, body)
+		} else if got, want := lines[0], "key,url,activates_at,expires_at,created_at,updated_at"; got != want {
+			t.Fatalf("header=%v, want %v", got, want)
+		} else if !strings.HasPrefix(lines[1], `aaa,"https://example.com/a,b",,,`) {
+			t.Fatalf("unexpected row: %s", lines[1])
+		} else if !strings.HasPrefix(lines[2], "bbb,") {
+			t.Fatalf("unexpected row: %s", lines[2])
+		}
+	})
+
+	// Ensure valid rows of a CSV body are created and the others reported.
+	t.Run("Import", func(t *testing.T) {
+		csv := "url,key,expires_at\n" +
+			"https://example.com,imported,2100-01-01T00:00:00Z\n" +
+			"https://example.com,aaa,\n" +
+			"https://example.com,bad,tomorrow\n"
+		resp := MustDoCSV(t, ctx, "POST", ts.URL+"/short/import", "text/csv", strings.NewReader(csv))
+
+		var out struct {
+			Created int `json:"created"`
+			Errors  []struct {
+				Line int    `json:"line"`
+				Key  string `json:"key"`
+			} `json:"errors"`
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		} else if got, want := out.Created, 1; got != want {
+			t.Fatalf("Created=%v, want %v", got, want)
+		} else if got, want := len(out.Errors), 2; got != want {
+			t.Fatalf("len(Errors)=%v, want %v", got, want)
+		} else if got, want := out.Errors[0].Line, 3; got != want {
+			t.Fatalf("Line=%v, want %v", got, want)
+		} else if got, want := out.Errors[1].Line, 4; got != want {
+			t.Fatalf("Line=%v, want %v", got, want)
+		}
+
+		if short, err := s.FindShortByKey(ctx, "imported"); err != nil {
+			t.Fatal(err)
+		} else if short.ExpiresAt == nil || short.ExpiresAt.Year() != 2100 {
+			t.Fatalf("unexpected expiration: %v", short.ExpiresAt)
+		}
+	})
+
+	// Ensure a CSV file can be uploaded through a form.
+	t.Run("ImportMultipart", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if fw, err := mw.CreateFormFile("file", "shorts.csv"); err != nil {
+			t.Fatal(err)
+		} else if _, err := io.WriteString(fw, "key,url\nuploaded,https://example.com\n"); err != nil {
+			t.Fatal(err)
+		} else if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := MustDoCSV(t, ctx, "POST", ts.URL+"/short/import", mw.FormDataContentType(), &buf)
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if _, err := s.FindShortByKey(ctx, "uploaded"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Ensure imports larger than MaxImportSize are rejected.
+	t.Run("ErrImportTooLarge", func(t *testing.T) {
+		body := "key,url\n" + strings.Repeat("a", MaxImportSize)
+		resp := MustDoCSV(t, ctx, "POST", ts.URL+"/short/import", "text/csv", strings.NewReader(body))
+		if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+}
+
+// MustDoCSV sends a request with the given content type, authenticated with
+// the API key of the context user. JSON responses are accepted along with
+// CSV. The response body is closed with the test. Fatal on error.
+func MustDoCSV(tb testing.TB, ctx context.Context, method, u, contentType string, body io.Reader) *http.Response {
+	tb.Helper()
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-type", contentType)
+	req.Header.Set("Authorization", "Bearer "+lil.UserFromContext(ctx).APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -0,0 +1,13 @@
+package html
+
+func (e *Engine) AdminShortsView() (Renderer, error) {
+	return e.view("ui/views/admin-shorts.tmpl.html")
+}
+
+func (e *Engine) AdminUsersView() (Renderer, error) {
+	return e.view("ui/views/admin-users.tmpl.html")
+}
+
+func (e *Engine) AdminAuditView() (Renderer, error) {
+	return e.view("ui/views/admin-audit.tmpl.html")
+}
@@ -0,0 +1,5 @@
+package html
+
+func (e *Engine) ErrorView() (Renderer, error) {
+	return e.view("ui/views/error.tmpl.html")
+}
@@ -48,6 +48,9 @@ type renderData struct {
 	URL   *url.URL
 	Data  any
 	Flash string
+
+	// Token of the current session, submitted by every form.
+	CSRFToken string
 }
 
 func (ren *render) Render(w io.Writer, r *http.Request, data any) error {
@@ -57,6 +60,9 @@ func (ren *render) Render(w io.Writer, r *http.Request, data any) error {
 		Data:  data,
 		Flash: lil.FlashFromContext(r.Context()),
 	}
+	if session := lil.SessionFromContext(r.Context()); session != nil {
+		pass.CSRFToken = session.CSRFToken
+	}
 
 	return ren.tmpl.ExecuteTemplate(w, "base", pass)
 }
@@ -0,0 +1,13 @@
+package html
+
+func (e *Engine) SettingsView() (Renderer, error) {
+	return e.view("ui/views/settings.tmpl.html")
+}
+
+func (e *Engine) DeleteAccountView() (Renderer, error) {
+	return e.view("ui/views/settings-delete.tmpl.html")
+}
+
+func (e *Engine) SessionsView() (Renderer, error) {
+	return e.view("ui/views/sessions.tmpl.html")
+}
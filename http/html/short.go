@@ -7,3 +7,7 @@ func (e *Engine) ShortView() (Renderer, error) {
 func (e *Engine) NewShortView() (Renderer, error) {
 	return e.view("ui/views/new-short.tmpl.html")
 }
+
+func (e *Engine) InterstitialView() (Renderer, error) {
+	return e.view("ui/views/interstitial.tmpl.html")
+}
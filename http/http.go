@@ -1,21 +1,32 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 
 	"github.com/kriive/lil"
+	"github.com/kriive/lil/http/html"
 )
 
 // SessionCookieName is the name of the cookie used to store the session.
 const SessionCookieName = "session"
 
-// Session represents session data stored in a secure cookie.
+// Names of the form field & header carrying the CSRF token of the session.
+// Forms submit the field while scripts may set the header instead.
+const (
+	CSRFFormName   = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// Session represents session data stored in a secure cookie. The logged in
+// user is only referenced by the opaque ID of a server-side session.
 type Session struct {
-	UserID      int    `json:"userID"`
+	SessionID   string `json:"sessionID"`
 	RedirectURL string `json:"redirectURL"`
 	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
 }
 
 // SetFlash sets the flash cookie for the next request to read.
@@ -28,7 +39,29 @@ func SetFlash(w http.ResponseWriter, s string) {
 	})
 }
 
-// Error prints & optionally logs an error message.
+// contextKey represents an internal key for adding context fields.
+type contextKey int
+
+// List of context keys.
+const (
+	// Stores the view used to render errors to browsers.
+	errorViewContextKey = contextKey(iota + 1)
+)
+
+// newContextWithErrorView returns a new context with the view rendering
+// errors to browsers.
+func newContextWithErrorView(ctx context.Context, view html.Renderer) context.Context {
+	return context.WithValue(ctx, errorViewContextKey, view)
+}
+
+// errorViewFromContext returns the view rendering errors to browsers, if any.
+func errorViewFromContext(ctx context.Context) html.Renderer {
+	view, _ := ctx.Value(errorViewContextKey).(html.Renderer)
+	return view
+}
+
+// Error prints & optionally logs an error message. Browsers are shown an HTML
+// error page if an error view is available, API clients get a JSON object.
 func Error(w http.ResponseWriter, r *http.Request, err error) {
 	// Extract error code & message.
 	code, message := lil.ErrorCode(err), lil.ErrorMessage(err)
@@ -38,6 +71,25 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 		LogError(r, err)
 	}
 
+	if view := errorViewFromContext(r.Context()); view != nil && Negotiate(r) == "text/html" {
+		w.Header().Set("Content-type", "text/html; charset=utf-8")
+		w.WriteHeader(ErrorStatusCode(code))
+		if err := view.Render(w, r, struct {
+			StatusCode int
+			Code       string
+			Title      string
+			Message    string
+		}{
+			StatusCode: ErrorStatusCode(code),
+			Code:       code,
+			Title:      errorTitles[code],
+			Message:    message,
+		}); err != nil {
+			LogError(r, err)
+		}
+		return
+	}
+
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(ErrorStatusCode(code))
 	json.NewEncoder(w).Encode(&ErrorResponse{Error: message})
@@ -56,13 +108,27 @@ func LogError(r *http.Request, err error) {
 // lookup of application error codes to HTTP status codes.
 var codes = map[string]int{
 	lil.ECONFLICT:       http.StatusConflict,
+	lil.EEXPIRED:        http.StatusGone,
 	lil.EINVALID:        http.StatusBadRequest,
+	lil.ENOTACTIVE:      http.StatusForbidden,
 	lil.ENOTFOUND:       http.StatusNotFound,
 	lil.ENOTIMPLEMENTED: http.StatusNotImplemented,
 	lil.EUNAUTHORIZED:   http.StatusUnauthorized,
 	lil.EINTERNAL:       http.StatusInternalServerError,
 }
 
+// lookup of application error codes to the titles of HTML error pages.
+var errorTitles = map[string]string{
+	lil.ECONFLICT:       "this conflicts with something else",
+	lil.EEXPIRED:        "this link has expired",
+	lil.EINVALID:        "something is wrong with your request",
+	lil.ENOTACTIVE:      "this link is not active yet",
+	lil.ENOTFOUND:       "page not found",
+	lil.ENOTIMPLEMENTED: "not implemented",
+	lil.EUNAUTHORIZED:   "you are not allowed to do this",
+	lil.EINTERNAL:       "something went wrong",
+}
+
 // ErrorStatusCode returns the associated HTTP status code for a lil error code.
 func ErrorStatusCode(code string) int {
 	if v, ok := codes[code]; ok {
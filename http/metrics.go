@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/kriive/lil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics exposed at "/metrics".
+var (
+	requestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lil_http_requests_total",
+		Help: "Total number of HTTP requests by method, route pattern & status code.",
+	}, []string{"method", "route", "status"})
+
+	requestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "lil_http_request_duration_seconds",
+		Help: "Latency of HTTP requests by method & route pattern.",
+	}, []string{"method", "route"})
+
+	redirectCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lil_redirects_total",
+		Help: "Total number of redirects through shorts.",
+	})
+
+	loginCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lil_logins_total",
+		Help: "Total number of logins by provider.",
+	}, []string{"provider"})
+)
+
+// trackMetrics is middleware recording the count & latency of requests. The
+// route pattern is used instead of the path to keep the number of series low.
+func trackMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// The route pattern is only known once the request has been routed.
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" && pattern != "/*" {
+				route = pattern
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		requestCount.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		requestSeconds.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// handleMetrics handles the "GET /metrics" route. The route is only enabled
+// if a metrics token is set and must be requested with the token as bearer.
+// Metrics can also be served on a separate address, see MetricsAddr.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.MetricsToken == "" {
+		Error(w, r, lil.Errorf(lil.ENOTFOUND, "Page not found."))
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) != 1 {
+		Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "Invalid metrics token."))
+		return
+	}
+
+	promhttp.Handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	// Ensure metrics are served to requests bearing the metrics token.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t, func(s *Server) { s.MetricsToken = "secret" })
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		s := NewShortService(NewClient(ts.URL))
+
+		u, _ := url.Parse("https://example.com")
+		if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "example"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Follow the short as looking it up is not a redirect.
+		req, _ := http.NewRequest("GET", ts.URL+"/s/example", nil)
+		if resp, err := http.DefaultTransport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		} else {
+			resp.Body.Close()
+		}
+
+		req, _ = http.NewRequest("GET", ts.URL+"/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+		for _, want := range []string{
+			`lil_http_requests_total{method="POST",route="/short/new",status="200"}`,
+			`lil_http_request_duration_seconds_count{method="GET",route="/s/{key}"}`,
+			`lil_shorts_created_total `,
+			`lil_redirects_total `,
+		} {
+			if !strings.Contains(string(body), want) {
+				t.Fatalf("expected %s in metrics: %s", want, body)
+			}
+		}
+	})
+
+	// Ensure metrics are not exposed without the token.
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		ts, db := MustOpenServer(t, func(s *Server) { s.MetricsToken = "secret" })
+		defer MustCloseServer(t, ts, db)
+
+		req, _ := http.NewRequest("GET", ts.URL+"/metrics", nil)
+		req.Header.Set("Authorization", "Bearer invalid")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure the route is disabled unless a token is set.
+	t.Run("ErrDisabled", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		resp, err := http.Get(ts.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		}
+	})
+}
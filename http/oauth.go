@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/kriive/lil"
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+	googleoauth "golang.org/x/oauth2/google"
+	google "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// OAuthProvider represents an OAuth2 authentication provider that users can
+// log in with. Providers are registered on the Server and served under
+// "/oauth/{source}".
+type OAuthProvider interface {
+	// Source returns the auth source stored on the created auths. It is also
+	// used as the provider name in the routes.
+	Source() string
+
+	// Label returns the name displayed on the login page.
+	Label() string
+
+	// Open validates the provider settings. Called when the server opens.
+	Open(ctx context.Context) error
+
+	// AuthCodeURL returns the URL of the provider consent page. The nonce is
+	// only used by providers which issue ID tokens.
+	AuthCodeURL(state, nonce, redirectURL string) string
+
+	// Exchange converts an authorization code into a token.
+	Exchange(ctx context.Context, code, redirectURL string) (*oauth2.Token, error)
+
+	// Profile fetches the profile of the user which owns the token.
+	Profile(ctx context.Context, tok *oauth2.Token, nonce string) (*OAuthProfile, error)
+}
+
+// OAuthProfile represents the user details returned by an OAuthProvider.
+type OAuthProfile struct {
+	// User ID on the provider.
+	ID string
+
+	Name    string
+	Email   string
+	Picture string
+}
+
+// GitHubProvider represents GitHub as an OAuthProvider.
+type GitHubProvider struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// Source returns lil.AuthSourceGitHub.
+func (p *GitHubProvider) Source() string { return lil.AuthSourceGitHub }
+
+// Label returns the name displayed on the login page.
+func (p *GitHubProvider) Label() string { return "github" }
+
+// Open validates the client credentials.
+func (p *GitHubProvider) Open(ctx context.Context) error {
+	if p.ClientID == "" {
+		return fmt.Errorf("github client id required")
+	} else if p.ClientSecret == "" {
+		return fmt.Errorf("github client secret required")
+	}
+	return nil
+}
+
+// AuthCodeURL returns the URL of the GitHub consent page.
+func (p *GitHubProvider) AuthCodeURL(state, nonce, redirectURL string) string {
+	return p.config(redirectURL).AuthCodeURL(state)
+}
+
+// Exchange converts an authorization code into a token.
+func (p *GitHubProvider) Exchange(ctx context.Context, code, redirectURL string) (*oauth2.Token, error) {
+	return p.config(redirectURL).Exchange(ctx, code)
+}
+
+// Profile fetches the GitHub user which owns the token.
+func (p *GitHubProvider) Profile(ctx context.Context, tok *oauth2.Token, nonce string) (*OAuthProfile, error) {
+	// Create a new GitHub API client.
+	client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: tok.AccessToken},
+	)))
+
+	// Fetch user information for the currently authenticated user.
+	// Require that we at least receive a user ID from GitHub.
+	u, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch github user: %s", err)
+	} else if u.ID == nil {
+		return nil, fmt.Errorf("user ID not returned by GitHub, cannot authenticate user")
+	}
+
+	// Email is not necessarily available for all accounts. If it is, store it
+	// so we can link together multiple OAuth providers.
+	profile := &OAuthProfile{ID: strconv.FormatInt(*u.ID, 10)}
+	if u.Name != nil {
+		profile.Name = *u.Name
+	} else if u.Login != nil {
+		profile.Name = *u.Login
+	}
+	if u.Email != nil {
+		profile.Email = *u.Email
+	}
+	return profile, nil
+}
+
+func (p *GitHubProvider) config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{},
+		Endpoint:     githuboauth.Endpoint,
+	}
+}
+
+// GoogleProvider represents Google as an OAuthProvider.
+type GoogleProvider struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// Source returns lil.AuthSourceGoogle.
+func (p *GoogleProvider) Source() string { return lil.AuthSourceGoogle }
+
+// Label returns the name displayed on the login page.
+func (p *GoogleProvider) Label() string { return "google" }
+
+// Open validates the client credentials.
+func (p *GoogleProvider) Open(ctx context.Context) error {
+	if p.ClientID == "" {
+		return fmt.Errorf("google client id required")
+	} else if p.ClientSecret == "" {
+		return fmt.Errorf("google client secret required")
+	}
+	return nil
+}
+
+// AuthCodeURL returns the URL of the Google consent page.
+func (p *GoogleProvider) AuthCodeURL(state, nonce, redirectURL string) string {
+	return p.config(redirectURL).AuthCodeURL(state)
+}
+
+// Exchange converts an authorization code into a token.
+func (p *GoogleProvider) Exchange(ctx context.Context, code, redirectURL string) (*oauth2.Token, error) {
+	return p.config(redirectURL).Exchange(ctx, code)
+}
+
+// Profile fetches the Google user which owns the token.
+func (p *GoogleProvider) Profile(ctx context.Context, tok *oauth2.Token, nonce string) (*OAuthProfile, error) {
+	g, err := google.NewService(ctx, option.WithTokenSource(oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: tok.AccessToken},
+	)))
+	if err != nil {
+		return nil, fmt.Errorf("google client creation error: %s", err)
+	}
+
+	c, err := google.NewUserinfoV2Service(g).Me.Get().Do()
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch google user: %s", err)
+	} else if c.Id == "" {
+		return nil, fmt.Errorf("user ID not returned by Google, cannot authenticate user")
+	}
+
+	return &OAuthProfile{
+		ID:      c.Id,
+		Name:    c.Name,
+		Email:   c.Email,
+		Picture: c.Picture,
+	}, nil
+}
+
+func (p *GoogleProvider) config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes: []string{
+			"openid",
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+		Endpoint: googleoauth.Endpoint,
+	}
+}
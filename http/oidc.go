@@ -0,0 +1,366 @@
+package http
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kriive/lil"
+	"golang.org/x/oauth2"
+)
+
+// Default claims mapped onto the user by an OIDCProvider.
+const (
+	DefaultOIDCNameClaim    = "name"
+	DefaultOIDCEmailClaim   = "email"
+	DefaultOIDCPictureClaim = "picture"
+)
+
+// oidcClockSkew is the difference tolerated between the clocks of the issuer
+// and the server when checking the "iat" & "nbf" claims of ID tokens.
+const oidcClockSkew = time.Minute
+
+// OIDCProvider represents a generic OpenID Connect identity provider as an
+// OAuthProvider. The provider endpoints & signing keys are discovered from
+// the issuer so only the issuer URL & client credentials need to be set.
+type OIDCProvider struct {
+	// Name displayed on the login page.
+	Name string
+
+	// Issuer URL, as returned in the "iss" claim of the ID tokens.
+	Issuer string
+
+	// OAuth client credentials registered with the issuer.
+	ClientID     string
+	ClientSecret string
+
+	// Additional scopes requested alongside "openid".
+	Scopes []string
+
+	// Claims of the ID token used to fill in the user's details.
+	// Default to the standard "name", "email" & "picture" claims.
+	NameClaim    string
+	EmailClaim   string
+	PictureClaim string
+
+	// Client used for discovery & key retrieval. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Returns the current time. Defaults to time.Now().
+	// Can be mocked for tests.
+	Now func() time.Time
+
+	// Endpoints read from the discovery document.
+	endpoint oauth2.Endpoint
+	jwksURL  string
+
+	// Signing keys of the issuer by key ID.
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// OIDCClaims represents the user details read from a verified ID token.
+type OIDCClaims struct {
+	Subject string
+	Nonce   string
+	Name    string
+	Email   string
+	Picture string
+}
+
+// Open fetches the discovery document of the issuer.
+func (p *OIDCProvider) Open(ctx context.Context) error {
+	if p.Issuer == "" {
+		return fmt.Errorf("oidc issuer required")
+	} else if p.ClientID == "" {
+		return fmt.Errorf("oidc client id required")
+	} else if p.ClientSecret == "" {
+		return fmt.Errorf("oidc client secret required")
+	}
+
+	if p.Name == "" {
+		p.Name = "oidc"
+	}
+	if p.NameClaim == "" {
+		p.NameClaim = DefaultOIDCNameClaim
+	}
+	if p.EmailClaim == "" {
+		p.EmailClaim = DefaultOIDCEmailClaim
+	}
+	if p.PictureClaim == "" {
+		p.PictureClaim = DefaultOIDCPictureClaim
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return fmt.Errorf("oidc discovery: %w", err)
+	} else if doc.Issuer != p.Issuer {
+		return fmt.Errorf("oidc discovery: issuer mismatch: %q", doc.Issuer)
+	} else if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return fmt.Errorf("oidc discovery: incomplete provider metadata")
+	}
+
+	p.endpoint = oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint}
+	p.jwksURL = doc.JWKSURI
+	return nil
+}
+
+// Source returns lil.AuthSourceOIDC.
+func (p *OIDCProvider) Source() string { return lil.AuthSourceOIDC }
+
+// Label returns the name displayed on the login page.
+func (p *OIDCProvider) Label() string { return p.Name }
+
+// AuthCodeURL returns the URL of the issuer consent page. The nonce is
+// returned in the ID token to bind it to the session.
+func (p *OIDCProvider) AuthCodeURL(state, nonce, redirectURL string) string {
+	return p.config(redirectURL).AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange converts an authorization code into a token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, redirectURL string) (*oauth2.Token, error) {
+	return p.config(redirectURL).Exchange(ctx, code)
+}
+
+// Profile verifies the ID token issued along with tok and returns the user
+// details from its claims.
+func (p *OIDCProvider) Profile(ctx context.Context, tok *oauth2.Token, nonce string) (*OAuthProfile, error) {
+	rawIDToken, _ := tok.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("id token not returned by provider, cannot authenticate user")
+	}
+
+	claims, err := p.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify id token: %s", err)
+	} else if claims.Nonce != nonce {
+		return nil, fmt.Errorf("oidc nonce mismatch")
+	}
+
+	return &OAuthProfile{
+		ID:      claims.Subject,
+		Name:    claims.Name,
+		Email:   claims.Email,
+		Picture: claims.Picture,
+	}, nil
+}
+
+func (p *OIDCProvider) config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       append([]string{"openid"}, p.Scopes...),
+		Endpoint:     p.endpoint,
+	}
+}
+
+// Verify checks the signature & standard claims of a raw ID token and
+// returns the mapped user claims. The email is dropped unless the issuer
+// reports it as verified, as users are linked together by email address.
+func (p *OIDCProvider) Verify(ctx context.Context, rawIDToken string) (*OIDCClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid id token header: %w", err)
+	}
+
+	var hash crypto.Hash
+	switch header.Alg {
+	case "RS256":
+		hash = crypto.SHA256
+	case "RS384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported id token algorithm: %q", header.Alg)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token signature: %w", err)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+		return nil, fmt.Errorf("invalid id token signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid id token claims: %w", err)
+	}
+
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return nil, fmt.Errorf("id token issued by %q", iss)
+	} else if !audienceContains(claims["aud"], p.ClientID) {
+		return nil, fmt.Errorf("id token not issued for this client")
+	} else if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("id token expired")
+	} else if iat, ok := claims["iat"].(float64); !ok || now.Add(oidcClockSkew).Before(time.Unix(int64(iat), 0)) {
+		return nil, fmt.Errorf("id token issued in the future")
+	} else if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("id token not valid yet")
+	}
+
+	c := &OIDCClaims{
+		Subject: claimString(claims, "sub"),
+		Nonce:   claimString(claims, "nonce"),
+		Name:    claimString(claims, p.NameClaim),
+		Email:   claimString(claims, p.EmailClaim),
+		Picture: claimString(claims, p.PictureClaim),
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("id token has no subject")
+	}
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		c.Email = ""
+	}
+	return c, nil
+}
+
+// key returns the signing key with the given ID. The key set is fetched again
+// if the ID is unknown, in case the issuer rotated its keys.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("cannot fetch oidc keys: %w", err)
+	}
+
+	p.keys = make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown id token signing key: %q", kid)
+}
+
+// findKey returns the key with the given ID. If the token does not name a
+// key, the only key of the issuer is used.
+func (p *OIDCProvider) findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// getJSON fetches u and decodes the JSON response body into v.
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v any) error {
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(seg string, v any) error {
+	buf, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// audienceContains returns true if the "aud" claim includes clientID. The
+// claim can either be a single string or an array of strings.
+func audienceContains(aud any, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []any:
+		for _, v := range aud {
+			if v == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimString returns the claim with the given name if it is a string.
+func claimString(claims map[string]any, name string) string {
+	v, _ := claims[name].(string)
+	return v
+}
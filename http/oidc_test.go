@@ -0,0 +1,284 @@
+package http
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestOIDCProvider_Verify(t *testing.T) {
+	// Ensure a valid ID token is accepted & its claims are mapped.
+	t.Run("OK", func(t *testing.T) {
+		issuer := NewMockIssuer(t)
+		defer issuer.Close()
+		p := issuer.MustOpenProvider(t)
+		p.NameClaim = "preferred_username"
+
+		claims := issuer.Claims()
+		claims["preferred_username"] = "susy"
+		if c, err := p.Verify(context.Background(), issuer.Sign(claims)); err != nil {
+			t.Fatal(err)
+		} else if got, want := c.Subject, "1234"; got != want {
+			t.Fatalf("Subject=%v, want %v", got, want)
+		} else if got, want := c.Name, "susy"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		} else if got, want := c.Email, "susy@example.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if got, want := c.Picture, "https://example.com/susy.png"; got != want {
+			t.Fatalf("Picture=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure unverified email addresses are not used to link users.
+	t.Run("UnverifiedEmail", func(t *testing.T) {
+		issuer := NewMockIssuer(t)
+		defer issuer.Close()
+		p := issuer.MustOpenProvider(t)
+
+		for name, fn := range map[string]func(map[string]any){
+			"False":   func(c map[string]any) { c["email_verified"] = false },
+			"Missing": func(c map[string]any) { delete(c, "email_verified") },
+			"String":  func(c map[string]any) { c["email_verified"] = "true" },
+		} {
+			claims := issuer.Claims()
+			fn(claims)
+			if c, err := p.Verify(context.Background(), issuer.Sign(claims)); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			} else if c.Email != "" {
+				t.Fatalf("%s: unexpected email: %q", name, c.Email)
+			}
+		}
+	})
+
+	// Ensure tokens that fail validation are rejected.
+	t.Run("Invalid", func(t *testing.T) {
+		issuer := NewMockIssuer(t)
+		defer issuer.Close()
+		p := issuer.MustOpenProvider(t)
+
+		for name, fn := range map[string]func(map[string]any){
+			"Expired":  func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+			"Audience": func(c map[string]any) { c["aud"] = []string{"other"} },
+			"Issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+			"Subject":  func(c map[string]any) { delete(c, "sub") },
+			"IssuedAt": func(c map[string]any) { delete(c, "iat") },
+			"Future":   func(c map[string]any) { c["iat"] = time.Now().Add(time.Hour).Unix() },
+			"NotValid": func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		} {
+			claims := issuer.Claims()
+			fn(claims)
+			if _, err := p.Verify(context.Background(), issuer.Sign(claims)); err == nil {
+				t.Fatalf("%s: expected error", name)
+			}
+		}
+
+		// Tamper with the payload of a valid token.
+		parts := strings.Split(issuer.Sign(issuer.Claims()), ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+		if _, err := p.Verify(context.Background(), strings.Join(parts, ".")); err == nil {
+			t.Fatal("expected signature error")
+		}
+	})
+}
+
+func TestServer_OAuthOIDC(t *testing.T) {
+	issuer := NewMockIssuer(t)
+	defer issuer.Close()
+
+	ts, db := MustOpenServer(t, func(s *Server) {
+		s.OAuthProviders = append(s.OAuthProviders, issuer.MustOpenProvider(t))
+	})
+	defer MustCloseServer(t, ts, db)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// Start the login flow & read the state & nonce sent to the issuer.
+	resp, err := client.Get(ts.URL + "/oauth/oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	} else if got, want := loc.Path, "/authorize"; got != want {
+		t.Fatalf("Location=%v, want %v", got, want)
+	}
+	issuer.SetNonce(loc.Query().Get("nonce"))
+
+	// Return to the callback as the issuer would.
+	resp, err = client.Get(ts.URL + "/oauth/oidc/callback?code=CODE&state=" + url.QueryEscape(loc.Query().Get("state")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusFound; got != want {
+		t.Fatalf("StatusCode=%v, want %v", got, want)
+	}
+
+	source, sourceID := lil.AuthSourceOIDC, "1234"
+	if auths, _, err := sqlite.NewAuthService(db).FindAuths(context.Background(), lil.AuthFilter{Source: &source, SourceID: &sourceID}); err != nil {
+		t.Fatal(err)
+	} else if len(auths) != 1 {
+		t.Fatalf("len=%v, want 1", len(auths))
+	} else if got, want := auths[0].User.Email, "susy@example.com"; got != want {
+		t.Fatalf("Email=%v, want %v", got, want)
+	} else if got, want := auths[0].AvatarURL(64), "https://example.com/susy.png"; got != want {
+		t.Fatalf("AvatarURL=%v, want %v", got, want)
+	}
+}
+
+// Ensure providers which are not registered cannot be used to log in.
+func TestServer_OAuthProviderNotFound(t *testing.T) {
+	ts, db := MustOpenServer(t, func(s *Server) {
+		s.OAuthProviders = []OAuthProvider{&GitHubProvider{ClientID: "ID", ClientSecret: "SECRET"}}
+	})
+	defer MustCloseServer(t, ts, db)
+
+	for path, want := range map[string]int{
+		"/oauth/github":          http.StatusFound,
+		"/oauth/google":          http.StatusNotFound,
+		"/oauth/google/callback": http.StatusNotFound,
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != want {
+			t.Fatalf("%s: StatusCode=%v, want %v", path, got, want)
+		}
+	}
+}
+
+// MockIssuer is an OpenID Connect issuer serving discovery, keys & tokens.
+type MockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	nonce string
+}
+
+// NewMockIssuer returns a running mock issuer with a new signing key.
+func NewMockIssuer(tb testing.TB) *MockIssuer {
+	tb.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	issuer := &MockIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		claims := issuer.Claims()
+		issuer.mu.Lock()
+		claims["nonce"] = issuer.nonce
+		issuer.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "ACCESS",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     issuer.Sign(claims),
+		})
+	})
+	issuer.Server = httptest.NewServer(mux)
+
+	return issuer
+}
+
+// MustOpenProvider returns a provider configured for the issuer. Fatal on error.
+func (issuer *MockIssuer) MustOpenProvider(tb testing.TB) *OIDCProvider {
+	tb.Helper()
+	p := &OIDCProvider{
+		Issuer:       issuer.URL,
+		ClientID:     "CLIENT",
+		ClientSecret: "SECRET",
+	}
+	if err := p.Open(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return p
+}
+
+// SetNonce sets the nonce included in the tokens issued by the token endpoint.
+func (issuer *MockIssuer) SetNonce(nonce string) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	issuer.nonce = nonce
+}
+
+// Claims returns a new set of valid claims for the test client.
+func (issuer *MockIssuer) Claims() map[string]any {
+	return map[string]any{
+		"iss":            issuer.URL,
+		"aud":            "CLIENT",
+		"sub":            "1234",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"name":           "Susy",
+		"email":          "susy@example.com",
+		"email_verified": true,
+		"picture":        "https://example.com/susy.png",
+	}
+}
+
+// Sign returns an RS256 signed ID token for claims.
+func (issuer *MockIssuer) Sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, issuer.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -16,20 +17,26 @@ import (
 	"github.com/kriive/lil"
 	"github.com/kriive/lil/http/assets"
 	"github.com/kriive/lil/http/html"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/crypto/acme/autocert"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
-	"golang.org/x/oauth2/google"
 )
 
 const ShutdownTimeout = time.Second * 5
 
+// SessionTouchInterval is the minimum delay between two updates of the last
+// use of a session.
+const SessionTouchInterval = time.Minute
+
 type Server struct {
 	ln     net.Listener
 	server *http.Server
 	router chi.Router
 	sc     *securecookie.SecureCookie
 
+	// Listener & server for metrics, if served on a separate address.
+	metricsLn     net.Listener
+	metricsServer *http.Server
+
 	// Bind address & domain for the server's listener.
 	// If domain is specified, server is run on TLS using acme/autocert.
 	Addr   string
@@ -39,30 +46,60 @@ type Server struct {
 	HashKey  string
 	BlockKey string
 
-	// GitHub OAuth settings.
-	GitHubClientID     string
-	GitHubClientSecret string
+	// Providers users can log in with, in the order shown on the login page.
+	// Providers are optional: only the registered ones are enabled.
+	OAuthProviders []OAuthProvider
 
-	// Google OAuth settings.
-	GoogleClientID     string
-	GoogleClientSecret string
+	// If set, the login page lets anyone log in as any user without
+	// authentication. Only meant for local development, so the server
+	// refuses to start if a TLS domain is set.
+	DevLogin bool
 
 	// Link length
 	KeyLength int
 	Alphabet  string
 
+	// Rules applied to keys chosen by the user.
+	KeyPolicy lil.KeyPolicy
+
+	// Secret salt used to hash client IPs when recording clicks. Required
+	// if ClickService is set.
+	ClickSalt string
+
+	// If set, metrics are served at "/metrics" to requests bearing this token.
+	MetricsToken string
+
+	// If set, metrics are also served without authentication on a separate
+	// listener bound to this address. It should not be publicly reachable.
+	MetricsAddr string
+
 	// Services used by the various HTTP routes.
-	AuthService  lil.AuthService
-	ShortService lil.ShortService
-	UserService  lil.UserService
+	AuthService    lil.AuthService
+	ShortService   lil.ShortService
+	UserService    lil.UserService
+	TeamService    lil.TeamService
+	AuditService   lil.AuditService
+	TokenService   lil.TokenService
+	SessionService lil.SessionService
+
+	// Records redirects, if set.
+	ClickService lil.ClickService
 
 	// Views
 	Views struct {
-		IndexView       html.Renderer
-		ShortsIndexView html.Renderer
-		LoginView       html.Renderer
-		ShortView       html.Renderer
-		NewShort        html.Renderer
+		IndexView         html.Renderer
+		ShortsIndexView   html.Renderer
+		LoginView         html.Renderer
+		ShortView         html.Renderer
+		NewShort          html.Renderer
+		InterstitialView  html.Renderer
+		ErrorView         html.Renderer
+		SettingsView      html.Renderer
+		DeleteAccountView html.Renderer
+		SessionsView      html.Renderer
+		AdminShortsView   html.Renderer
+		AdminUsersView    html.Renderer
+		AdminAuditView    html.Renderer
 	}
 }
 
@@ -75,14 +112,21 @@ func NewServer() *Server {
 	// Our router is wrapped by another function handler to perform some
 	// middleware-like tasks that cannot be performed by actual middleware.
 	// This includes changing route paths for JSON endpoints & overridding methods.
-	s.server.Handler = http.HandlerFunc(s.serveHTTP)
+	s.server.Handler = http.HandlerFunc(s.ServeHTTP)
+
+	// Record the count & latency of every request.
+	s.router.Use(trackMetrics)
 
 	// Setup endpoint to display deployed version.
 	s.router.Get("/debug/version", s.handleVersion)
 	s.router.Get("/debug/commit", s.handleCommit)
 
+	// Setup endpoint to expose metrics.
+	s.router.Get("/metrics", s.handleMetrics)
+
 	router := chi.NewRouter()
 	router.Use(s.authenticate)
+	router.Use(s.verifyCSRF)
 	router.Use(loadFlash)
 
 	router.Handle("/assets/*", http.StripPrefix("/assets/", http.FileServer(http.FS(assets.FS))))
@@ -97,10 +141,20 @@ func NewServer() *Server {
 	router.Group(func(r chi.Router) {
 		r.Use(s.requireAuth)
 		s.registerShortPrivateRoutes(r)
+		s.registerUserRoutes(r)
+		s.registerTeamRoutes(r)
+		s.registerTokenRoutes(r)
+		s.registerSettingsRoutes(r)
+		s.registerAdminRoutes(r)
 	})
 
 	router.Get("/", s.handleIndex())
 
+	// Report unknown routes like any other missing resource.
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, lil.Errorf(lil.ENOTFOUND, "Page not found."))
+	})
+
 	s.router.Mount("/", router)
 
 	return s
@@ -176,51 +230,51 @@ func (s *Server) URL() string {
 func (s *Server) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
 	defer cancel()
+
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	return s.server.Shutdown(ctx)
 }
 
-// OAuth2Config returns the GitHub OAuth2 configuration.
-func (s *Server) OAuth2Config(provider string) *oauth2.Config {
-	switch provider {
-	case lil.AuthSourceGitHub:
-		return &oauth2.Config{
-			ClientID:     s.GitHubClientID,
-			ClientSecret: s.GitHubClientSecret,
-			Scopes:       []string{},
-			Endpoint:     github.Endpoint,
-		}
-	case lil.AuthSourceGoogle:
-		return &oauth2.Config{
-			ClientID:     s.GoogleClientID,
-			ClientSecret: s.GoogleClientSecret,
-			Scopes: []string{
-				"openid",
-				"https://www.googleapis.com/auth/userinfo.email",
-				"https://www.googleapis.com/auth/userinfo.profile",
-			},
-			Endpoint: google.Endpoint,
+// OAuthProvider returns the registered provider for the given auth source.
+// Returns nil if the provider is not enabled.
+func (s *Server) OAuthProvider(source string) OAuthProvider {
+	for _, p := range s.OAuthProviders {
+		if p.Source() == source {
+			return p
 		}
 	}
-
 	return nil
 }
 
 func (s *Server) Open() (err error) {
+	// Never expose the unauthenticated login on a public domain.
+	if s.DevLogin && s.UseTLS() {
+		return fmt.Errorf("dev login cannot be enabled with a tls domain")
+	}
+
 	// Initialize our secure cookie with our encryption keys.
 	if err := s.openSecureCookie(); err != nil {
 		return err
 	}
 
-	if s.GitHubClientID == "" {
-		return fmt.Errorf("github client id required")
-	} else if s.GitHubClientSecret == "" {
-		return fmt.Errorf("github client secret required")
+	// Without a secret salt, hashed client IPs can be reversed by hashing
+	// every address.
+	if s.ClickService != nil && s.ClickSalt == "" {
+		return fmt.Errorf("click salt required")
 	}
 
-	if s.GoogleClientID == "" {
-		return fmt.Errorf("google client id required")
-	} else if s.GoogleClientSecret == "" {
-		return fmt.Errorf("google client secret required")
+	// Validate the login providers. Each source can only be registered once
+	// as it identifies the provider in the routes.
+	for _, p := range s.OAuthProviders {
+		if s.OAuthProvider(p.Source()) != p {
+			return fmt.Errorf("duplicate oauth provider: %s", p.Source())
+		} else if err := p.Open(context.Background()); err != nil {
+			return err
+		}
 	}
 
 	// Open a listener on our bind address.
@@ -237,6 +291,17 @@ func (s *Server) Open() (err error) {
 	// as trying to use an already open port) synchronously.
 	go s.server.Serve(s.ln)
 
+	// Serve metrics on their own listener, if enabled.
+	if s.MetricsAddr != "" {
+		if s.metricsLn, err = net.Listen("tcp", s.MetricsAddr); err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		s.metricsServer = &http.Server{Handler: mux}
+		go s.metricsServer.Serve(s.metricsLn)
+	}
+
 	return nil
 }
 
@@ -284,6 +349,22 @@ func (s *Server) session(r *http.Request) (Session, error) {
 	return session, nil
 }
 
+// login starts a new server-side session for the user and stores its ID in
+// the session cookie. Any OAuth state is cleared. The provider used to log in
+// is only recorded in metrics.
+func (s *Server) login(w http.ResponseWriter, r *http.Request, userID int, provider string) error {
+	sess := &lil.Session{
+		UserID:    userID,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if err := s.SessionService.CreateSession(r.Context(), sess); err != nil {
+		return err
+	}
+	loginCount.WithLabelValues(provider).Inc()
+	return s.setSession(w, Session{SessionID: sess.Secret})
+}
+
 // setSession creates a secure cookie with session data.
 func (s *Server) setSession(w http.ResponseWriter, session Session) error {
 	// Encode session data to JSON.
@@ -300,6 +381,7 @@ func (s *Server) setSession(w http.ResponseWriter, session Session) error {
 		Expires:  time.Now().Add(30 * 24 * time.Hour),
 		Secure:   s.UseTLS(),
 		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	})
 	return nil
 }
@@ -324,7 +406,14 @@ func ListenAndServeTLSRedirect(domain string) error {
 	}))
 }
 
-func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+// ServeHTTP implements http.Handler. This allows the server to be mounted
+// on another listener, such as an httptest.Server in tests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Let Error() render HTML pages for browsers.
+	if s.Views.ErrorView != nil {
+		r = r.WithContext(newContextWithErrorView(r.Context(), s.Views.ErrorView))
+	}
+
 	// Override method for forms passing "_method" value.
 	if r.Method == http.MethodPost {
 		switch v := r.PostFormValue("_method"); v {
@@ -354,23 +443,20 @@ func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
 // authenticate is middleware for loading session data from a cookie or API key header.
 func (s *Server) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Login via API key, if available.
+		// Login via API token or legacy API key, if available.
 		if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
-			apiKey := strings.TrimPrefix(v, "Bearer ")
-
-			// Lookup user by API key. Display error if not found.
-			// Otherwise set
-			users, _, err := s.UserService.FindUsers(r.Context(), lil.UserFilter{APIKey: &apiKey})
+			user, token, err := s.findBearerUser(r.Context(), strings.TrimPrefix(v, "Bearer "))
 			if err != nil {
 				Error(w, r, err)
 				return
-			} else if len(users) == 0 {
-				Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "Invalid API key."))
+			} else if user.IsSuspended() {
+				Error(w, r, lil.ErrUserSuspended)
 				return
 			}
 
-			// Update request context to include authenticated user.
-			r = r.WithContext(lil.NewContextWithUser(r.Context(), users[0]))
+			// Update request context to include authenticated user. Tokens
+			// restrict the routes available to the request.
+			r = r.WithContext(lil.NewContextWithToken(lil.NewContextWithUser(r.Context(), user), token))
 
 			// Delegate to next HTTP handler.
 			next.ServeHTTP(w, r)
@@ -380,12 +466,24 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 		// Read session from secure cookie.
 		session, _ := s.session(r)
 
-		// Read user, if available. Ignore if fetching assets.
-		if session.UserID != 0 {
-			if user, err := s.UserService.FindUserByID(r.Context(), session.UserID); err != nil {
-				log.Printf("cannot find session user: id=%d err=%s", session.UserID, err)
+		// Read user from the server-side session, if available. Revoked &
+		// expired sessions are ignored.
+		if session.SessionID != "" {
+			if sess, err := s.SessionService.FindSessionBySecret(r.Context(), session.SessionID); err != nil {
+				log.Printf("ignoring session: %s", err)
+			} else if sess.User.IsSuspended() {
+				log.Printf("ignoring session of suspended user: id=%d", sess.UserID)
 			} else {
-				r = r.WithContext(lil.NewContextWithUser(r.Context(), user))
+				// Avoid a write on every request.
+				if time.Since(sess.LastSeenAt) > SessionTouchInterval {
+					if err := s.SessionService.TouchSession(r.Context(), sess.ID); err != nil {
+						log.Printf("cannot touch session: id=%d err=%s", sess.ID, err)
+					}
+				}
+
+				user := sess.User
+				ctx := lil.NewContextWithUser(r.Context(), user)
+				r = r.WithContext(lil.NewContextWithSession(ctx, sess))
 			}
 		}
 
@@ -393,6 +491,64 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// verifyCSRF is middleware rejecting state-changing requests authenticated by
+// the session cookie which do not carry the CSRF token of the session. Requests
+// authenticated by a bearer secret cannot be forged by a browser so they are
+// not checked.
+func (s *Server) verifyCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session := lil.SessionFromContext(r.Context())
+		if session == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(CSRFHeaderName)
+		if token == "" {
+			token = r.PostFormValue(CSRFFormName)
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+			Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "Invalid CSRF token. Reload the page and try again."))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// findBearerUser returns the user & token authenticated by a bearer secret.
+// Secrets starting with lil.TokenPrefix are looked up as API tokens. Others
+// are looked up as the legacy API key of the user, which is returned as a
+// token granted every scope so that scope checks apply to it as well.
+func (s *Server) findBearerUser(ctx context.Context, secret string) (*lil.User, *lil.Token, error) {
+	if strings.HasPrefix(secret, lil.TokenPrefix) {
+		if s.TokenService == nil {
+			return nil, nil, lil.Errorf(lil.EUNAUTHORIZED, "Invalid API token.")
+		}
+
+		token, err := s.TokenService.FindTokenBySecret(ctx, secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return token.User, token, nil
+	}
+
+	// Lookup user by API key. Display error if not found.
+	users, _, err := s.UserService.FindUsers(ctx, lil.UserFilter{APIKey: &secret})
+	if err != nil {
+		return nil, nil, err
+	} else if len(users) == 0 {
+		return nil, nil, lil.Errorf(lil.EUNAUTHORIZED, "Invalid API key.")
+	}
+	return users[0], &lil.Token{UserID: users[0].ID, User: users[0], Name: "API key", Scopes: lil.Scopes}, nil
+}
+
 // requireNoAuth is middleware for requiring no authentication.
 // This is used if a user goes to log in but is already logged in.
 func (s *Server) requireNoAuth(next http.Handler) http.Handler {
@@ -418,6 +574,12 @@ func (s *Server) requireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		// API clients cannot follow the login flow.
+		if Negotiate(r) == "application/json" {
+			Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "You must be logged in."))
+			return
+		}
+
 		// Otherwise save the current URL (without scheme/host).
 		redirectURL := r.URL
 		redirectURL.Scheme, redirectURL.Host = "", ""
@@ -433,6 +595,20 @@ func (s *Server) requireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// requireScope returns middleware rejecting requests authenticated by an API
+// token lacking the scope. Other requests are not restricted.
+func (s *Server) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !lil.CanUseScope(r.Context(), scope) {
+				Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "This API token lacks the %q scope.", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // loadFlash is middleware for reading flash data from the cookie.
 // Data is only loaded once and then immediately cleared... hence the name "flash".
 func loadFlash(next http.Handler) http.Handler {
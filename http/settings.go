@@ -0,0 +1,392 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kriive/lil"
+)
+
+// registerSettingsRoutes is a helper function to register routes to a router.
+// These routes manage the account of the current user. They read & write
+// data using HTML or JSON, depending on HTTP Accept Header.
+func (s *Server) registerSettingsRoutes(r chi.Router) {
+	read, write := s.requireScope(lil.ScopeUserRead), s.requireScope(lil.ScopeUserWrite)
+	r.With(read).Get("/settings", s.handleSettings)
+	r.With(write).Patch("/settings", s.handleSettingsUpdate)
+	r.With(write).Delete("/settings", s.handleSettingsDelete)
+	r.With(read).Get("/settings/delete", s.handleSettingsDeleteConfirm)
+	r.With(write).Post("/settings/api-key", s.handleSettingsRotateAPIKey)
+	r.With(write).Delete("/settings/auths/{id}", s.handleSettingsAuthDelete)
+	r.With(read).Get("/settings/sessions", s.handleSettingsSessions)
+	r.With(write).Delete("/settings/sessions", s.handleSettingsSessionsRevoke)
+	r.With(write).Delete("/settings/sessions/{id}", s.handleSettingsSessionRevoke)
+	r.With(read, s.requireScope(lil.ScopeShortsRead)).Get("/settings/export", s.handleSettingsExport)
+	r.With(s.requireScope(lil.ScopeShortsWrite)).Post("/settings/import", s.handleSettingsImport)
+}
+
+// handleSettings handles the "GET /settings" route.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	s.renderSettings(w, r, nil, "")
+}
+
+// renderSettings renders the account of the current user along with their
+// tokens. If set, newToken & newAPIKey are shown with their secret as it
+// cannot be read again afterwards.
+func (s *Server) renderSettings(w http.ResponseWriter, r *http.Request, newToken *lil.Token, newAPIKey string) {
+	user, err := s.UserService.FindUserByID(r.Context(), lil.UserIDFromContext(r.Context()))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	var tokens []*lil.Token
+	if s.TokenService != nil {
+		if tokens, _, err = s.TokenService.FindTokens(r.Context(), lil.TokenFilter{}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			User   *lil.User    `json:"user"`
+			Tokens []*lil.Token `json:"tokens"`
+		}{
+			User:   user,
+			Tokens: tokens,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		if err := s.Views.SettingsView.Render(w, r, struct {
+			Account   *lil.User
+			Tokens    []*lil.Token
+			NewToken  *lil.Token
+			NewAPIKey string
+			Scopes    []string
+		}{
+			Account:   user,
+			Tokens:    tokens,
+			NewToken:  newToken,
+			NewAPIKey: newAPIKey,
+			Scopes:    lil.Scopes,
+		}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+}
+
+// handleSettingsUpdate handles the "PATCH /settings" route. It changes the
+// name & email of the current user.
+func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	var upd lil.UserUpdate
+	switch Negotiate(r) {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		name, email := r.PostFormValue("name"), r.PostFormValue("email")
+		upd.Name, upd.Email = &name, &email
+	}
+
+	user, err := s.UserService.UpdateUser(r.Context(), lil.UserIDFromContext(r.Context()), upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		SetFlash(w, "Successfully updated your profile.")
+		http.Redirect(w, r, "/settings", http.StatusFound)
+	}
+}
+
+// handleSettingsRotateAPIKey handles the "POST /settings/api-key" route. The
+// previous key stops working immediately and the new one is only shown once.
+func (s *Server) handleSettingsRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, err := s.UserService.RotateAPIKey(r.Context(), lil.UserIDFromContext(r.Context()))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			APIKey string `json:"apiKey"`
+		}{
+			APIKey: user.APIKey,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		s.renderSettings(w, r, nil, user.APIKey)
+	}
+}
+
+// handleSettingsAuthDelete handles the "DELETE /settings/auths/{id}" route.
+// It unlinks a login provider from the current user, unless it is the last.
+func (s *Server) handleSettingsAuthDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.AuthService.DeleteAuth(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully unlinked the login provider.")
+		http.Redirect(w, r, "/settings", http.StatusFound)
+	}
+}
+
+// handleSettingsDeleteConfirm handles the "GET /settings/delete" route. It
+// asks the user to confirm the deletion of their account.
+func (s *Server) handleSettingsDeleteConfirm(w http.ResponseWriter, r *http.Request) {
+	if err := s.Views.DeleteAccountView.Render(w, r, nil); err != nil {
+		Error(w, r, err)
+		return
+	}
+}
+
+// handleSettingsDelete handles the "DELETE /settings" route. The user must
+// confirm the deletion by typing their name. Their shorts are deleted along
+// with the account.
+func (s *Server) handleSettingsDelete(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Confirm string `json:"confirm"`
+	}
+	switch Negotiate(r) {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+	default:
+		body.Confirm = r.PostFormValue("confirm")
+	}
+
+	user := lil.UserFromContext(r.Context())
+	if body.Confirm != user.Name {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Type your name to confirm the deletion of your account."))
+		return
+	} else if err := s.UserService.DeleteUser(r.Context(), user.ID); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		// Clear the session as the user does not exist anymore.
+		if err := s.setSession(w, Session{}); err != nil {
+			log.Printf("http: cannot clear session: %s", err)
+		}
+		SetFlash(w, "Your account has been deleted.")
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// handleSettingsSessions handles the "GET /settings/sessions" route. It lists
+// the active sessions of the current user.
+func (s *Server) handleSettingsSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, n, err := s.SessionService.FindSessions(r.Context(), lil.SessionFilter{})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Sessions []*lil.Session `json:"sessions"`
+			N        int            `json:"n"`
+		}{
+			Sessions: sessions,
+			N:        n,
+		}); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		// Highlight the session used to view the page.
+		var currentID int
+		if sess := lil.SessionFromContext(r.Context()); sess != nil {
+			currentID = sess.ID
+		}
+
+		if err := s.Views.SessionsView.Render(w, r, struct {
+			Sessions  []*lil.Session
+			CurrentID int
+		}{
+			Sessions:  sessions,
+			CurrentID: currentID,
+		}); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+}
+
+// handleSettingsSessionRevoke handles the "DELETE /settings/sessions/{id}"
+// route. Revoking the current session logs the user out.
+func (s *Server) handleSettingsSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.SessionService.RevokeSession(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully revoked the session.")
+		http.Redirect(w, r, "/settings/sessions", http.StatusFound)
+	}
+}
+
+// handleSettingsSessionsRevoke handles the "DELETE /settings/sessions" route.
+// It revokes every session of the current user, including the current one.
+func (s *Server) handleSettingsSessionsRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := s.SessionService.RevokeUserSessions(r.Context(), lil.UserIDFromContext(r.Context())); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		if err := s.setSession(w, Session{}); err != nil {
+			log.Printf("http: cannot clear session: %s", err)
+		}
+		SetFlash(w, "You have been logged out everywhere.")
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}
+}
+
+// handleSettingsExport handles the "GET /settings/export" route. It
+// downloads the data of the current user in the export format, regardless of
+// the Accept header.
+func (s *Server) handleSettingsExport(w http.ResponseWriter, r *http.Request) {
+	e, err := lil.ExportUserData(r.Context(), s.UserService, s.ShortService, lil.UserIDFromContext(r.Context()), time.Now())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="lil-export.json"`)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleSettingsImport handles the "POST /settings/import" route. It creates
+// the shorts of an export for the current user, keeping their keys. The body
+// is either the export itself or a multipart form holding it in "file".
+// Shorts which cannot be created, for example because their key is taken,
+// are reported without failing the others.
+func (s *Server) handleSettingsImport(w http.ResponseWriter, r *http.Request) {
+	buf, err := readUpload(w, r, "application/json", "export file")
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	var e lil.Export
+	if err := json.Unmarshal(buf, &e); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	} else if err := e.Validate(); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// importError represents a short that could not be imported.
+	type importError struct {
+		Key   string `json:"key,omitempty"`
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+
+	resp := struct {
+		Created int           `json:"created"`
+		Errors  []importError `json:"errors"`
+	}{Errors: make([]importError, 0)}
+
+	for _, es := range e.Shorts {
+		short, err := es.Short()
+		if err == nil {
+			if err = s.assignKey(short); err == nil {
+				err = s.ShortService.CreateShort(r.Context(), short)
+			}
+		}
+
+		if err != nil {
+			if lil.ErrorCode(err) == lil.EINTERNAL {
+				LogError(r, err)
+			}
+			resp.Errors = append(resp.Errors, importError{
+				Key:   es.Key,
+				Code:  lil.ErrorCode(err),
+				Error: lil.ErrorMessage(err),
+			})
+			continue
+		}
+		resp.Created++
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		SetFlash(w, fmt.Sprintf("Imported %d shorts, %d failed.", resp.Created, len(resp.Errors)))
+		http.Redirect(w, r, "/settings", http.StatusFound)
+	}
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/http/html"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestServer_Settings(t *testing.T) {
+	// Ensure users can manage their account through the JSON endpoints.
+	t.Run("OK", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		if resp := MustDoJSON(t, ctx, "PATCH", ts.URL+"/settings", `{"name":"jill","email":"jill@example.com"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusOK)
+		}
+
+		resp := MustDoJSON(t, ctx, "POST", ts.URL+"/settings/api-key", "")
+		var rotated struct {
+			APIKey string `json:"apiKey"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&rotated); err != nil {
+			t.Fatal(err)
+		} else if rotated.APIKey == "" || rotated.APIKey == lil.UserFromContext(ctx).APIKey {
+			t.Fatalf("unexpected api key: %q", rotated.APIKey)
+		}
+
+		// The previous key stops working immediately.
+		if resp := MustDoJSON(t, ctx, "GET", ts.URL+"/settings", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+		ctx = lil.NewContextWithUser(context.Background(), &lil.User{APIKey: rotated.APIKey})
+
+		resp = MustDoJSON(t, ctx, "GET", ts.URL+"/settings", "")
+		var body struct {
+			User   *lil.User `json:"user"`
+			APIKey string    `json:"apiKey"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if got, want := body.User.Name, "jill"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		} else if got, want := body.User.Email, "jill@example.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if body.User.EmailVerified {
+			t.Fatal("expected changed email to be unverified")
+		} else if body.APIKey != "" {
+			t.Fatal("expected api key to be hidden as only its hash is stored")
+		}
+	})
+
+	// Ensure the API key cannot be read nor rotated with an API token.
+	t.Run("Token", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		resp := MustDoJSON(t, ctx, "POST", ts.URL+"/token", `{"name":"ci","scopes":["user:read","user:write"]}`)
+		var token lil.Token
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			t.Fatal(err)
+		}
+		tokenCtx := lil.NewContextWithUser(context.Background(), &lil.User{APIKey: token.Secret})
+
+		resp = MustDoJSON(t, tokenCtx, "GET", ts.URL+"/settings", "")
+		var body struct {
+			APIKey string `json:"apiKey"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if body.APIKey != "" {
+			t.Fatal("expected api key to be hidden")
+		}
+
+		if resp := MustDoJSON(t, tokenCtx, "POST", ts.URL+"/settings/api-key", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	// Ensure accounts are only deleted once confirmed.
+	t.Run("Delete", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		// Accounts cannot be deleted through the user routes, which have no
+		// confirmation.
+		userID := lil.UserFromContext(ctx).ID
+		if resp := MustDoJSON(t, ctx, "DELETE", ts.URL+"/user/"+strconv.Itoa(userID), ""); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+
+		if resp := MustDoJSON(t, ctx, "DELETE", ts.URL+"/settings", `{"confirm":"jane"}`); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusBadRequest)
+		} else if resp := MustDoJSON(t, ctx, "DELETE", ts.URL+"/settings", `{"confirm":"susy"}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusOK)
+		} else if resp := MustDoJSON(t, ctx, "GET", ts.URL+"/settings", ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	// Ensure shorts can be exported & imported into another account, keeping
+	// their keys. Taken keys are reported as conflicts and statuses are reset.
+	t.Run("Export", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		user0, user1 := &lil.User{Name: "susy"}, &lil.User{Name: "jill"}
+		ctx0, ctx1 := MustCreateUser(t, db, user0), MustCreateUser(t, db, user1)
+
+		// Shorts are created directly as the API key context carries no ID.
+		shortService := sqlite.NewShortService(db)
+		u, _ := url.Parse("https://example.com")
+		for _, key := range []string{"aaa", "bbb"} {
+			if err := shortService.CreateShort(lil.NewContextWithUser(context.Background(), user0), &lil.Short{Key: key, URL: *u}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp := MustDoJSON(t, ctx0, "GET", ts.URL+"/settings/export.json", "")
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if strings.Contains(string(body), lil.UserFromContext(ctx0).APIKey) {
+			t.Fatal("expected api key to be omitted")
+		}
+
+		var e lil.Export
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatal(err)
+		} else if got, want := e.Version, lil.ExportVersion; got != want {
+			t.Fatalf("Version=%v, want %v", got, want)
+		} else if got, want := e.User.Name, "susy"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		} else if got, want := len(e.Shorts), 2; got != want {
+			t.Fatalf("len(Shorts)=%v, want %v", got, want)
+		}
+
+		// Delete one short so that only the other one conflicts.
+		if err := shortService.DeleteShort(lil.NewContextWithUser(context.Background(), user0), "bbb"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Statuses set by administrators must not be carried over.
+		body = bytes.ReplaceAll(body, []byte(`"status":"active"`), []byte(`"status":"disabled"`))
+
+		resp = MustDoJSON(t, ctx1, "POST", ts.URL+"/settings/import", string(body))
+		var result struct {
+			Created int `json:"created"`
+			Errors  []struct {
+				Key  string `json:"key"`
+				Code string `json:"code"`
+			} `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		} else if got, want := result.Created, 1; got != want {
+			t.Fatalf("Created=%v, want %v", got, want)
+		} else if got, want := len(result.Errors), 1; got != want {
+			t.Fatalf("len(Errors)=%v, want %v", got, want)
+		} else if got, want := result.Errors[0].Key, "aaa"; got != want {
+			t.Fatalf("Key=%v, want %v", got, want)
+		} else if got, want := result.Errors[0].Code, lil.ECONFLICT; got != want {
+			t.Fatalf("Code=%v, want %v", got, want)
+		}
+
+		if short, err := shortService.FindShortByKey(lil.NewContextWithUser(context.Background(), user1), "bbb"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.OwnerID, user1.ID; got != want {
+			t.Fatalf("OwnerID=%v, want %v", got, want)
+		} else if got, want := short.Status, lil.ShortStatusActive; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure exports of unknown versions are rejected.
+	t.Run("ErrImportVersion", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+		if resp := MustDoJSON(t, ctx, "POST", ts.URL+"/settings/import", `{"version":99,"shorts":[]}`); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	// Ensure the settings page renders for browsers and only shows the API key
+	// once it is regenerated.
+	t.Run("HTML", func(t *testing.T) {
+		ts, db := MustOpenServer(t, func(s *Server) {
+			engine, err := html.NewEngine(html.FS)
+			if err != nil {
+				t.Fatal(err)
+			} else if s.Views.SettingsView, err = engine.SettingsView(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		apiKey := lil.UserFromContext(ctx).APIKey
+		req, err := http.NewRequest("POST", ts.URL+"/settings/api-key", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "new api key: <code>") || strings.Contains(string(body), apiKey) {
+			t.Fatalf("unexpected body: %s", body)
+		}
+	})
+}
@@ -1,10 +1,15 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/kriive/lil"
@@ -13,20 +18,38 @@ import (
 
 func (s *Server) registerShortPublicRoutes(r chi.Router) {
 	r.Handle("/s/{key}", s.handleShortenedURL())
+	r.Get("/s/{key}/info", s.handleShortInfo())
 }
 
 func (s *Server) registerShortPrivateRoutes(r chi.Router) {
-	r.Post("/short/new", s.handleShortURLCreate())
-	r.Get("/short/new", s.handleShortURLNew())
-	r.Delete("/s/{key}", s.handleShortURLDelete())
-	r.Get("/short", s.handleShortsIndex())
+	read, write := s.requireScope(lil.ScopeShortsRead), s.requireScope(lil.ScopeShortsWrite)
+	r.With(write).Post("/short/new", s.handleShortURLCreate())
+	r.With(read).Get("/short/new", s.handleShortURLNew())
+	r.With(write).Delete("/s/{key}", s.handleShortURLDelete())
+	r.With(write).Patch("/s/{key}", s.handleShortURLUpdate())
+	r.With(read).Get("/short", s.handleShortsIndex())
+	r.With(write).Post("/short/import", s.handleShortsImport())
 }
 
 // handleShortURLNew handles the "GET /short/new" route.
 // It renders an HTML form for editing a new dial.
 func (s *Server) handleShortURLNew() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := s.Views.ShortView.Render(w, r, nil); err != nil {
+		// List the teams of the user so the short can be shared on creation.
+		var teams []*lil.Team
+		if s.TeamService != nil {
+			var err error
+			if teams, _, err = s.TeamService.FindTeams(r.Context(), lil.TeamFilter{}); err != nil {
+				Error(w, r, err)
+				return
+			}
+		}
+
+		if err := s.Views.ShortView.Render(w, r, struct {
+			Teams []*lil.Team
+		}{
+			Teams: teams,
+		}); err != nil {
 			Error(w, r, err)
 			return
 		}
@@ -48,8 +71,66 @@ func (s *Server) handleShortURLDelete() http.HandlerFunc {
 			return
 		}
 
-		SetFlash(w, "Successfully deleted short "+key+".")
-		http.Redirect(w, r, "/short", http.StatusFound)
+		switch Negotiate(r) {
+		case "application/json":
+			w.Header().Set("Content-type", "application/json")
+			w.Write([]byte(`{}`))
+		default:
+			SetFlash(w, "Successfully deleted short "+key+".")
+			http.Redirect(w, r, "/short", http.StatusFound)
+		}
+	}
+}
+
+// handleShortURLUpdate handles the "PATCH /s/{key}" route.
+// It retargets a short, if the user is the owner. It reads & writes
+// data using HTML or JSON, depending on HTTP Accept Header.
+func (s *Server) handleShortURLUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "key")
+		if key == "" {
+			Error(w, r, lil.Errorf(lil.EINTERNAL, "empty URLParameter: shouldn't happen, did you forget to configure the route?"))
+			return
+		}
+
+		var upd lil.ShortUpdate
+		switch Negotiate(r) {
+		case "application/json":
+			if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+				Error(w, r, lil.Errorf(lil.EINVALID, "We couldn't parse the request body."))
+				return
+			}
+		default:
+			if v := r.FormValue("url"); v != "" {
+				url, err := url.ParseRequestURI(v)
+				if err != nil {
+					Error(w, r, lil.Errorf(lil.EINVALID, "Invalid URL passed."))
+					return
+				}
+				upd.URL = url
+			}
+			if v := r.FormValue("status"); v != "" {
+				upd.Status = &v
+			}
+		}
+
+		short, err := s.ShortService.UpdateShort(r.Context(), key, upd)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		switch Negotiate(r) {
+		case "application/json":
+			w.Header().Set("Content-type", "application/json")
+			if err := json.NewEncoder(w).Encode(short); err != nil {
+				LogError(r, err)
+				return
+			}
+		default:
+			SetFlash(w, "Successfully updated short "+key+".")
+			http.Redirect(w, r, "/short", http.StatusFound)
+		}
 	}
 }
 
@@ -61,7 +142,7 @@ func (s *Server) handleShortURLCreate() http.HandlerFunc {
 		short := &lil.Short{}
 
 		// Format returned data based on HTTP accept header.
-		switch r.Header.Get("Accept") {
+		switch Negotiate(r) {
 		case "application/json":
 			if err := json.NewDecoder(r.Body).Decode(short); err != nil {
 				Error(w, r, lil.Errorf(lil.EINVALID, "We couldn't parse the request body."))
@@ -75,15 +156,27 @@ func (s *Server) handleShortURLCreate() http.HandlerFunc {
 			}
 
 			short.URL = *url
-		}
+			short.Key = r.FormValue("key")
 
-		var err error
+			if short.ActivatesAt, err = parseFormTime(r.FormValue("activates_at")); err != nil {
+				Error(w, r, err)
+				return
+			} else if short.ExpiresAt, err = parseFormTime(r.FormValue("expires_at")); err != nil {
+				Error(w, r, err)
+				return
+			}
 
-		// Overwrite the possibly user-provided Key to avoid
-		// attacks and vanity URLs. A future version may actually
-		// permit those.
-		short.Key, err = generate.SecureStringFromAlphabet(s.KeyLength, s.Alphabet)
-		if err != nil {
+			if v := r.FormValue("team_id"); v != "" {
+				teamID, err := strconv.Atoi(v)
+				if err != nil {
+					Error(w, r, lil.Errorf(lil.EINVALID, "Invalid team passed."))
+					return
+				}
+				short.TeamID = &teamID
+			}
+		}
+
+		if err := s.assignKey(short); err != nil {
 			Error(w, r, err)
 			return
 		}
@@ -93,10 +186,11 @@ func (s *Server) handleShortURLCreate() http.HandlerFunc {
 			return
 		}
 
-		switch r.Header.Get("Accept") {
+		switch Negotiate(r) {
 		case "application/json":
+			w.Header().Set("Content-type", "application/json")
 			if err := json.NewEncoder(w).Encode(short); err != nil {
-				Error(w, r, err)
+				LogError(r, err)
 				return
 			}
 		default:
@@ -116,6 +210,32 @@ func (s *Server) handleShortURLCreate() http.HandlerFunc {
 	}
 }
 
+// assignKey validates the key requested for short against the key policy.
+// If no key was requested, a random key is generated from the alphabet.
+func (s *Server) assignKey(short *lil.Short) (err error) {
+	if short.Key != "" {
+		return s.KeyPolicy.Validate(short.Key)
+	}
+	short.Key, err = generate.SecureStringFromAlphabet(s.KeyLength, s.Alphabet)
+	return err
+}
+
+// formTimeLayout is the layout used by "datetime-local" HTML inputs.
+const formTimeLayout = "2006-01-02T15:04"
+
+// parseFormTime parses an optional UTC timestamp submitted through an HTML
+// form. Returns nil if v is empty.
+func parseFormTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(formTimeLayout, v)
+	if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid date passed.")
+	}
+	return &t, nil
+}
+
 func (s *Server) handleShortsIndex() http.HandlerFunc {
 	// findShortsResponse represents the output JSON struct for "GET /short".
 	type findShortsResponse struct {
@@ -127,13 +247,26 @@ func (s *Server) handleShortsIndex() http.HandlerFunc {
 		var filter lil.ShortFilter
 		switch r.Header.Get("Content-type") {
 		case "application/json":
-			if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+			if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
 				Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
 				return
 			}
 		default:
 			filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
 			filter.Limit = 20
+			if teamID, err := strconv.Atoi(r.URL.Query().Get("team")); err == nil {
+				filter.TeamID = &teamID
+			}
+			if v := r.URL.Query().Get("status"); v != "" {
+				filter.Status = &v
+			}
+		}
+
+		// CSV output is streamed page by page and always contains every
+		// matching short.
+		if Negotiate(r) == "text/csv" {
+			s.writeShortsCSV(w, r, filter)
+			return
 		}
 
 		// Fetch shorts from database.
@@ -144,7 +277,7 @@ func (s *Server) handleShortsIndex() http.HandlerFunc {
 		}
 
 		// Render output based on HTTP accept header.
-		switch r.Header.Get("Accept") {
+		switch Negotiate(r) {
 		case "application/json":
 			w.Header().Set("Content-type", "application/json")
 			if err := json.NewEncoder(w).Encode(findShortsResponse{
@@ -172,6 +305,82 @@ func (s *Server) handleShortsIndex() http.HandlerFunc {
 	}
 }
 
+// handleShortsImport handles the "POST /short/import" route. It creates
+// shorts from a CSV file, sent either as a "text/csv" body or as the "file"
+// field of a multipart form. Rows that fail are reported individually and
+// do not prevent the other rows from being imported.
+func (s *Server) handleShortsImport() http.HandlerFunc {
+	// importError represents a row that could not be imported.
+	type importError struct {
+		Line  int    `json:"line"`
+		Key   string `json:"key,omitempty"`
+		Error string `json:"error"`
+	}
+
+	// importShortsResponse represents the output JSON struct for "POST /short/import".
+	type importShortsResponse struct {
+		Created int           `json:"created"`
+		Errors  []importError `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		buf, err := readUpload(w, r, "text/csv", "CSV file")
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		rows, err := readShortsCSV(bytes.NewReader(buf))
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		resp := importShortsResponse{Errors: make([]importError, 0)}
+		for _, row := range rows {
+			if row.Err == nil {
+				if row.Err = s.assignKey(row.Short); row.Err == nil {
+					row.Err = s.ShortService.CreateShort(r.Context(), row.Short)
+				}
+			}
+
+			if row.Err != nil {
+				if lil.ErrorCode(row.Err) == lil.EINTERNAL {
+					LogError(r, row.Err)
+				}
+
+				var key string
+				if row.Short != nil {
+					key = row.Short.Key
+				}
+				resp.Errors = append(resp.Errors, importError{
+					Line:  row.Line,
+					Key:   key,
+					Error: lil.ErrorMessage(row.Err),
+				})
+				continue
+			}
+			resp.Created++
+		}
+
+		switch Negotiate(r) {
+		case "application/json":
+			w.Header().Set("Content-type", "application/json")
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				LogError(r, err)
+				return
+			}
+		default:
+			SetFlash(w, fmt.Sprintf("Imported %d shorts, %d failed.", resp.Created, len(resp.Errors)))
+			http.Redirect(w, r, "/short", http.StatusFound)
+		}
+	}
+}
+
+// handleShortenedURL handles the "GET /s/{key}" route. It redirects to the
+// original URL. Browsers are shown an interstitial page instead if the short
+// has been disabled, or flagged and the user has not chosen to proceed yet.
+// API clients get the status of flagged shorts as JSON in the latter case.
 func (s *Server) handleShortenedURL() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		key := chi.URLParam(r, "key")
@@ -180,12 +389,90 @@ func (s *Server) handleShortenedURL() http.HandlerFunc {
 			return
 		}
 
+		// API clients are not shown the interstitial pages.
+		browser := Negotiate(r) != "application/json"
+		proceed := r.URL.Query().Get("proceed") != ""
+
 		short, err := s.ShortService.SearchShort(r.Context(), key)
+		if errors.Is(err, lil.ErrShortDisabled) && browser {
+			s.renderInterstitial(w, r, http.StatusForbidden, &lil.Short{Key: key, Status: lil.ShortStatusDisabled})
+			return
+		} else if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		// API clients are told that the short is flagged instead, so that
+		// they can warn their users before proceeding.
+		if short.Status == lil.ShortStatusFlagged && !proceed && browser {
+			s.renderInterstitial(w, r, http.StatusOK, short)
+			return
+		} else if short.Status == lil.ShortStatusFlagged && !proceed {
+			w.Header().Set("Content-type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			if err := json.NewEncoder(w).Encode(newShortInfoResponse(short)); err != nil {
+				LogError(r, err)
+			}
+			return
+		}
+
+		s.recordClick(r, short.Key)
+		redirectCount.Inc()
+
+		// Shorts may be retargeted, disabled or expire so browsers must not
+		// remember where they point to.
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, short.URL.String(), http.StatusFound)
+	}
+}
+
+// handleShortInfo handles the "GET /s/{key}/info" route. It returns where a
+// short points to & its status as JSON, without recording a click. Disabled
+// shorts are reported as errors, as when following them.
+func (s *Server) handleShortInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		short, err := s.ShortService.SearchShort(r.Context(), chi.URLParam(r, "key"))
 		if err != nil {
 			Error(w, r, err)
 			return
 		}
 
-		http.Redirect(w, r, short.URL.String(), http.StatusMovedPermanently)
+		w.Header().Set("Content-type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(newShortInfoResponse(short)); err != nil {
+			LogError(r, err)
+			return
+		}
+	}
+}
+
+// shortInfoResponse represents the JSON returned by "GET /s/{key}/info", and
+// to API clients by "GET /s/{key}" instead of redirecting through a flagged
+// short.
+type shortInfoResponse struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// newShortInfoResponse returns the public details of short.
+func newShortInfoResponse(short *lil.Short) shortInfoResponse {
+	return shortInfoResponse{Key: short.Key, URL: short.URL.String(), Status: short.Status}
+}
+
+// renderInterstitial renders the page shown instead of redirecting through
+// a disabled or flagged short.
+func (s *Server) renderInterstitial(w http.ResponseWriter, r *http.Request, code int, short *lil.Short) {
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := s.Views.InterstitialView.Render(w, r, struct {
+		Short      *lil.Short
+		ProceedURL string
+	}{
+		Short:      short,
+		ProceedURL: "/s/" + url.PathEscape(short.Key) + "?proceed=1",
+	}); err != nil {
+		LogError(r, err)
+		return
 	}
 }
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/http/html"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Ensure shorts can be looked up without being followed.
+func TestServer_ShortInfo(t *testing.T) {
+	ts, db := MustOpenServer(t)
+	defer MustCloseServer(t, ts, db)
+
+	ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+	s := NewShortService(NewClient(ts.URL))
+
+	u, _ := url.Parse("https://example.com")
+	if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "example"}); err != nil {
+		t.Fatal(err)
+	}
+
+	redirects := MustCounterValue(t, redirectCount)
+	if short, err := s.SearchShort(ctx, "example"); err != nil {
+		t.Fatal(err)
+	} else if got, want := short.URL.String(), "https://example.com"; got != want {
+		t.Fatalf("URL=%v, want %v", got, want)
+	} else if got, want := short.Status, lil.ShortStatusActive; got != want {
+		t.Fatalf("Status=%v, want %v", got, want)
+	} else if got := MustCounterValue(t, redirectCount); got != redirects {
+		t.Fatalf("redirects=%v, want %v", got, redirects)
+	}
+
+	if _, err := s.SearchShort(ctx, "missing"); lil.ErrorCode(err) != lil.ENOTFOUND {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
+
+func TestServer_ShortStatus(t *testing.T) {
+	ts, db := MustOpenServer(t, func(s *Server) {
+		engine, err := html.NewEngine(html.FS)
+		if err != nil {
+			t.Fatal(err)
+		} else if s.Views.InterstitialView, err = engine.InterstitialView(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer MustCloseServer(t, ts, db)
+
+	ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+	s := NewShortService(NewClient(ts.URL))
+
+	u, _ := url.Parse("https://example.com")
+	flagged, disabled := lil.ShortStatusFlagged, lil.ShortStatusDisabled
+	if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "flagged", Status: flagged}); err != nil {
+		t.Fatal(err)
+	} else if err := s.CreateShort(ctx, &lil.Short{URL: *u, Key: "disabled"}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.UpdateShort(ctx, "disabled", lil.ShortUpdate{Status: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// Ensure browsers are warned before following a flagged short.
+	t.Run("Flagged", func(t *testing.T) {
+		resp, err := client.Get(ts.URL + "/s/flagged")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "proceed with caution") {
+			t.Fatalf("unexpected body: %s", body)
+		}
+
+		if resp, err = client.Get(ts.URL + "/s/flagged?proceed=1"); err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusFound; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if got, want := resp.Header.Get("Cache-Control"), "no-store"; got != want {
+			t.Fatalf("Cache-Control=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure API clients are told that a short is flagged rather than being
+	// redirected.
+	t.Run("FlaggedAPI", func(t *testing.T) {
+		if short, err := s.SearchShort(ctx, "flagged"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.URL.String(), "https://example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		} else if got, want := short.Status, lil.ShortStatusFlagged; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure disabled shorts are not followed.
+	t.Run("Disabled", func(t *testing.T) {
+		resp, err := client.Get(ts.URL + "/s/disabled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusForbidden; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "this link has been disabled") {
+			t.Fatalf("unexpected body: %s", body)
+		}
+
+		if _, err := s.SearchShort(ctx, "disabled"); lil.ErrorCode(err) != lil.ENOTACTIVE {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestServer_Error(t *testing.T) {
+	ts, db := MustOpenServer(t, func(s *Server) {
+		engine, err := html.NewEngine(html.FS)
+		if err != nil {
+			t.Fatal(err)
+		} else if s.Views.ErrorView, err = engine.ErrorView(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer MustCloseServer(t, ts, db)
+
+	// Ensure browsers are shown an HTML error page while API clients get a
+	// JSON error, depending on the Accept header.
+	for _, tt := range []struct {
+		path   string
+		accept string
+		want   string
+	}{
+		{"/s/missing", "text/html,application/xhtml+xml,*/*;q=0.8", "page not found"},
+		{"/s/missing", "application/json, text/plain, */*", `{"error":`},
+		{"/no/such/page", "", "page not found"},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+			t.Fatalf("%s: StatusCode=%v, want %v", tt.accept, got, want)
+		} else if !strings.Contains(string(body), tt.want) {
+			t.Fatalf("%s: unexpected body: %s", tt.accept, body)
+		}
+	}
+}
+
+// MustCounterValue returns the current value of a counter. Fatal on error.
+func MustCounterValue(tb testing.TB, c prometheus.Counter) float64 {
+	tb.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		tb.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
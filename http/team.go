@@ -0,0 +1,195 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kriive/lil"
+)
+
+// registerTeamRoutes is a helper function to register routes to a router.
+// These routes are JSON-only.
+func (s *Server) registerTeamRoutes(r chi.Router) {
+	read, write := s.requireScope(lil.ScopeTeamsRead), s.requireScope(lil.ScopeTeamsWrite)
+	r.With(read).Get("/team", s.handleTeamIndex)
+	r.With(write).Post("/team", s.handleTeamCreate)
+	r.With(read).Get("/team/{id}", s.handleTeamView)
+	r.With(write).Patch("/team/{id}", s.handleTeamUpdate)
+	r.With(write).Delete("/team/{id}", s.handleTeamDelete)
+	r.With(write).Put("/team/{id}/members/{userID}", s.handleTeamMemberSet)
+	r.With(write).Delete("/team/{id}/members/{userID}", s.handleTeamMemberRemove)
+}
+
+// handleTeamIndex handles the "GET /team" route. Only the teams of the
+// current user are returned.
+func (s *Server) handleTeamIndex(w http.ResponseWriter, r *http.Request) {
+	var filter lil.TeamFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	teams, n, err := s.TeamService.FindTeams(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Teams []*lil.Team `json:"teams"`
+		N     int         `json:"n"`
+	}{
+		Teams: teams,
+		N:     n,
+	}); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTeamCreate handles the "POST /team" route.
+func (s *Server) handleTeamCreate(w http.ResponseWriter, r *http.Request) {
+	var team lil.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.TeamService.CreateTeam(r.Context(), &team); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTeamView handles the "GET /team/{id}" route.
+func (s *Server) handleTeamView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	team, err := s.TeamService.FindTeamByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTeamUpdate handles the "PATCH /team/{id}" route.
+func (s *Server) handleTeamUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd lil.TeamUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	team, err := s.TeamService.UpdateTeam(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(team); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTeamDelete handles the "DELETE /team/{id}" route.
+func (s *Server) handleTeamDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.TeamService.DeleteTeam(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.Write([]byte(`{}`))
+}
+
+// handleTeamMemberSet handles the "PUT /team/{id}/members/{userID}" route.
+// The body holds the role of the member.
+func (s *Server) handleTeamMemberSet(w http.ResponseWriter, r *http.Request) {
+	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid user ID format"))
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	member, err := s.TeamService.SetTeamMember(r.Context(), teamID, userID, body.Role)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTeamMemberRemove handles the "DELETE /team/{id}/members/{userID}" route.
+func (s *Server) handleTeamMemberRemove(w http.ResponseWriter, r *http.Request) {
+	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid user ID format"))
+		return
+	}
+
+	if err := s.TeamService.RemoveTeamMember(r.Context(), teamID, userID); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	w.Write([]byte(`{}`))
+}
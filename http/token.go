@@ -0,0 +1,136 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kriive/lil"
+)
+
+// registerTokenRoutes is a helper function to register routes to a router.
+// These routes only expose the tokens of the current user. Tokens are listed
+// as JSON only, the HTML list is part of the settings page.
+func (s *Server) registerTokenRoutes(r chi.Router) {
+	read, write := s.requireScope(lil.ScopeUserRead), s.requireScope(lil.ScopeUserWrite)
+	r.With(read).Get("/token", s.handleTokenIndex)
+	r.With(write).Post("/token", s.handleTokenCreate)
+	r.With(read).Get("/token/{id}", s.handleTokenView)
+	r.With(write).Delete("/token/{id}", s.handleTokenRevoke)
+}
+
+// handleTokenIndex handles the "GET /token" route. Secrets are never listed.
+func (s *Server) handleTokenIndex(w http.ResponseWriter, r *http.Request) {
+	var filter lil.TokenFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	tokens, n, err := s.TokenService.FindTokens(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Tokens []*lil.Token `json:"tokens"`
+		N      int          `json:"n"`
+	}{
+		Tokens: tokens,
+		N:      n,
+	}); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTokenCreate handles the "POST /token" route. The response holds the
+// secret of the token, which cannot be read again afterwards.
+func (s *Server) handleTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var token lil.Token
+	switch Negotiate(r) {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+			return
+		}
+		token.Secret = ""
+	default:
+		if err := r.ParseForm(); err != nil {
+			Error(w, r, lil.Errorf(lil.EINVALID, "Invalid form."))
+			return
+		}
+		token.Name, token.Scopes = r.PostFormValue("name"), r.PostForm["scopes"]
+
+		var err error
+		if token.ExpiresAt, err = parseFormTime(r.PostFormValue("expires_at")); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	if err := s.TokenService.CreateToken(r.Context(), &token); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(token); err != nil {
+			LogError(r, err)
+			return
+		}
+	default:
+		s.renderSettings(w, r, &token, "")
+	}
+}
+
+// handleTokenView handles the "GET /token/{id}" route.
+func (s *Server) handleTokenView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	token, err := s.TokenService.FindTokenByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleTokenRevoke handles the "DELETE /token/{id}" route.
+func (s *Server) handleTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.TokenService.RevokeToken(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	switch Negotiate(r) {
+	case "application/json":
+		w.Header().Set("Content-type", "application/json")
+		w.Write([]byte(`{}`))
+	default:
+		SetFlash(w, "Successfully revoked the token.")
+		http.Redirect(w, r, "/settings", http.StatusFound)
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestServer_Token(t *testing.T) {
+	// Ensure tokens authenticate requests within their scopes only.
+	t.Run("Scopes", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		resp := MustDoJSON(t, ctx, "POST", ts.URL+"/token", `{"name":"ci","scopes":["shorts:read"]}`)
+		var token lil.Token
+		if got, want := resp.StatusCode, http.StatusCreated; got != want {
+			t.Fatalf("StatusCode=%v, want %v", got, want)
+		} else if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			t.Fatal(err)
+		} else if token.Secret == "" {
+			t.Fatal("expected secret")
+		}
+
+		tokenCtx := lil.NewContextWithUser(context.Background(), &lil.User{APIKey: token.Secret})
+		s := NewShortService(NewClient(ts.URL))
+		if _, _, err := s.FindShorts(tokenCtx, lil.ShortFilter{}); err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse("https://example.com")
+		if err := s.CreateShort(tokenCtx, &lil.Short{URL: *u}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Secrets are only returned on creation.
+		resp = MustDoJSON(t, ctx, "GET", ts.URL+"/token", "")
+		var body struct {
+			Tokens []*lil.Token `json:"tokens"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		} else if len(body.Tokens) != 1 || body.Tokens[0].Secret != "" {
+			t.Fatalf("unexpected tokens: %#v", body.Tokens)
+		}
+	})
+
+	// Ensure revoked tokens stop working immediately.
+	t.Run("Revoke", func(t *testing.T) {
+		ts, db := MustOpenServer(t)
+		defer MustCloseServer(t, ts, db)
+
+		ctx := MustCreateUser(t, db, &lil.User{Name: "susy"})
+
+		resp := MustDoJSON(t, ctx, "POST", ts.URL+"/token", `{"name":"ci","scopes":["shorts:read"]}`)
+		var token lil.Token
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp := MustDoJSON(t, ctx, "DELETE", ts.URL+"/token/1", ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("StatusCode=%v, want %v", resp.StatusCode, http.StatusOK)
+		}
+
+		tokenCtx := lil.NewContextWithUser(context.Background(), &lil.User{APIKey: token.Secret})
+		if _, _, err := NewShortService(NewClient(ts.URL)).FindShorts(tokenCtx, lil.ShortFilter{}); lil.ErrorCode(err) != lil.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
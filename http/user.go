@@ -0,0 +1,132 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kriive/lil"
+)
+
+// registerUserRoutes is a helper function to register routes to a router.
+// These routes only expose the current user and are JSON-only.
+func (s *Server) registerUserRoutes(r chi.Router) {
+	read, write := s.requireScope(lil.ScopeUserRead), s.requireScope(lil.ScopeUserWrite)
+	r.With(read).Get("/user", s.handleUserIndex)
+	r.With(read).Get("/user/{id}", s.handleUserView)
+	r.With(write).Patch("/user/{id}", s.handleUserUpdate)
+	r.With(write).Post("/user/{id}/api-key", s.handleUserRotateAPIKey)
+}
+
+// handleUserIndex handles the "GET /user" route. The filter is always
+// restricted to the current user.
+func (s *Server) handleUserIndex(w http.ResponseWriter, r *http.Request) {
+	var filter lil.UserFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	userID := lil.UserIDFromContext(r.Context())
+	filter.ID = &userID
+
+	users, n, err := s.UserService.FindUsers(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Users []*lil.User `json:"users"`
+		N     int         `json:"n"`
+	}{
+		Users: users,
+		N:     n,
+	}); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleUserView handles the "GET /user/{id}" route. Only the current user
+// can be viewed.
+func (s *Server) handleUserView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	} else if id != lil.UserIDFromContext(r.Context()) {
+		Error(w, r, lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to view this user."))
+		return
+	}
+
+	user, err := s.UserService.FindUserByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleUserUpdate handles the "PATCH /user/{id}" route.
+func (s *Server) handleUserUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd lil.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	user, err := s.UserService.UpdateUser(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		LogError(r, err)
+		return
+	}
+}
+
+// handleUserRotateAPIKey handles the "POST /user/{id}/api-key" route. The
+// API key is not part of the JSON user so it is returned alongside.
+func (s *Server) handleUserRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, lil.Errorf(lil.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	user, err := s.UserService.RotateAPIKey(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		User   *lil.User `json:"user"`
+		APIKey string    `json:"apiKey"`
+	}{
+		User:   user,
+		APIKey: user.APIKey,
+	}); err != nil {
+		LogError(r, err)
+		return
+	}
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// Column names of the Bitly & YOURLS CSV exports. Bitly has renamed its
+// columns over time so several names are accepted, matched case-insensitively.
+var (
+	bitlyKeyColumns  = []string{"custom bitlinks", "custom_bitlinks", "bitlink", "link", "short url", "short_url"}
+	bitlyURLColumns  = []string{"long url", "long_url", "original url", "destination url"}
+	yourlsKeyColumns = []string{"keyword"}
+	yourlsURLColumns = []string{"url"}
+)
+
+// readCSV reads a CSV file of "key,url" rows. The header row is optional.
+func readCSV(r io.Reader) ([]*Row, error) {
+	cr := newCSVReader(r)
+
+	rows := make([]*Row, 0)
+	for first := true; ; first = false {
+		record, line, err := readCSVRecord(cr)
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		} else if record == nil {
+			rows = append(rows, &Row{Line: line, Err: lil.Errorf(lil.EINVALID, "Invalid CSV row.")})
+			continue
+		}
+
+		// Skip the header row, if any.
+		if first && len(record) >= 2 && strings.EqualFold(strings.TrimSpace(record[0]), "key") && strings.EqualFold(strings.TrimSpace(record[1]), "url") {
+			continue
+		}
+
+		row := &Row{Line: line}
+		if len(record) < 2 {
+			row.Err = lil.Errorf(lil.EINVALID, "Expected a key and a URL.")
+		} else {
+			row.Key, row.URL = strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readBitlyCSV reads a CSV export of Bitly links. The key of a link is the
+// back-half of its custom Bitlink if it has one, or of its Bitlink otherwise.
+func readBitlyCSV(r io.Reader) ([]*Row, error) {
+	return readHeaderCSV(r, bitlyKeyColumns, bitlyURLColumns, bitlyKey)
+}
+
+// readYOURLSCSV reads a CSV export of the YOURLS links table.
+func readYOURLSCSV(r io.Reader) ([]*Row, error) {
+	return readHeaderCSV(r, yourlsKeyColumns, yourlsURLColumns, strings.TrimSpace)
+}
+
+// readHeaderCSV reads a CSV file whose columns are matched by its header row.
+// The first non-blank column of keyColumns, in order, is passed to keyFn to
+// extract the key of a row. The same goes for urlColumns and the URL.
+func readHeaderCSV(r io.Reader, keyColumns, urlColumns []string, keyFn func(string) string) ([]*Row, error) {
+	cr := newCSVReader(r)
+
+	header, _, err := readCSVRecord(cr)
+	if err == io.EOF {
+		return nil, lil.Errorf(lil.EINVALID, "Empty CSV file.")
+	} else if err != nil || header == nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid CSV file.")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	// field returns the first non-blank value of the named columns.
+	field := func(record []string, names []string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) {
+				if v := strings.TrimSpace(record[i]); v != "" {
+					return v
+				}
+			}
+		}
+		return ""
+	}
+
+	if !hasColumn(columns, urlColumns) {
+		return nil, lil.Errorf(lil.EINVALID, "CSV file must have a %q column.", urlColumns[0])
+	}
+
+	rows := make([]*Row, 0)
+	for {
+		record, line, err := readCSVRecord(cr)
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		} else if record == nil {
+			rows = append(rows, &Row{Line: line, Err: lil.Errorf(lil.EINVALID, "Invalid CSV row.")})
+			continue
+		}
+
+		rows = append(rows, &Row{
+			Line: line,
+			Key:  keyFn(field(record, keyColumns)),
+			URL:  field(record, urlColumns),
+		})
+	}
+}
+
+// hasColumn returns true if any of names is a column.
+func hasColumn(columns map[string]int, names []string) bool {
+	for _, name := range names {
+		if _, ok := columns[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bitlyKey returns the back-half of a Bitlink such as "bit.ly/abc". Custom
+// Bitlinks may be listed together, in which case the first one is used.
+func bitlyKey(v string) string {
+	if i := strings.IndexAny(v, ", "); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.TrimRight(v, "/")
+	if i := strings.LastIndex(v, "/"); i >= 0 {
+		v = v[i+1:]
+	}
+	return v
+}
+
+// newCSVReader returns a CSV reader accepting rows of any length.
+func newCSVReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr
+}
+
+// readCSVRecord reads the next record and its line. Returns a nil record if
+// the row cannot be parsed, so that reading can continue with the next one.
+func readCSVRecord(cr *csv.Reader) (record []string, line int, err error) {
+	record, err = cr.Read()
+	if e, ok := err.(*csv.ParseError); ok {
+		return nil, e.StartLine, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	line, _ = cr.FieldPos(0)
+	return record, line, nil
+}
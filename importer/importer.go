@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/generate"
+)
+
+// Formats of the dumps that can be read.
+const (
+	FormatCSV       = "csv"
+	FormatBitly     = "bitly"
+	FormatYOURLSCSV = "yourls-csv"
+	FormatYOURLSSQL = "yourls-sql"
+)
+
+// Formats lists every supported format.
+var Formats = []string{FormatCSV, FormatBitly, FormatYOURLSCSV, FormatYOURLSSQL}
+
+// Row represents a link read from a dump. Err is set if the row could not be
+// parsed.
+type Row struct {
+	Line int
+	Key  string
+	URL  string
+	Err  error
+}
+
+// Read reads the links of a dump in the given format. Rows that cannot be
+// parsed are returned with an error rather than failing the whole dump.
+func Read(r io.Reader, format string) ([]*Row, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatBitly:
+		return readBitlyCSV(r)
+	case FormatYOURLSCSV:
+		return readYOURLSCSV(r)
+	case FormatYOURLSSQL:
+		return readYOURLSSQL(r)
+	default:
+		return nil, lil.Errorf(lil.EINVALID, "Unknown import format %q.", format)
+	}
+}
+
+// Result statuses of an imported row. Renamed rows are created with a random
+// key as their original key does not satisfy the key policy.
+const (
+	StatusCreated   = "created"
+	StatusRenamed   = "renamed"
+	StatusCollision = "collision"
+	StatusInvalid   = "invalid"
+)
+
+// Result represents the outcome of importing a row. Key is the key the short
+// is created with, which differs from the original one if it was renamed.
+type Result struct {
+	Line   int
+	Key    string
+	URL    string
+	Status string
+	Detail string
+}
+
+// Report represents the outcome of an import.
+type Report struct {
+	DryRun  bool
+	Results []*Result
+}
+
+// Count returns the number of results with the given status.
+func (r *Report) Count(status string) int {
+	var n int
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Importer creates shorts from the rows of a dump. Shorts are owned by the
+// user of the context.
+type Importer struct {
+	ShortService lil.ShortService
+
+	// Original keys are kept if they satisfy the policy. Other keys are
+	// replaced by random keys of KeyLength characters from Alphabet.
+	KeyPolicy lil.KeyPolicy
+	KeyLength int
+	Alphabet  string
+
+	// If set, nothing is created and the report lists what would happen.
+	DryRun bool
+}
+
+// NewImporter returns a new instance of Importer.
+func NewImporter(shortService lil.ShortService) *Importer {
+	return &Importer{ShortService: shortService}
+}
+
+// Import creates a short for every valid row. Keys which are already taken,
+// or repeated within the dump, are reported as collisions and skipped.
+// Returns an error only if the import could not proceed.
+func (imp *Importer) Import(ctx context.Context, rows []*Row) (*Report, error) {
+	report := &Report{DryRun: imp.DryRun}
+	seen := make(map[string]struct{})
+
+	for _, row := range rows {
+		result, err := imp.importRow(ctx, row, seen)
+		if err != nil {
+			return report, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// importRow imports a single row and marks its key as seen. Returns an error
+// only for internal errors.
+func (imp *Importer) importRow(ctx context.Context, row *Row, seen map[string]struct{}) (*Result, error) {
+	result := &Result{Line: row.Line, Key: row.Key, URL: row.URL, Status: StatusCreated}
+
+	if row.Err != nil {
+		result.Status, result.Detail = StatusInvalid, lil.ErrorMessage(row.Err)
+		return result, nil
+	}
+
+	u, err := parseURL(row.URL)
+	if err != nil {
+		result.Status, result.Detail = StatusInvalid, lil.ErrorMessage(err)
+		return result, nil
+	}
+
+	// Replace keys which are missing or do not satisfy the policy.
+	if verr := imp.KeyPolicy.Validate(row.Key); verr != nil {
+		if result.Key, err = generate.SecureStringFromAlphabet(imp.KeyLength, imp.Alphabet); err != nil {
+			return nil, err
+		}
+		result.Status = StatusRenamed
+		if row.Key != "" {
+			result.Detail = fmt.Sprintf("Key %q replaced: %s", row.Key, lil.ErrorMessage(verr))
+		}
+	}
+
+	if _, ok := seen[result.Key]; ok {
+		result.Status, result.Detail = StatusCollision, "Key is repeated in the dump."
+		return result, nil
+	}
+	seen[result.Key] = struct{}{}
+
+	if imp.DryRun {
+		if taken, err := imp.isTaken(ctx, result.Key); err != nil {
+			return nil, err
+		} else if taken {
+			result.Status, result.Detail = StatusCollision, "Short with the same key already exists."
+		}
+		return result, nil
+	}
+
+	// Conflicts are reported by the database rather than checked beforehand
+	// so that keys taken concurrently are reported as well.
+	if err := imp.ShortService.CreateShort(ctx, &lil.Short{Key: result.Key, URL: *u}); lil.ErrorCode(err) == lil.ECONFLICT {
+		result.Status, result.Detail = StatusCollision, lil.ErrorMessage(err)
+	} else if lil.ErrorCode(err) == lil.EINVALID {
+		result.Status, result.Detail = StatusInvalid, lil.ErrorMessage(err)
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isTaken returns true if a short exists with the given key, whether or not
+// it can currently be followed.
+func (imp *Importer) isTaken(ctx context.Context, key string) (bool, error) {
+	_, err := imp.ShortService.SearchShort(ctx, key)
+	switch lil.ErrorCode(err) {
+	case "", lil.ENOTACTIVE, lil.EEXPIRED:
+		return true, nil
+	case lil.ENOTFOUND:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// parseURL parses an absolute http or https URL.
+func parseURL(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, lil.ErrEmptyURL
+	}
+
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid URL passed.")
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, lil.ErrInvalidURLScheme
+	} else if u.Host == "" {
+		return nil, lil.Errorf(lil.EINVALID, "Invalid URL passed.")
+	}
+	return u, nil
+}
@@ -0,0 +1,221 @@
+package importer_test
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/importer"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestRead(t *testing.T) {
+	// Ensure "key,url" rows are read with or without a header.
+	t.Run("CSV", func(t *testing.T) {
+		rows, err := importer.Read(strings.NewReader("key,url\nabc,https://example.com\n\"bad\nxyz\n"), importer.FormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualRows(t, rows, []*importer.Row{
+			{Line: 2, Key: "abc", URL: "https://example.com"},
+			{Line: 3, Err: lil.Errorf(lil.EINVALID, "Invalid CSV row.")},
+		})
+
+		if rows, err := importer.Read(strings.NewReader("abc,https://example.com\nxyz\n"), importer.FormatCSV); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(rows), 2; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if rows[1].Err == nil {
+			t.Fatal("expected error for missing URL")
+		}
+	})
+
+	// Ensure the back-half of Bitlinks is used as key, custom ones first.
+	t.Run("Bitly", func(t *testing.T) {
+		csv := "Title,Bitlink,Custom Bitlinks,Long URL\n" +
+			"Home,https://bit.ly/3xYz,,https://example.com\n" +
+			"Docs,bit.ly/4abc,\"bit.ly/docs, bit.ly/help\",https://example.com/docs\n"
+		rows, err := importer.Read(strings.NewReader(csv), importer.FormatBitly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualRows(t, rows, []*importer.Row{
+			{Line: 2, Key: "3xYz", URL: "https://example.com"},
+			{Line: 3, Key: "docs", URL: "https://example.com/docs"},
+		})
+
+		if _, err := importer.Read(strings.NewReader("Title,Bitlink\n"), importer.FormatBitly); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure YOURLS CSV exports are matched by their header.
+	t.Run("YOURLSCSV", func(t *testing.T) {
+		rows, err := importer.Read(strings.NewReader("url,keyword,title\nhttps://example.com,abc,Example\n"), importer.FormatYOURLSCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualRows(t, rows, []*importer.Row{
+			{Line: 2, Key: "abc", URL: "https://example.com"},
+		})
+	})
+
+	// Ensure only the links table is read from SQL dumps and that strings are
+	// unescaped.
+	t.Run("YOURLSSQL", func(t *testing.T) {
+		dump := "-- MySQL dump\n" +
+			"/*!40101 SET NAMES utf8mb4 */;\n" +
+			"CREATE TABLE `yourls_url` (`keyword` varchar(100), `url` text);\n" +
+			"INSERT INTO `yourls_url` VALUES ('abc','https://example.com/?q=it\\'s','A; title','2020-01-01 00:00:00','127.0.0.1',-1),\n" +
+			"('xyz','https://example.org','','2020-01-01 00:00:00','127.0.0.1',0.5);\n" +
+			"INSERT INTO `yourls_log` VALUES (1,'abc');\n" +
+			"INSERT INTO `db`.`yourls_url` (`url`, `keyword`) VALUES ('https://example.net', 'def'), ('https://example.io');\n"
+		rows, err := importer.Read(strings.NewReader(dump), importer.FormatYOURLSSQL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualRows(t, rows, []*importer.Row{
+			{Line: 4, Key: "abc", URL: "https://example.com/?q=it's"},
+			{Line: 5, Key: "xyz", URL: "https://example.org"},
+			{Line: 7, Key: "def", URL: "https://example.net"},
+			{Line: 7, Err: lil.Errorf(lil.EINVALID, "Expected 2 values, got 1.")},
+		})
+	})
+
+	// Ensure malformed statements are reported.
+	t.Run("ErrYOURLSSQL", func(t *testing.T) {
+		if _, err := importer.Read(strings.NewReader("INSERT INTO yourls_url VALUES ('abc', 'https://example.com'"), importer.FormatYOURLSSQL); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure unknown formats are rejected.
+	t.Run("ErrFormat", func(t *testing.T) {
+		if _, err := importer.Read(strings.NewReader(""), "tinyurl"); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestImporter_Import(t *testing.T) {
+	rows := []*importer.Row{
+		{Line: 1, Key: "abc", URL: "https://example.com"},
+		{Line: 2, Key: "taken", URL: "https://example.com"},
+		{Line: 3, Key: "x", URL: "https://example.com"},
+		{Line: 4, Key: "bad", URL: "ftp://example.com"},
+		{Line: 5, Key: "abc", URL: "https://example.org"},
+		{Line: 6, Err: lil.Errorf(lil.EINVALID, "Invalid CSV row.")},
+	}
+	want := []string{
+		importer.StatusCreated,
+		importer.StatusCollision,
+		importer.StatusRenamed,
+		importer.StatusInvalid,
+		importer.StatusCollision,
+		importer.StatusInvalid,
+	}
+
+	// Ensure a dry run reports collisions & invalid URLs without creating
+	// anything.
+	t.Run("DryRun", func(t *testing.T) {
+		imp, shortService, ctx := MustOpenImporter(t)
+		imp.DryRun = true
+
+		report, err := imp.Import(ctx, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualStatuses(t, report, want)
+
+		if _, err := shortService.FindShortByKey(ctx, "abc"); lil.ErrorCode(err) != lil.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Ensure valid rows are created for the user, keeping valid keys.
+	t.Run("OK", func(t *testing.T) {
+		imp, shortService, ctx := MustOpenImporter(t)
+
+		report, err := imp.Import(ctx, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustEqualStatuses(t, report, want)
+
+		if short, err := shortService.FindShortByKey(ctx, "abc"); err != nil {
+			t.Fatal(err)
+		} else if got, want := short.OwnerID, lil.UserIDFromContext(ctx); got != want {
+			t.Fatalf("OwnerID=%v, want %v", got, want)
+		} else if got, want := short.URL.String(), "https://example.com"; got != want {
+			t.Fatalf("URL=%v, want %v", got, want)
+		}
+
+		renamed := report.Results[2]
+		if got, want := len(renamed.Key), imp.KeyLength; got != want {
+			t.Fatalf("len(Key)=%v, want %v", got, want)
+		} else if _, err := shortService.FindShortByKey(ctx, renamed.Key); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// MustOpenImporter returns an importer in front of an in-memory SQLite
+// database, its short service and the context of a new user. A short with
+// the "taken" key is owned by another user. The database is closed at the
+// end of the test.
+func MustOpenImporter(tb testing.TB) (*importer.Importer, lil.ShortService, context.Context) {
+	tb.Helper()
+
+	db := sqlite.NewDB(":memory:")
+	if err := db.Open(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	userService, shortService := sqlite.NewUserService(db), sqlite.NewShortService(db)
+	user0, user1 := &lil.User{Name: "susy"}, &lil.User{Name: "jill"}
+	for _, user := range []*lil.User{user0, user1} {
+		if err := userService.CreateUser(context.Background(), user); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	u, _ := url.Parse("https://example.com")
+	if err := shortService.CreateShort(lil.NewContextWithUser(context.Background(), user1), &lil.Short{Key: "taken", URL: *u}); err != nil {
+		tb.Fatal(err)
+	}
+
+	imp := importer.NewImporter(shortService)
+	imp.KeyPolicy = lil.KeyPolicy{Alphabet: "abcdefghijklmnopqrstuvwxyz", MinLength: 3}
+	imp.KeyLength, imp.Alphabet = 8, "abcdefghijklmnopqrstuvwxyz"
+	return imp, shortService, lil.NewContextWithUser(context.Background(), user0)
+}
+
+// MustEqualRows fails if the rows differ.
+func MustEqualRows(tb testing.TB, got, want []*importer.Row) {
+	tb.Helper()
+	if len(got) != len(want) {
+		tb.Fatalf("len=%v, want %v", len(got), len(want))
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			tb.Fatalf("row %d=%#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+// MustEqualStatuses fails if the statuses of the results of report differ.
+func MustEqualStatuses(tb testing.TB, report *importer.Report, want []string) {
+	tb.Helper()
+	if len(report.Results) != len(want) {
+		tb.Fatalf("len=%v, want %v", len(report.Results), len(want))
+	}
+	for i, result := range report.Results {
+		if result.Status != want[i] {
+			tb.Fatalf("status %d=%v, want %v (%s)", i, result.Status, want[i], result.Detail)
+		}
+	}
+}
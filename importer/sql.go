@@ -0,0 +1,352 @@
+package importer
+
+import (
+	"io"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// yourlsColumns are the columns of the YOURLS links table, in the order used
+// by INSERT statements which do not list them.
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// readYOURLSSQL reads the links of a MySQL dump of a YOURLS database, as
+// written by mysqldump or phpMyAdmin. Only INSERT statements into the links
+// table, named "url" after the table prefix, are read. Other statements are
+// skipped.
+func readYOURLSSQL(r io.Reader) ([]*Row, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sqlScanner{src: string(buf), line: 1}
+	rows := make([]*Row, 0)
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return nil, err
+		} else if tok.kind == sqlEOF {
+			return rows, nil
+		} else if tok.isWord("INSERT") {
+			if rows, err = s.readInsert(rows); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := s.skipStatement(tok); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readInsert reads the rows of an INSERT statement, after the INSERT keyword,
+// and appends them to rows. Statements into other tables are skipped.
+func (s *sqlScanner) readInsert(rows []*Row) ([]*Row, error) {
+	// Skip modifiers until the table name.
+	tok, err := s.next()
+	for err == nil && (tok.isWord("IGNORE") || tok.isWord("INTO") || tok.isWord("LOW_PRIORITY") || tok.isWord("DELAYED") || tok.isWord("HIGH_PRIORITY")) {
+		tok, err = s.next()
+	}
+	if err != nil {
+		return nil, err
+	} else if tok.kind != sqlWord {
+		return nil, s.errorf(tok)
+	}
+
+	// The table name may be qualified by a database name.
+	table := tok.text
+	if tok, err = s.next(); err != nil {
+		return nil, err
+	} else if tok.isPunct('.') {
+		if tok, err = s.next(); err != nil {
+			return nil, err
+		} else if tok.kind != sqlWord {
+			return nil, s.errorf(tok)
+		}
+		table = tok.text
+		if tok, err = s.next(); err != nil {
+			return nil, err
+		}
+	}
+	if t := strings.ToLower(table); t != "url" && !strings.HasSuffix(t, "_url") {
+		return rows, s.skipStatement(tok)
+	}
+
+	columns := yourlsColumns
+	if tok.isPunct('(') {
+		if columns, err = s.readList(); err != nil {
+			return nil, err
+		} else if tok, err = s.next(); err != nil {
+			return nil, err
+		}
+	}
+	keyIndex, urlIndex := indexOf(columns, "keyword"), indexOf(columns, "url")
+	if keyIndex < 0 || urlIndex < 0 {
+		return rows, s.skipStatement(tok)
+	}
+
+	if !tok.isWord("VALUES") && !tok.isWord("VALUE") {
+		return nil, s.errorf(tok)
+	}
+
+	for {
+		if tok, err = s.next(); err != nil {
+			return nil, err
+		} else if !tok.isPunct('(') {
+			return nil, s.errorf(tok)
+		}
+
+		line := tok.line
+		values, err := s.readList()
+		if err != nil {
+			return nil, err
+		}
+
+		row := &Row{Line: line}
+		if len(values) != len(columns) {
+			row.Err = lil.Errorf(lil.EINVALID, "Expected %d values, got %d.", len(columns), len(values))
+		} else {
+			row.Key, row.URL = strings.TrimSpace(values[keyIndex]), strings.TrimSpace(values[urlIndex])
+		}
+		rows = append(rows, row)
+
+		// Rows are separated by commas until the end of the statement.
+		if tok, err = s.next(); err != nil {
+			return nil, err
+		} else if tok.isPunct(',') {
+			continue
+		} else if tok.isPunct(';') || tok.kind == sqlEOF {
+			return rows, nil
+		}
+		return nil, s.errorf(tok)
+	}
+}
+
+// indexOf returns the index of a case-insensitive name in a, or -1.
+func indexOf(a []string, name string) int {
+	for i, v := range a {
+		if strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SQL token kinds.
+const (
+	sqlEOF = iota
+	sqlWord
+	sqlString
+	sqlPunct
+)
+
+// sqlToken represents a token of a SQL dump. Quoted identifiers are words,
+// and the text of strings is unescaped.
+type sqlToken struct {
+	kind int
+	text string
+	line int
+}
+
+// isWord returns true if the token is the given case-insensitive word.
+func (t sqlToken) isWord(word string) bool {
+	return t.kind == sqlWord && strings.EqualFold(t.text, word)
+}
+
+// isPunct returns true if the token is the given punctuation.
+func (t sqlToken) isPunct(c byte) bool {
+	return t.kind == sqlPunct && t.text == string(c)
+}
+
+// sqlScanner splits a MySQL dump into tokens. It only understands enough of
+// the syntax to read the values of INSERT statements.
+type sqlScanner struct {
+	src  string
+	pos  int
+	line int
+}
+
+// errorf returns an error for an unexpected token.
+func (s *sqlScanner) errorf(tok sqlToken) error {
+	return lil.Errorf(lil.EINVALID, "Invalid SQL dump: unexpected %q on line %d.", tok.text, tok.line)
+}
+
+// skipStatement skips tokens until the end of the statement of tok.
+func (s *sqlScanner) skipStatement(tok sqlToken) (err error) {
+	for tok.kind != sqlEOF && !tok.isPunct(';') {
+		if tok, err = s.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readList reads a parenthesized list of values or identifiers, after the
+// opening parenthesis. NULL is read as a blank value.
+func (s *sqlScanner) readList() ([]string, error) {
+	var values []string
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tok.isWord("NULL"):
+			values = append(values, "")
+		case tok.kind == sqlWord || tok.kind == sqlString:
+			values = append(values, tok.text)
+		case tok.isPunct('-') || tok.isPunct('+'):
+			// Signed numbers are split in two tokens.
+			sign := tok.text
+			if tok, err = s.next(); err != nil {
+				return nil, err
+			} else if tok.kind != sqlWord {
+				return nil, s.errorf(tok)
+			}
+			values = append(values, sign+tok.text)
+		default:
+			return nil, s.errorf(tok)
+		}
+
+		if tok, err = s.next(); err != nil {
+			return nil, err
+		} else if tok.isPunct(')') {
+			return values, nil
+		} else if !tok.isPunct(',') {
+			return nil, s.errorf(tok)
+		}
+	}
+}
+
+// next returns the next token, skipping whitespace & comments.
+func (s *sqlScanner) next() (sqlToken, error) {
+	if err := s.skip(); err != nil {
+		return sqlToken{}, err
+	}
+	if s.pos >= len(s.src) {
+		return sqlToken{kind: sqlEOF, line: s.line}, nil
+	}
+
+	tok := sqlToken{line: s.line}
+	switch c := s.src[s.pos]; {
+	case c == '\'' || c == '"':
+		text, err := s.readQuoted(c, true)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = sqlString, text
+	case c == '`':
+		text, err := s.readQuoted(c, false)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = sqlWord, text
+	case isWordByte(c):
+		// Numbers may have a decimal point.
+		start, number := s.pos, '0' <= c && c <= '9'
+		for s.pos < len(s.src) && (isWordByte(s.src[s.pos]) || number && s.src[s.pos] == '.') {
+			s.pos++
+		}
+		tok.kind, tok.text = sqlWord, s.src[start:s.pos]
+	default:
+		s.pos++
+		tok.kind, tok.text = sqlPunct, string(c)
+	}
+	return tok, nil
+}
+
+// skip advances past whitespace and comments. Conditional comments such as
+// "/*!40101 ... */" are skipped as well.
+func (s *sqlScanner) skip() error {
+	for s.pos < len(s.src) {
+		switch rest := s.src[s.pos:]; {
+		case rest[0] == '\n':
+			s.line++
+			s.pos++
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r':
+			s.pos++
+		case strings.HasPrefix(rest, "-- ") || strings.HasPrefix(rest, "--\n") || rest == "--" || rest[0] == '#':
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				s.pos += i
+			} else {
+				s.pos = len(s.src)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			i := strings.Index(rest[2:], "*/")
+			if i < 0 {
+				return lil.Errorf(lil.EINVALID, "Invalid SQL dump: unterminated comment on line %d.", s.line)
+			}
+			s.line += strings.Count(rest[:i+4], "\n")
+			s.pos += i + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// readQuoted reads a quoted string or identifier at the current position.
+// A doubled quote stands for the quote itself. If escapes is set, backslash
+// escape sequences are decoded as well.
+func (s *sqlScanner) readQuoted(quote byte, escapes bool) (string, error) {
+	line := s.line
+	s.pos++
+
+	var sb strings.Builder
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		s.pos++
+
+		switch {
+		case c == quote && s.pos < len(s.src) && s.src[s.pos] == quote:
+			sb.WriteByte(quote)
+			s.pos++
+		case c == quote:
+			return sb.String(), nil
+		case c == '\\' && escapes && s.pos < len(s.src):
+			sb.WriteString(unescapeSQL(s.src[s.pos]))
+			if s.src[s.pos] == '\n' {
+				s.line++
+			}
+			s.pos++
+		default:
+			if c == '\n' {
+				s.line++
+			}
+			sb.WriteByte(c)
+		}
+	}
+	return "", lil.Errorf(lil.EINVALID, "Invalid SQL dump: unterminated string on line %d.", line)
+}
+
+// unescapeSQL returns the character escaped by a backslash in MySQL.
+func unescapeSQL(c byte) string {
+	switch c {
+	case '0':
+		return "\x00"
+	case 'b':
+		return "\b"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case 'Z':
+		return "\x1a"
+	case '%', '_':
+		// These keep their backslash as they are only escaped in patterns.
+		return "\\" + string(c)
+	default:
+		return string(c)
+	}
+}
+
+// isWordByte returns true if c can be part of an unquoted word or number.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
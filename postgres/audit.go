@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// Ensure service implements interface.
+var _ lil.AuditService = (*AuditService)(nil)
+
+// AuditService represents a service for reading the audit log.
+type AuditService struct {
+	db *DB
+}
+
+// NewAuditService returns a new instance of AuditService.
+func NewAuditService(db *DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// FindAuditEntries retrieves a list of audit entries by filter, newest first.
+// Returns EUNAUTHORIZED unless the caller is an administrator.
+func (s *AuditService) FindAuditEntries(ctx context.Context, filter lil.AuditFilter) ([]*lil.AuditEntry, int, error) {
+	if !lil.AdminFromContext(ctx) {
+		return nil, 0, lil.Errorf(lil.EUNAUTHORIZED, "Only administrators can read the audit log.")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	entries, n, err := findAuditEntries(ctx, tx, filter)
+	if err != nil {
+		return entries, n, err
+	}
+
+	// Attach the actors that still exist.
+	for _, entry := range entries {
+		if entry.ActorID == 0 {
+			continue
+		} else if entry.Actor, err = findUserByID(ctx, tx, entry.ActorID); err != nil {
+			return entries, n, fmt.Errorf("attach audit actor: %w", err)
+		}
+	}
+	return entries, n, nil
+}
+
+// findAuditEntries returns a list of audit entries matching a filter. Also
+// returns a count of total matching entries which may differ if filter.Limit
+// is set.
+func findAuditEntries(ctx context.Context, tx *Tx, filter lil.AuditFilter) (_ []*lil.AuditEntry, n int, err error) {
+	// Build WHERE clause.
+	where, args := []string{"1 = 1"}, []any{}
+	if v := filter.ActorID; v != nil {
+		where, args = append(where, "actor_id = ?"), append(args, *v)
+	}
+	if v := filter.Action; v != nil {
+		where, args = append(where, "action = ?"), append(args, *v)
+	}
+	if v := filter.Target; v != nil {
+		where, args = append(where, "target = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+		    id,
+		    actor_id,
+		    action,
+		    target,
+		    created_at,
+		    COUNT(*) OVER()
+		FROM audit_log
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id DESC
+		`+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	entries := make([]*lil.AuditEntry, 0)
+	for rows.Next() {
+		var entry lil.AuditEntry
+		var actorID sql.NullInt64
+		if err := rows.Scan(
+			&entry.ID,
+			&actorID,
+			&entry.Action,
+			&entry.Target,
+			(*NullTime)(&entry.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		entry.ActorID = int(actorID.Int64)
+
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, n, nil
+}
+
+// createAdminAuditEntry records action on target in the audit log if the
+// context has administrator privileges. Does nothing otherwise. The entry is
+// written in the same transaction as the action itself.
+func createAdminAuditEntry(ctx context.Context, tx *Tx, action, target string) error {
+	if !lil.AdminFromContext(ctx) {
+		return nil
+	}
+
+	// Maintenance tools act without a user.
+	var actorID *int
+	if userID := lil.UserIDFromContext(ctx); userID != 0 {
+		actorID = &userID
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (
+			actor_id,
+			action,
+			target,
+			created_at
+		)
+		VALUES (?, ?, ?, ?)
+	`,
+		actorID,
+		action,
+		target,
+		(*NullTime)(&tx.now),
+	); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+// AuthService represents a service for managing OAuth authentication.
+type AuthService struct {
+	db *DB
+}
+
+// NewAuthService returns a new instance of AuthService attached to DB.
+func NewAuthService(db *DB) *AuthService {
+	return &AuthService{db: db}
+}
+
+// FindAuthByID retrieves an authentication object by ID along with the associated user.
+// Returns ENOTFOUND if ID does not exist.
+func (s *AuthService) FindAuthByID(ctx context.Context, id int) (*lil.Auth, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Look up auth by ID and read associated user object.
+	auth, err := findAuthByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachAuthAssociations(ctx, tx, auth); err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// FindAuths retrieves authentication objects based on a filter.
+//
+// Also returns the total number of objects that match the filter. This may
+// differ from the returned object count if the Limit field is set.
+func (s *AuthService) FindAuths(ctx context.Context, filter lil.AuthFilter) ([]*lil.Auth, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	// Fetch the individual authentication objects from the database.
+	auths, n, err := findAuths(ctx, tx, filter)
+	if err != nil {
+		return auths, n, err
+	}
+
+	// Iterate over returned objects and attach user objects.
+	// Remote database servers incur a per-query latency so these queries
+	// should eventually be batched.
+	for _, auth := range auths {
+		if err := attachAuthAssociations(ctx, tx, auth); err != nil {
+			return auths, n, err
+		}
+	}
+	return auths, n, nil
+}
+
+// CreateAuth Creates a new authentication object If a User is attached to auth,
+// then the auth object is linked to an existing user. Otherwise a new user
+// object is created.
+//
+// On success, the auth.ID is set to the new authentication ID.
+func (s *AuthService) CreateAuth(ctx context.Context, auth *lil.Auth) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Check to see if the auth already exists for the given source.
+	if other, err := findAuthBySourceID(ctx, tx, auth.Source, auth.SourceID); err == nil {
+		// If an auth already exists for the source user, update with the new tokens.
+		if other, err = updateAuth(ctx, tx, other.ID, auth.AccessToken, auth.RefreshToken, auth.Expiry, auth.Picture); err != nil {
+			return fmt.Errorf("cannot update auth: id=%d err=%w", other.ID, err)
+		} else if err := attachAuthAssociations(ctx, tx, other); err != nil {
+			return err
+		}
+
+		// Logging in again confirms the email if the provider reports the
+		// one of the user.
+		if auth.User != nil && auth.User.Email != "" && auth.User.Email == other.User.Email && !other.User.EmailVerified {
+			if err := verifyUserEmail(ctx, tx, other.UserID); err != nil {
+				return err
+			}
+			other.User.EmailVerified = true
+		}
+
+		// Copy found auth back to the caller's arg & return.
+		*auth = *other
+		return tx.Commit()
+	} else if lil.ErrorCode(err) != lil.ENOTFOUND {
+		return fmt.Errorf("cannot find auth by source user: %w", err)
+	}
+
+	// Check if auth has a new user object passed in. It is considered "new" if
+	// the caller doesn't know the database ID for the user.
+	if auth.UserID == 0 && auth.User != nil {
+		// Look up the user by email address. Providers only report confirmed
+		// emails, so the login is linked to the user only if they confirmed
+		// it too. Otherwise, the email is taken from the user who set it and
+		// a new user is created with the auth.User object passed in.
+		user, err := findUserByEmail(ctx, tx, auth.User.Email)
+		if err == nil && user.EmailVerified { // user exists
+			auth.User = user
+		} else if err == nil || lil.ErrorCode(err) == lil.ENOTFOUND { // user does not exist
+			if user != nil {
+				if err := releaseUserEmail(ctx, tx, user.ID); err != nil {
+					return fmt.Errorf("cannot release user email: %w", err)
+				}
+			}
+
+			auth.User.EmailVerified = true
+			if err := createUser(ctx, tx, auth.User); err != nil {
+				return fmt.Errorf("cannot create user: %w", err)
+			}
+		} else {
+			return fmt.Errorf("cannot find user by email: %w", err)
+		}
+
+		// Assign the created/found user ID back to the auth object.
+		auth.UserID = auth.User.ID
+	}
+
+	// Create new auth object & attach associated user.
+	if err := createAuth(ctx, tx, auth); err != nil {
+		return err
+	} else if err := attachAuthAssociations(ctx, tx, auth); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAuth permanently deletes an authentication object from the system by ID.
+// The parent user object is not removed. Returns ECONFLICT if it is the last
+// authentication object of the user.
+func (s *AuthService) DeleteAuth(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteAuth(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findAuthByID is a helper function to return an auth object by ID.
+// Returns ENOTFOUND if auth doesn't exist.
+func findAuthByID(ctx context.Context, tx *Tx, id int) (*lil.Auth, error) {
+	auths, _, err := findAuths(ctx, tx, lil.AuthFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(auths) == 0 {
+		return nil, &lil.Error{Code: lil.ENOTFOUND, Message: "Auth not found."}
+	}
+	return auths[0], nil
+}
+
+// findAuthBySourceID is a helper function to return an auth object by source ID.
+// Returns ENOTFOUND if auth doesn't exist.
+func findAuthBySourceID(ctx context.Context, tx *Tx, source, sourceID string) (*lil.Auth, error) {
+	auths, _, err := findAuths(ctx, tx, lil.AuthFilter{Source: &source, SourceID: &sourceID})
+	if err != nil {
+		return nil, err
+	} else if len(auths) == 0 {
+		return nil, &lil.Error{Code: lil.ENOTFOUND, Message: "Auth not found."}
+	}
+	return auths[0], nil
+}
+
+// findAuths returns a list of auth objects that match a filter. Also returns
+// a total count of matches which may differ from results if filter.Limit is set.
+func findAuths(ctx context.Context, tx *Tx, filter lil.AuthFilter) (_ []*lil.Auth, n int, err error) {
+	// Build WHERE clause. Each part of the clause is AND-ed together to further
+	// restrict the results. Placeholders are added to "args" and are used
+	// to avoid SQL injection.
+	//
+	// Each filter field is optional.
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.UserID; v != nil {
+		where, args = append(where, "user_id = ?"), append(args, *v)
+	}
+	if v := filter.Source; v != nil {
+		where, args = append(where, "source = ?"), append(args, *v)
+	}
+	if v := filter.SourceID; v != nil {
+		where, args = append(where, "source_id = ?"), append(args, *v)
+	}
+
+	// Execute the query with WHERE clause and LIMIT/OFFSET injected.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT 
+		    id,
+		    user_id,
+		    source,
+		    source_id,
+		    access_token,
+		    refresh_token,
+		    expiry,
+		    picture,
+		    created_at,
+		    updated_at,
+		    COUNT(*) OVER()
+		FROM auths
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id ASC
+		`+FormatLimitOffset(filter.Limit, filter.Offset)+`
+	`,
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	// Iterate over result set and deserialize rows into Auth objects.
+	auths := make([]*lil.Auth, 0)
+	for rows.Next() {
+		var auth lil.Auth
+		var expiry time.Time
+		if err := rows.Scan(
+			&auth.ID,
+			&auth.UserID,
+			&auth.Source,
+			&auth.SourceID,
+			&auth.AccessToken,
+			&auth.RefreshToken,
+			(*NullTime)(&expiry),
+			&auth.Picture,
+			(*NullTime)(&auth.CreatedAt),
+			(*NullTime)(&auth.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if !expiry.IsZero() {
+			auth.Expiry = &expiry
+		}
+
+		auths = append(auths, &auth)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return auths, n, nil
+}
+
+// createAuth creates a new auth object in the database. On success, the
+// ID is set to the new database ID & timestamp fields are set to the current time.
+func createAuth(ctx context.Context, tx *Tx, auth *lil.Auth) error {
+	// Set timestamp fields to current time.
+	auth.CreatedAt = tx.now
+	auth.UpdatedAt = auth.CreatedAt
+
+	// Ensure auth object passes basic validation.
+	if err := auth.Validate(); err != nil {
+		return err
+	}
+
+	// Execute insertion query.
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO auths (
+			user_id,
+			source,
+			source_id,
+			access_token,
+			refresh_token,
+			expiry,
+			picture,
+			created_at,
+			updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		auth.UserID,
+		auth.Source,
+		auth.SourceID,
+		auth.AccessToken,
+		auth.RefreshToken,
+		(*NullTime)(auth.Expiry),
+		auth.Picture,
+		(*NullTime)(&auth.CreatedAt),
+		(*NullTime)(&auth.UpdatedAt),
+	).Scan(&auth.ID); err != nil {
+		return FormatError(err)
+	}
+
+	return nil
+}
+
+// updateAuth updates tokens, expiry & picture on exist auth object.
+// Returns new state of the auth object.
+func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken string, expiry *time.Time, picture string) (*lil.Auth, error) {
+	// Fetch current object state.
+	auth, err := findAuthByID(ctx, tx, id)
+	if err != nil {
+		return auth, err
+	}
+
+	// Update fields & last updated date.
+	auth.AccessToken = accessToken
+	auth.RefreshToken = refreshToken
+	auth.Expiry = expiry
+	auth.Picture = picture
+	auth.UpdatedAt = tx.now
+
+	// Perform basic field validation.
+	if err := auth.Validate(); err != nil {
+		return auth, err
+	}
+
+	// Execute SQL update query.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE auths
+		SET access_token = ?,
+		    refresh_token = ?,
+		    expiry = ?,
+		    picture = ?,
+		    updated_at = ?
+		WHERE id = ?
+	`,
+		auth.AccessToken,
+		auth.RefreshToken,
+		(*NullTime)(auth.Expiry),
+		auth.Picture,
+		(*NullTime)(&auth.UpdatedAt),
+		id,
+	); err != nil {
+		return auth, FormatError(err)
+	}
+
+	return auth, nil
+}
+
+// deleteAuth permanently removes an auth object by ID. Returns ECONFLICT if
+// it is the last auth object of the user.
+func deleteAuth(ctx context.Context, tx *Tx, id int) error {
+	// Verify object exists & that the user is the owner of the auth.
+	auth, err := findAuthByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if auth.UserID != lil.UserIDFromContext(ctx) {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to delete this auth.")
+	}
+
+	// Users must keep at least one way to log in.
+	if _, n, err := findAuths(ctx, tx, lil.AuthFilter{UserID: &auth.UserID}); err != nil {
+		return err
+	} else if n <= 1 {
+		return lil.Errorf(lil.ECONFLICT, "You cannot unlink your last login provider.")
+	}
+
+	// Remove row from database.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM auths WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// attachAuthAssociations is a helper function to fetch & attach the associated user
+// to the auth object.
+func attachAuthAssociations(ctx context.Context, tx *Tx, auth *lil.Auth) (err error) {
+	if auth.User, err = findUserByID(ctx, tx, auth.UserID); err != nil {
+		return fmt.Errorf("attach auth user: %w", err)
+	}
+	return nil
+}
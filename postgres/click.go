@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+// Ensure service implements interface.
+var _ lil.ClickService = (*ClickService)(nil)
+
+// Default settings for the click writer.
+const (
+	DefaultClickBufferSize    = 1024
+	DefaultClickFlushInterval = time.Second
+)
+
+// ClickService represents a service for recording & aggregating clicks.
+//
+// Clicks are buffered in memory and written in batches by a background
+// goroutine so that recording a click never waits on the database.
+type ClickService struct {
+	db *DB
+
+	clicks  chan *lil.Click
+	closing chan struct{}
+	done    chan struct{}
+
+	// Maximum number of pending clicks. Clicks are dropped when the
+	// buffer is full.
+	BufferSize int
+
+	// Maximum time a click waits in the buffer before being written.
+	FlushInterval time.Duration
+}
+
+// NewClickService returns a new instance of ClickService attached to DB.
+func NewClickService(db *DB) *ClickService {
+	return &ClickService{
+		db:            db,
+		BufferSize:    DefaultClickBufferSize,
+		FlushInterval: DefaultClickFlushInterval,
+	}
+}
+
+// Open starts the background writer.
+func (s *ClickService) Open() error {
+	if s.BufferSize <= 0 {
+		return fmt.Errorf("click buffer size must be positive")
+	} else if s.FlushInterval <= 0 {
+		return fmt.Errorf("click flush interval must be positive")
+	}
+
+	s.clicks = make(chan *lil.Click, s.BufferSize)
+	s.closing = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+
+	return nil
+}
+
+// Close stops the background writer after flushing pending clicks.
+func (s *ClickService) Close() error {
+	if s.closing == nil {
+		return nil
+	}
+	close(s.closing)
+	<-s.done
+	return nil
+}
+
+// RecordClick queues a click to be written. Returns an error if the buffer
+// is full or the service is not open.
+func (s *ClickService) RecordClick(ctx context.Context, click *lil.Click) error {
+	click.CreatedAt = s.db.Now().UTC().Truncate(time.Second)
+
+	select {
+	case <-s.closing:
+		return fmt.Errorf("click service closed")
+	default:
+	}
+
+	select {
+	case s.clicks <- click:
+		return nil
+	default:
+		return fmt.Errorf("click buffer full, dropping click: key=%q", click.ShortKey)
+	}
+}
+
+// FindClickStats returns the aggregated clicks of a Short. Returns ENOTFOUND
+// if the Short does not exist or does not belong to the current user.
+func (s *ClickService) FindClickStats(ctx context.Context, key string) (*lil.ClickStats, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Ensure the short exists & belongs to the current user.
+	if _, err := findShortByKey(ctx, tx, key, false); err != nil {
+		return nil, err
+	}
+
+	return findClickStats(ctx, tx, key)
+}
+
+// run reads clicks from the buffer and writes them in batches.
+func (s *ClickService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*lil.Click, 0, s.BufferSize)
+	for {
+		select {
+		case click := <-s.clicks:
+			if batch = append(batch, click); len(batch) < s.BufferSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-s.closing:
+			// Drain pending clicks before the final flush.
+			for len(s.clicks) > 0 {
+				batch = append(batch, <-s.clicks)
+			}
+			s.flush(batch)
+			return
+		}
+
+		s.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush writes a batch of clicks. Errors are logged as there is no caller
+// to report them to.
+func (s *ClickService) flush(clicks []*lil.Click) {
+	if len(clicks) == 0 {
+		return
+	}
+
+	if err := s.insertClicks(context.Background(), clicks); err != nil {
+		log.Printf("postgres: cannot write %d clicks: %s", len(clicks), err)
+	}
+}
+
+func (s *ClickService) insertClicks(ctx context.Context, clicks []*lil.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, click := range clicks {
+		if err := createClick(ctx, tx, click); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// createClick inserts a click. Clicks on shorts that have been deleted
+// since the click was recorded are silently discarded.
+func createClick(ctx context.Context, tx *Tx, click *lil.Click) error {
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO clicks (
+			short_key,
+			referrer,
+			user_agent,
+			ip_hash,
+			created_at
+		)
+		SELECT ?, ?, ?, ?, ?::timestamptz
+		WHERE EXISTS (SELECT 1 FROM shorts WHERE key = ?)
+		RETURNING id
+	`,
+		click.ShortKey,
+		click.Referrer,
+		click.UserAgent,
+		click.IPHash,
+		(*NullTime)(&click.CreatedAt),
+		click.ShortKey,
+	).Scan(&click.ID); err == sql.ErrNoRows {
+		return nil // short deleted
+	} else if err != nil {
+		return FormatError(err)
+	}
+
+	return nil
+}
+
+// findClickStats aggregates the clicks of a short, in total and per day.
+func findClickStats(ctx context.Context, tx *Tx, key string) (*lil.ClickStats, error) {
+	stats := &lil.ClickStats{ShortKey: key, Days: make([]*lil.ClickDay, 0)}
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT ip_hash)
+		FROM clicks
+		WHERE short_key = ?
+	`, key).Scan(&stats.Total, &stats.Unique); err != nil {
+		return nil, FormatError(err)
+	}
+
+	// Days are grouped by their UTC date, whatever the session time zone.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day,
+			COUNT(*),
+			COUNT(DISTINCT ip_hash)
+		FROM clicks
+		WHERE short_key = ?
+		GROUP BY day
+		ORDER BY day ASC
+	`, key)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day lil.ClickDay
+		var date string
+		if err := rows.Scan(&date, &day.Total, &day.Unique); err != nil {
+			return nil, err
+		}
+		if day.Date, err = time.Parse("2006-01-02", date); err != nil {
+			return nil, err
+		}
+		stats.Days = append(stats.Days, &day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
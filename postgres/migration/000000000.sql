@@ -0,0 +1,151 @@
+-- initial migration, matching the schema of every SQLite migration.
+CREATE TABLE users (
+	id             BIGSERIAL PRIMARY KEY,
+	name           TEXT NOT NULL,
+	email          TEXT UNIQUE,
+	email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+	api_key_hash   TEXT NOT NULL UNIQUE,
+	admin          BOOLEAN NOT NULL DEFAULT FALSE,
+	suspended_at   TIMESTAMPTZ,
+	created_at     TIMESTAMPTZ NOT NULL,
+	updated_at     TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE auths (
+	id            BIGSERIAL PRIMARY KEY,
+	user_id       BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	source        TEXT NOT NULL,
+	source_id     TEXT NOT NULL,
+	access_token  TEXT NOT NULL,
+	refresh_token TEXT NOT NULL,
+	expiry        TIMESTAMPTZ,
+	picture       TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL,
+
+	UNIQUE(user_id, source),  -- one source per user
+	UNIQUE(source, source_id) -- one auth per source user
+);
+
+-- teams sharing the ownership of shorts
+CREATE TABLE teams (
+	id         BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE team_members (
+	team_id    BIGINT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+	user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	role       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+
+	PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX team_members_user_id_idx ON team_members (user_id);
+
+-- shorts of a deleted team go back to their owner. The moderation date is
+-- kept to tell the statuses set by administrators apart.
+CREATE TABLE shorts (
+	key          TEXT PRIMARY KEY,
+	url          TEXT NOT NULL,
+	owner_id     BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	team_id      BIGINT REFERENCES teams (id) ON DELETE SET NULL,
+	activates_at TIMESTAMPTZ,
+	expires_at   TIMESTAMPTZ,
+	status       TEXT NOT NULL DEFAULT 'active',
+	moderated_at TIMESTAMPTZ,
+	host         TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX shorts_expires_at_idx ON shorts (expires_at);
+CREATE INDEX shorts_team_id_idx ON shorts (team_id);
+CREATE INDEX shorts_status_idx ON shorts (status);
+CREATE INDEX shorts_host_idx ON shorts (host);
+
+-- expired shorts are moved here by the sweeper when archiving is enabled.
+CREATE TABLE shorts_archive (
+	key          TEXT NOT NULL,
+	url          TEXT NOT NULL,
+	owner_id     BIGINT NOT NULL,
+	team_id      BIGINT,
+	activates_at TIMESTAMPTZ,
+	expires_at   TIMESTAMPTZ,
+	status       TEXT NOT NULL DEFAULT 'active',
+	moderated_at TIMESTAMPTZ,
+	created_at   TIMESTAMPTZ NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL,
+	archived_at  TIMESTAMPTZ NOT NULL
+);
+
+-- click analytics
+CREATE TABLE clicks (
+	id         BIGSERIAL PRIMARY KEY,
+	short_key  TEXT NOT NULL REFERENCES shorts (key) ON DELETE CASCADE,
+	referrer   TEXT NOT NULL,
+	user_agent TEXT NOT NULL,
+	ip_hash    TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX clicks_short_key_idx ON clicks (short_key, created_at);
+
+-- clicks of the shorts moved to shorts_archive by the sweeper. Clicks of
+-- deleted shorts are removed along with them.
+CREATE TABLE clicks_archive (
+	short_key   TEXT NOT NULL,
+	referrer    TEXT NOT NULL,
+	user_agent  TEXT NOT NULL,
+	ip_hash     TEXT NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	archived_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX clicks_archive_short_key_idx ON clicks_archive (short_key, archived_at);
+
+-- actions performed with administrator privileges. The actor is NULL for
+-- maintenance tools.
+CREATE TABLE audit_log (
+	id         BIGSERIAL PRIMARY KEY,
+	actor_id   BIGINT REFERENCES users (id) ON DELETE SET NULL,
+	action     TEXT NOT NULL,
+	target     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+-- named api tokens. Only a hash of the secret is stored and scopes are
+-- separated by spaces.
+CREATE TABLE tokens (
+	id          BIGSERIAL PRIMARY KEY,
+	user_id     BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name        TEXT NOT NULL,
+	scopes      TEXT NOT NULL,
+	secret_hash TEXT NOT NULL UNIQUE,
+	expires_at  TIMESTAMPTZ,
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX tokens_user_id_idx ON tokens (user_id);
+
+-- server-side browser sessions. The cookie only holds the secret, of which
+-- only a hash is stored.
+CREATE TABLE sessions (
+	id           BIGSERIAL PRIMARY KEY,
+	user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	secret_hash  TEXT NOT NULL UNIQUE,
+	csrf_token   TEXT NOT NULL,
+	ip_address   TEXT NOT NULL,
+	user_agent   TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
@@ -0,0 +1,417 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/dbmetrics"
+	"github.com/lib/pq"
+)
+
+//go:embed migration/*.sql
+var migrationFS embed.FS
+
+// migrationLockID is the key of the advisory lock held while migrating.
+const migrationLockID = 0x6c696c64 // "lild"
+
+// DB represents the database connection.
+type DB struct {
+	db     *sql.DB
+	ctx    context.Context // background context
+	cancel func()          // cancel background context
+
+	// Datasource name, either as a URL or as key/value pairs.
+	DSN string
+
+	// If set, pending migrations are not executed on Open(). This allows
+	// the migration state of a database to be inspected without changing it.
+	NoMigrate bool
+
+	// Returns the current time. Defaults to time.Now().
+	// Can be mocked for tests.
+	Now func() time.Time
+
+	// Expired shorts are swept every SweepInterval once they have been
+	// expired for longer than SweepGracePeriod. If SweepArchive is set, they
+	// are archived along with their clicks instead of deleted. A zero interval
+	// disables the sweeper.
+	SweepInterval    time.Duration
+	SweepGracePeriod time.Duration
+	SweepArchive     bool
+}
+
+// NewDB returns a new instance of DB associated with the given datasource name.
+func NewDB(dsn string) *DB {
+	db := &DB{
+		DSN: dsn,
+		Now: time.Now,
+	}
+	db.ctx, db.cancel = context.WithCancel(context.Background())
+	return db
+}
+
+// Open opens the database connection.
+func (db *DB) Open() (err error) {
+	// Ensure a DSN is set before attempting to open the database.
+	if db.DSN == "" {
+		return fmt.Errorf("dsn required")
+	}
+
+	// Connect to the database. The driver connects lazily so ping the server
+	// to report a bad DSN on startup.
+	if db.db, err = sql.Open("postgres", db.DSN); err != nil {
+		return err
+	} else if err := db.db.Ping(); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	if !db.NoMigrate {
+		if err := db.migrate(); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	// Monitor stats in background goroutine.
+	go db.monitor()
+
+	// Remove expired shorts in background goroutine, if enabled.
+	if db.SweepInterval > 0 {
+		go db.sweep()
+	}
+
+	return nil
+}
+
+// migrate sets up migration tracking and executes pending migration files.
+//
+// Migration files are embedded in the postgres/migration folder and are
+// executed in lexigraphical order.
+//
+// Once a migration is run, its name is stored in the 'migrations' table so it
+// is not re-executed. Migrations run in a transaction to prevent partial
+// migrations.
+func (db *DB) migrate() error {
+	// Advisory locks are held by a session so a single connection is used
+	// for the whole run.
+	conn, err := db.db.Conn(db.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Serialize migrations of instances starting at the same time until every
+	// migration has run.
+	if _, err := conn.ExecContext(db.ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("cannot lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	// Ensure the 'migrations' table exists so we don't duplicate migrations.
+	if _, err := conn.ExecContext(db.ctx, `CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);`); err != nil {
+		return fmt.Errorf("cannot create migrations table: %w", err)
+	}
+
+	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// Loop over all migration files and execute them in order.
+	for _, name := range names {
+		if err := db.migrateFile(conn, name); err != nil {
+			return fmt.Errorf("migration error: name=%q err=%w", name, err)
+		}
+	}
+	return nil
+}
+
+// Migration represents an embedded migration file & whether it has been
+// executed against the database.
+type Migration struct {
+	Name    string
+	Applied bool
+}
+
+// Migrations returns every embedded migration in execution order.
+func (db *DB) Migrations(ctx context.Context) ([]Migration, error) {
+	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	// The migrations table does not exist until the first migration runs.
+	var exists bool
+	if err := db.db.QueryRowContext(ctx, `SELECT to_regclass('migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]bool)
+	if exists {
+		rows, err := db.db.QueryContext(ctx, `SELECT name FROM migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			applied[name] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, len(names))
+	for i, name := range names {
+		migrations[i] = Migration{Name: name, Applied: applied[name]}
+	}
+	return migrations, nil
+}
+
+// migrateFile runs a single migration file within a transaction. On success,
+// the migration file name is saved to the "migrations" table to prevent
+// re-running.
+func (db *DB) migrateFile(conn *sql.Conn, name string) error {
+	tx, err := conn.BeginTx(db.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Ensure migration has not already been run.
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = $1`, name).Scan(&n); err != nil {
+		return err
+	} else if n != 0 {
+		return nil // already run migration, skip
+	}
+
+	// Read and execute migration file.
+	if buf, err := fs.ReadFile(migrationFS, name); err != nil {
+		return err
+	} else if _, err := tx.Exec(string(buf)); err != nil {
+		return err
+	}
+
+	// Insert record into migrations to prevent re-running migration.
+	if _, err := tx.Exec(`INSERT INTO migrations (name) VALUES ($1)`, name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close closes the database connection.
+func (db *DB) Close() error {
+	// Cancel background context.
+	db.cancel()
+
+	// Close database.
+	if db.db != nil {
+		return db.db.Close()
+	}
+	return nil
+}
+
+// BeginTx starts a transaction and returns a wrapper Tx type. This type
+// provides a reference to the database and a fixed timestamp at the start of
+// the transaction. The timestamp allows us to mock time during tests as well.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return wrapper Tx that includes the transaction start time.
+	return &Tx{
+		Tx:  tx,
+		db:  db,
+		now: db.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// monitor runs in a goroutine and periodically publishes the statistics of
+// the connection pool.
+func (db *DB) monitor() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dbmetrics.UpdateStats(db.db.Stats())
+	}
+}
+
+// sweep runs in a goroutine and periodically removes expired shorts.
+func (db *DB) sweep() {
+	ticker := time.NewTicker(db.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if n, err := db.SweepShorts(db.ctx, db.SweepGracePeriod, db.SweepArchive); err != nil {
+			log.Printf("postgres: cannot sweep expired shorts: %s", err)
+		} else if n > 0 {
+			log.Printf("postgres: swept %d expired shorts", n)
+		}
+	}
+}
+
+// Tx wraps the SQL Tx object to provide a timestamp at the start of the transaction.
+//
+// Queries are written with "?" placeholders, as for SQLite, and are rewritten
+// to PostgreSQL's numbered placeholders before being executed.
+type Tx struct {
+	*sql.Tx
+	db  *DB
+	now time.Time
+}
+
+// ExecContext executes a query without returning any rows.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, rebind(query), args...)
+}
+
+// QueryContext executes a query that returns rows.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, rebind(query), args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one row.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, rebind(query), args...)
+}
+
+// rebind replaces the "?" placeholders of query with "$1", "$2", etc.
+// Question marks within string literals are left untouched.
+func rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	var n int
+	var quoted bool
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			quoted = !quoted
+			b.WriteByte(c)
+		case c == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// NullTime represents a helper wrapper for time.Time. Times are stored in UTC
+// with second precision, as for SQLite. Also supports NULL for zero time.
+type NullTime time.Time
+
+// Scan reads a time value from the database.
+func (n *NullTime) Scan(value interface{}) error {
+	if value == nil {
+		*(*time.Time)(n) = time.Time{}
+		return nil
+	} else if value, ok := value.(time.Time); ok {
+		*(*time.Time)(n) = value.UTC()
+		return nil
+	}
+	return fmt.Errorf("NullTime: cannot scan to time.Time: %T", value)
+}
+
+// Value formats a time value for the database.
+func (n *NullTime) Value() (driver.Value, error) {
+	if n == nil || (*time.Time)(n).IsZero() {
+		return nil, nil
+	}
+	return (*time.Time)(n).UTC().Truncate(time.Second), nil
+}
+
+type DBUrl url.URL
+
+func (u *DBUrl) Scan(value any) error {
+	valueStr, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("DBUrl: cannot scan to url.URL: %T", value)
+	}
+	ur, err := url.Parse(valueStr)
+	if err != nil {
+		return err
+	}
+	*u = DBUrl(*ur)
+	return nil
+}
+
+func (u DBUrl) Value() (driver.Value, error) {
+	return (*url.URL)(&u).String(), nil
+}
+
+// urlHost returns the lowercase host of u, without the port. Stored
+// alongside shorts so that they can be searched by host.
+func urlHost(u *url.URL) string {
+	return strings.ToLower(u.Hostname())
+}
+
+// FormatLimitOffset returns a SQL string for a given limit & offset.
+// Clauses are only added if limit and/or offset are greater than zero.
+func FormatLimitOffset(limit, offset int) string {
+	if limit > 0 && offset > 0 {
+		return fmt.Sprintf(`LIMIT %d OFFSET %d`, limit, offset)
+	} else if limit > 0 {
+		return fmt.Sprintf(`LIMIT %d`, limit)
+	} else if offset > 0 {
+		return fmt.Sprintf(`OFFSET %d`, offset)
+	}
+	return ""
+}
+
+// FormatError returns err as a lil error, if possible.
+// Otherwise returns the original error.
+func FormatError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *pq.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	switch {
+	case e.Code.Name() == "unique_violation" && e.Constraint == "shorts_pkey":
+		return lil.Errorf(lil.ECONFLICT, "Short with the same key already exists.")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,478 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+var _ lil.TeamService = (*TeamService)(nil)
+
+// TeamService represents a service for managing teams & their members.
+type TeamService struct {
+	db *DB
+}
+
+// NewTeamService returns a new instance of TeamService attached to DB.
+func NewTeamService(db *DB) *TeamService {
+	return &TeamService{db: db}
+}
+
+// FindTeamByID retrieves a team by ID along with its members. Returns
+// ENOTFOUND if the team does not exist or the current user is not a member.
+func (s *TeamService) FindTeamByID(ctx context.Context, id int) (*lil.Team, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	teams, _, err := findTeams(ctx, tx, lil.TeamFilter{ID: &id}, false)
+	if err != nil {
+		return nil, err
+	} else if len(teams) == 0 {
+		return nil, lil.Errorf(lil.ENOTFOUND, "Team not found.")
+	} else if err := attachTeamMemberUsers(ctx, tx, teams[0]); err != nil {
+		return nil, err
+	}
+	return teams[0], nil
+}
+
+// FindTeams retrieves the teams of the current user along with their members.
+// Also returns the total count of matching teams.
+func (s *TeamService) FindTeams(ctx context.Context, filter lil.TeamFilter) ([]*lil.Team, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findTeams(ctx, tx, filter, false)
+}
+
+// CreateTeam creates a new team with the current user as its owner.
+func (s *TeamService) CreateTeam(ctx context.Context, team *lil.Team) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createTeam(ctx, tx, team); err != nil {
+		return err
+	} else if err := attachTeamMemberUsers(ctx, tx, team); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateTeam updates a team. Returns EUNAUTHORIZED if the current user does
+// not own the team. Returns ENOTFOUND if the team does not exist.
+func (s *TeamService) UpdateTeam(ctx context.Context, id int, upd lil.TeamUpdate) (*lil.Team, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	team, err := updateTeam(ctx, tx, id, upd)
+	if err != nil {
+		return nil, err
+	} else if err := attachTeamMemberUsers(ctx, tx, team); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// DeleteTeam permanently deletes a team. The shorts of the team are kept by
+// their owners. Returns EUNAUTHORIZED if the current user does not own the
+// team. Returns ENOTFOUND if the team does not exist.
+func (s *TeamService) DeleteTeam(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTeam(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetTeamMember adds a user to a team or changes the role of an existing
+// member. Returns EUNAUTHORIZED if the current user does not own the team.
+func (s *TeamService) SetTeamMember(ctx context.Context, teamID, userID int, role string) (*lil.TeamMember, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	member, err := setTeamMember(ctx, tx, teamID, userID, role)
+	if err != nil {
+		return nil, err
+	} else if member.User, err = findUserByID(ctx, tx, member.UserID); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveTeamMember removes a user from a team. Members can remove
+// themselves, otherwise returns EUNAUTHORIZED if the current user does not
+// own the team.
+func (s *TeamService) RemoveTeamMember(ctx context.Context, teamID, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeTeamMember(ctx, tx, teamID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findTeamByID returns a team by ID along with its members, regardless of
+// the current user. Returns ENOTFOUND if the team does not exist.
+func findTeamByID(ctx context.Context, tx *Tx, id int) (*lil.Team, error) {
+	teams, _, err := findTeams(ctx, tx, lil.TeamFilter{ID: &id}, true)
+	if err != nil {
+		return nil, err
+	} else if len(teams) == 0 {
+		return nil, lil.Errorf(lil.ENOTFOUND, "Team not found.")
+	}
+	return teams[0], nil
+}
+
+// findTeams returns teams matching filter along with their members. Unless
+// all is set, teams are limited to those the current user is a member of.
+func findTeams(ctx context.Context, tx *Tx, filter lil.TeamFilter, all bool) (_ []*lil.Team, n int, err error) {
+	where, args := []string{"1 = 1"}, []any{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.Name; v != nil {
+		where, args = append(where, "name = ?"), append(args, *v)
+	}
+
+	// Limit teams to those the user is a member of, unless the caller is
+	// an administrator.
+	if !all && !lil.AdminFromContext(ctx) {
+		userID := lil.UserIDFromContext(ctx)
+		where, args = append(where, "id IN (SELECT team_id FROM team_members WHERE user_id = ?)"), append(args, userID)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+		    id,
+		    name,
+		    created_at,
+		    updated_at,
+		    COUNT(*) OVER()
+		FROM teams
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id ASC
+		`+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	teams := make([]*lil.Team, 0)
+	for rows.Next() {
+		var team lil.Team
+		if err := rows.Scan(
+			&team.ID,
+			&team.Name,
+			(*NullTime)(&team.CreatedAt),
+			(*NullTime)(&team.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		teams = append(teams, &team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+	rows.Close()
+
+	for _, team := range teams {
+		if team.Members, err = findTeamMembers(ctx, tx, team.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return teams, n, nil
+}
+
+// findTeamMembers returns the members of a team, owners first.
+func findTeamMembers(ctx context.Context, tx *Tx, teamID int) (_ []*lil.TeamMember, err error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+		    team_id,
+		    user_id,
+		    role,
+		    created_at,
+		    updated_at
+		FROM team_members
+		WHERE team_id = ?
+		ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, user_id ASC
+	`, teamID)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	members := make([]*lil.TeamMember, 0)
+	for rows.Next() {
+		var member lil.TeamMember
+		if err := rows.Scan(
+			&member.TeamID,
+			&member.UserID,
+			&member.Role,
+			(*NullTime)(&member.CreatedAt),
+			(*NullTime)(&member.UpdatedAt),
+		); err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return members, nil
+}
+
+// createTeam creates a new team and adds the current user as its owner.
+func createTeam(ctx context.Context, tx *Tx, team *lil.Team) error {
+	userID := lil.UserIDFromContext(ctx)
+	if userID == 0 {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You must be logged in to create a team.")
+	}
+
+	team.CreatedAt = tx.now
+	team.UpdatedAt = team.CreatedAt
+
+	if err := team.Validate(); err != nil {
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO teams (
+			name,
+			created_at,
+			updated_at
+		)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`,
+		team.Name,
+		(*NullTime)(&team.CreatedAt),
+		(*NullTime)(&team.UpdatedAt),
+	).Scan(&team.ID); err != nil {
+		return FormatError(err)
+	}
+
+	owner := &lil.TeamMember{TeamID: team.ID, UserID: userID, Role: lil.TeamRoleOwner}
+	if err := createTeamMember(ctx, tx, owner); err != nil {
+		return err
+	}
+	team.Members = []*lil.TeamMember{owner}
+
+	return nil
+}
+
+// updateTeam updates the fields of a team. Returns EUNAUTHORIZED if the
+// current user does not own the team.
+func updateTeam(ctx context.Context, tx *Tx, id int, upd lil.TeamUpdate) (*lil.Team, error) {
+	team, err := findTeamByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if !lil.CanEditTeam(ctx, team) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to update this team.")
+	}
+
+	if v := upd.Name; v != nil {
+		team.Name = *v
+	}
+	team.UpdatedAt = tx.now
+
+	if err := team.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE teams
+		SET name = ?,
+		    updated_at = ?
+		WHERE id = ?
+	`,
+		team.Name,
+		(*NullTime)(&team.UpdatedAt),
+		id,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return team, nil
+}
+
+// deleteTeam permanently removes a team. Returns EUNAUTHORIZED if the
+// current user does not own the team.
+func deleteTeam(ctx context.Context, tx *Tx, id int) error {
+	if team, err := findTeamByID(ctx, tx, id); err != nil {
+		return err
+	} else if !lil.CanEditTeam(ctx, team) {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to delete this team.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM teams WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// setTeamMember adds a member or changes its role. A team always keeps at
+// least one owner.
+func setTeamMember(ctx context.Context, tx *Tx, teamID, userID int, role string) (*lil.TeamMember, error) {
+	team, err := findTeamByID(ctx, tx, teamID)
+	if err != nil {
+		return nil, err
+	} else if !lil.CanEditTeam(ctx, team) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to manage the members of this team.")
+	} else if _, err := findUserByID(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	member := &lil.TeamMember{TeamID: teamID, UserID: userID, Role: role}
+	if err := member.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Insert the member if it does not exist yet.
+	current := team.MemberRole(userID)
+	if current == "" {
+		if err := createTeamMember(ctx, tx, member); err != nil {
+			return nil, err
+		}
+		return member, nil
+	}
+
+	if current == lil.TeamRoleOwner && role != lil.TeamRoleOwner && countTeamOwners(team) == 1 {
+		return nil, lil.Errorf(lil.EINVALID, "A team needs at least one owner.")
+	}
+
+	member.UpdatedAt = tx.now
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE team_members
+		SET role = ?,
+		    updated_at = ?
+		WHERE team_id = ? AND user_id = ?
+	`,
+		member.Role,
+		(*NullTime)(&member.UpdatedAt),
+		teamID,
+		userID,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	// Re-read the membership to return its creation date.
+	members, err := findTeamMembers(ctx, tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.UserID == userID {
+			return m, nil
+		}
+	}
+	return member, nil
+}
+
+// createTeamMember inserts a new membership.
+func createTeamMember(ctx context.Context, tx *Tx, member *lil.TeamMember) error {
+	member.CreatedAt = tx.now
+	member.UpdatedAt = member.CreatedAt
+
+	if err := member.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO team_members (
+			team_id,
+			user_id,
+			role,
+			created_at,
+			updated_at
+		)
+		VALUES (?, ?, ?, ?, ?)
+	`,
+		member.TeamID,
+		member.UserID,
+		member.Role,
+		(*NullTime)(&member.CreatedAt),
+		(*NullTime)(&member.UpdatedAt),
+	); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// removeTeamMember removes a member from a team. A team always keeps at
+// least one owner.
+func removeTeamMember(ctx context.Context, tx *Tx, teamID, userID int) error {
+	team, err := findTeamByID(ctx, tx, teamID)
+	if err != nil {
+		return err
+	} else if userID != lil.UserIDFromContext(ctx) && !lil.CanEditTeam(ctx, team) {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to manage the members of this team.")
+	}
+
+	switch team.MemberRole(userID) {
+	case "":
+		return lil.Errorf(lil.ENOTFOUND, "Team member not found.")
+	case lil.TeamRoleOwner:
+		if countTeamOwners(team) == 1 {
+			return lil.Errorf(lil.EINVALID, "A team needs at least one owner.")
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// countTeamOwners returns the number of members owning the team.
+func countTeamOwners(team *lil.Team) (n int) {
+	for _, m := range team.Members {
+		if m.Role == lil.TeamRoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// attachTeamMemberUsers is a helper function to look up and attach the user
+// of each team member.
+func attachTeamMemberUsers(ctx context.Context, tx *Tx, team *lil.Team) (err error) {
+	for _, m := range team.Members {
+		if m.User, err = findUserByID(ctx, tx, m.UserID); err != nil {
+			return fmt.Errorf("attach team member user: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package lil
+
+import (
+	"context"
+	"time"
+)
+
+// SessionIdleTimeout is the duration after which a session that has not been
+// used expires.
+const SessionIdleTimeout = 30 * 24 * time.Hour
+
+// Session represents a browser session of a user. Sessions are stored on the
+// server so they can be listed & revoked.
+type Session struct {
+	ID int `json:"id"`
+
+	// User logged in by the session.
+	UserID int   `json:"userID"`
+	User   *User `json:"-"`
+
+	// Opaque identifier stored in the session cookie. Only a hash is stored
+	// so the secret is only set when the session is created.
+	Secret string `json:"-"`
+
+	// Token which must be submitted along with every form posted by the
+	// browser using the session. Generated with the session.
+	CSRFToken string `json:"-"`
+
+	// Client which created the session.
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+
+	// Timestamps for session creation & last use.
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// Validate returns an error if the session contains invalid fields.
+func (s *Session) Validate() error {
+	if s.UserID == 0 {
+		return Errorf(EINVALID, "User required.")
+	}
+	return nil
+}
+
+// IsExpired returns true if the session has been idle for too long at the
+// given time.
+func (s *Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.LastSeenAt.Add(SessionIdleTimeout))
+}
+
+// SessionService represents a service for managing browser sessions.
+type SessionService interface {
+	// Retrieves the session matching a secret along with its user. Returns
+	// EUNAUTHORIZED if the secret is unknown or the session has expired.
+	FindSessionBySecret(ctx context.Context, secret string) (*Session, error)
+
+	// Retrieves the sessions of the current user, most recently used first.
+	// Administrators can list the sessions of any user.
+	FindSessions(ctx context.Context, filter SessionFilter) ([]*Session, int, error)
+
+	// Creates a new session for session.UserID and sets session.Secret.
+	// This is called on login, before the user is attached to the context.
+	CreateSession(ctx context.Context, session *Session) error
+
+	// Marks a session as used at the current time.
+	TouchSession(ctx context.Context, id int) error
+
+	// Permanently revokes a session. Returns ENOTFOUND if the session does
+	// not exist or does not belong to the current user.
+	RevokeSession(ctx context.Context, id int) error
+
+	// Revokes every session of a user. Returns EUNAUTHORIZED unless the
+	// current user is that user or an administrator.
+	RevokeUserSessions(ctx context.Context, userID int) error
+}
+
+// SessionFilter represents a filter passed to FindSessions().
+type SessionFilter struct {
+	ID     *int `json:"id"`
+	UserID *int `json:"userID"`
+
+	// Restrict to subset of results.
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
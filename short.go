@@ -3,7 +3,9 @@ package lil
 import (
 	"context"
 	"net/url"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -11,6 +13,20 @@ var (
 	ErrEmptyKey         = Errorf(EINVALID, "Missing Key.")
 	ErrEmptyOwner       = Errorf(EINVALID, "Missing owner.")
 	ErrInvalidURLScheme = Errorf(EINVALID, "Invalid URL scheme. Only http and https are supported.")
+	ErrInvalidLifetime  = Errorf(EINVALID, "Expiration must be after activation.")
+	ErrShortExpired     = Errorf(EEXPIRED, "This short has expired.")
+	ErrShortNotActive   = Errorf(ENOTACTIVE, "This short is not active yet.")
+	ErrInvalidStatus    = Errorf(EINVALID, "Invalid status. Only active, disabled and flagged are supported.")
+	ErrShortDisabled    = Errorf(ENOTACTIVE, "This short has been disabled.")
+	ErrShortModerated   = Errorf(EUNAUTHORIZED, "This short has been moderated by an administrator and cannot be changed.")
+)
+
+// Short statuses. Disabled shorts cannot be followed while flagged shorts
+// show a warning before redirecting.
+const (
+	ShortStatusActive   = "active"
+	ShortStatusDisabled = "disabled"
+	ShortStatusFlagged  = "flagged"
 )
 
 // Short defines a shortened URL.
@@ -24,6 +40,23 @@ type Short struct {
 	Owner   *User `json:"-"`
 	OwnerID int   `json:"-"`
 
+	// Optional team sharing the ownership of the short.
+	Team   *Team `json:"-"`
+	TeamID *int  `json:"team_id,omitempty"`
+
+	// Optional lifetime of the short. A short can only be followed
+	// after ActivatesAt and before ExpiresAt, if they are set.
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	// Either ShortStatusActive, ShortStatusDisabled or ShortStatusFlagged.
+	// Defaults to ShortStatusActive.
+	Status string `json:"status"`
+
+	// Set when an administrator changes the status of the short. Only
+	// administrators can update or delete a moderated short.
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+
 	// CreatedAt and UpdatedAt get filled by the service.
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -38,7 +71,9 @@ type ShortService interface {
 
 	// Retrieves a single Short by Key. Returns ENOTFOUND if the Short
 	// object does not exist. Does not check if the Short does belong
-	// to the user.
+	// to the user. Returns ENOTACTIVE or EEXPIRED if the Short cannot
+	// be followed yet or anymore, or has been disabled. The owner & team
+	// are not attached.
 	SearchShort(ctx context.Context, key string) (*Short, error)
 
 	// Retrieves a list of Shorts based on a filter. Returns a count of the
@@ -46,23 +81,60 @@ type ShortService interface {
 	// returned (if you have set the "Limit" field).
 	FindShorts(ctx context.Context, filter ShortFilter) ([]*Short, int, error)
 
+	// Calls fn for every Short matching filter, ordered by key. Shorts are
+	// read from a single transaction so that none is skipped or repeated
+	// while others are changed. Offset & limit are ignored. Stops at the
+	// first error returned by fn and returns it.
+	ForEachShort(ctx context.Context, filter ShortFilter, fn func(*Short) error) error
+
 	// Creates a new Short.
 	CreateShort(ctx context.Context, short *Short) error
 
+	// Updates an existing Short. Returns ENOTFOUND if the Short does not
+	// exist. Returns EUNAUTHORIZED if the user cannot edit the Short, or if
+	// it has been moderated by an administrator.
+	UpdateShort(ctx context.Context, key string, upd ShortUpdate) (*Short, error)
+
 	// Permanently removes a Short. Returns a ENOTFOUND if the key
-	// does not belong to any Short.
+	// does not belong to any Short. Returns EUNAUTHORIZED if the Short
+	// has been moderated by an administrator.
 	DeleteShort(ctx context.Context, key string) error
 }
 
 // ShortFilter represents a filter used by FindShorts().
 type ShortFilter struct {
-	Key *string  `json:"key"`
-	URL *url.URL `json:"url"`
+	Key     *string  `json:"key"`
+	URL     *url.URL `json:"url"`
+	OwnerID *int     `json:"owner_id"`
+	TeamID  *int     `json:"team_id"`
+
+	// Matches shorts pointing to the given host, ignoring case & port.
+	Host *string `json:"host"`
+
+	Status *string `json:"status"`
+
+	// Matches shorts with a key sorting after the given one. Shorts are then
+	// ordered by key so that large result sets can be paged through with
+	// the key of the last short of the previous page.
+	After *string `json:"after"`
 
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
 
+// ShortUpdate represents a set of fields to be updated via UpdateShort().
+type ShortUpdate struct {
+	URL         *url.URL   `json:"url"`
+	ActivatesAt *time.Time `json:"activates_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	// Moves the short to a team. Zero moves it back to its owner.
+	TeamID *int `json:"team_id"`
+
+	// Changes the status of the short.
+	Status *string `json:"status"`
+}
+
 // Validate returns an error if Short has invalid fields.
 // Only performs basic validation.
 func (s *Short) Validate() error {
@@ -82,10 +154,89 @@ func (s *Short) Validate() error {
 		return ErrEmptyOwner
 	}
 
+	if s.ActivatesAt != nil && s.ExpiresAt != nil && !s.ExpiresAt.After(*s.ActivatesAt) {
+		return ErrInvalidLifetime
+	}
+
+	if !IsValidShortStatus(s.Status) {
+		return ErrInvalidStatus
+	}
+
 	return nil
 }
 
-// Only the short owner can delete the short.
+// CheckActive returns ErrShortDisabled if the short has been disabled,
+// ErrShortNotActive if it has not been activated yet, or ErrShortExpired if
+// it has expired, at the given time.
+func (s *Short) CheckActive(now time.Time) error {
+	if s.Status == ShortStatusDisabled {
+		return ErrShortDisabled
+	} else if s.ActivatesAt != nil && now.Before(*s.ActivatesAt) {
+		return ErrShortNotActive
+	} else if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return ErrShortExpired
+	}
+	return nil
+}
+
+// IsValidShortStatus returns true if status is one of the short statuses.
+func IsValidShortStatus(status string) bool {
+	switch status {
+	case ShortStatusActive, ShortStatusDisabled, ShortStatusFlagged:
+		return true
+	}
+	return false
+}
+
+// Only the short owner, an owner or editor of its team, or an administrator
+// can edit the short. The team must be attached to the short.
 func CanEditShort(ctx context.Context, short *Short) bool {
-	return short.OwnerID == UserIDFromContext(ctx)
+	userID := UserIDFromContext(ctx)
+	if short.OwnerID == userID || AdminFromContext(ctx) {
+		return true
+	} else if short.Team != nil {
+		return CanEditTeamShorts(userID, short.Team)
+	}
+	return false
+}
+
+// KeyPolicy defines the rules that a user-chosen (vanity) key must
+// satisfy. Randomly generated keys do not go through the policy.
+type KeyPolicy struct {
+	// Characters allowed in a key.
+	Alphabet string
+
+	// Inclusive bounds on the key length, in characters.
+	MinLength int
+	MaxLength int
+
+	// Keys that cannot be requested by users, such as route names.
+	// Comparison is case-insensitive.
+	Reserved []string
+}
+
+// Validate returns EINVALID if key does not satisfy the policy.
+func (p *KeyPolicy) Validate(key string) error {
+	n := utf8.RuneCountInString(key)
+	if n == 0 {
+		return ErrEmptyKey
+	} else if p.MinLength > 0 && n < p.MinLength {
+		return Errorf(EINVALID, "Key must be at least %d characters long.", p.MinLength)
+	} else if p.MaxLength > 0 && n > p.MaxLength {
+		return Errorf(EINVALID, "Key must be at most %d characters long.", p.MaxLength)
+	}
+
+	for _, r := range key {
+		if !strings.ContainsRune(p.Alphabet, r) {
+			return Errorf(EINVALID, "Key contains an invalid character: %q.", r)
+		}
+	}
+
+	for _, word := range p.Reserved {
+		if strings.EqualFold(key, word) {
+			return Errorf(EINVALID, "Key %q is reserved.", key)
+		}
+	}
+
+	return nil
 }
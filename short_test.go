@@ -0,0 +1,60 @@
+package lil_test
+
+import (
+	"testing"
+
+	"github.com/kriive/lil"
+)
+
+func TestKeyPolicy_Validate(t *testing.T) {
+	policy := lil.KeyPolicy{
+		Alphabet:  "abcdefghijklmnopqrstuvwxyz0123456789-é",
+		MinLength: 3,
+		MaxLength: 8,
+		Reserved:  []string{"login", "admin"},
+	}
+
+	for _, tt := range []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{name: "OK", key: "abc-123"},
+		{name: "MinLength", key: "abc"},
+		{name: "MaxLength", key: "abcdefgh"},
+		{name: "Multibyte", key: "éééééééé"},
+		{name: "ErrEmpty", key: "", err: lil.ErrorMessage(lil.ErrEmptyKey)},
+		{name: "ErrTooShort", key: "ab", err: "Key must be at least 3 characters long."},
+		{name: "ErrTooLong", key: "abcdefghi", err: "Key must be at most 8 characters long."},
+		{name: "ErrCharacter", key: "ab_c", err: `Key contains an invalid character: '_'.`},
+		{name: "ErrUppercase", key: "Abc", err: `Key contains an invalid character: 'A'.`},
+		{name: "ErrReserved", key: "login", err: `Key "login" is reserved.`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.key)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %#v", err)
+				}
+				return
+			}
+
+			if lil.ErrorCode(err) != lil.EINVALID {
+				t.Fatalf("unexpected error: %#v", err)
+			} else if got, want := lil.ErrorMessage(err), tt.err; got != want {
+				t.Fatalf("message=%q, want %q", got, want)
+			}
+		})
+	}
+
+	// Ensure reserved keys are matched case-insensitively and that limits
+	// are ignored when unset.
+	t.Run("ReservedCase", func(t *testing.T) {
+		p := lil.KeyPolicy{Alphabet: "ADMINadmin", Reserved: []string{"admin"}}
+		if err := p.Validate("ADMIN"); lil.ErrorCode(err) != lil.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := p.Validate("a"); err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
@@ -83,12 +83,21 @@ func (s *AuthService) CreateAuth(ctx context.Context, auth *lil.Auth) error {
 	// Check to see if the auth already exists for the given source.
 	if other, err := findAuthBySourceID(ctx, tx, auth.Source, auth.SourceID); err == nil {
 		// If an auth already exists for the source user, update with the new tokens.
-		if other, err = updateAuth(ctx, tx, other.ID, auth.AccessToken, auth.RefreshToken, auth.Expiry); err != nil {
+		if other, err = updateAuth(ctx, tx, other.ID, auth.AccessToken, auth.RefreshToken, auth.Expiry, auth.Picture); err != nil {
 			return fmt.Errorf("cannot update auth: id=%d err=%w", other.ID, err)
 		} else if err := attachAuthAssociations(ctx, tx, other); err != nil {
 			return err
 		}
 
+		// Logging in again confirms the email if the provider reports the
+		// one of the user.
+		if auth.User != nil && auth.User.Email != "" && auth.User.Email == other.User.Email && !other.User.EmailVerified {
+			if err := verifyUserEmail(ctx, tx, other.UserID); err != nil {
+				return err
+			}
+			other.User.EmailVerified = true
+		}
+
 		// Copy found auth back to the caller's arg & return.
 		*auth = *other
 		return tx.Commit()
@@ -99,11 +108,21 @@ func (s *AuthService) CreateAuth(ctx context.Context, auth *lil.Auth) error {
 	// Check if auth has a new user object passed in. It is considered "new" if
 	// the caller doesn't know the database ID for the user.
 	if auth.UserID == 0 && auth.User != nil {
-		// Look up the user by email address. If no user can be found then
-		// create a new user with the auth.User object passed in.
-		if user, err := findUserByEmail(ctx, tx, auth.User.Email); err == nil { // user exists
+		// Look up the user by email address. Providers only report confirmed
+		// emails, so the login is linked to the user only if they confirmed
+		// it too. Otherwise, the email is taken from the user who set it and
+		// a new user is created with the auth.User object passed in.
+		user, err := findUserByEmail(ctx, tx, auth.User.Email)
+		if err == nil && user.EmailVerified { // user exists
 			auth.User = user
-		} else if lil.ErrorCode(err) == lil.ENOTFOUND { // user does not exist
+		} else if err == nil || lil.ErrorCode(err) == lil.ENOTFOUND { // user does not exist
+			if user != nil {
+				if err := releaseUserEmail(ctx, tx, user.ID); err != nil {
+					return fmt.Errorf("cannot release user email: %w", err)
+				}
+			}
+
+			auth.User.EmailVerified = true
 			if err := createUser(ctx, tx, auth.User); err != nil {
 				return fmt.Errorf("cannot create user: %w", err)
 			}
@@ -125,7 +144,8 @@ func (s *AuthService) CreateAuth(ctx context.Context, auth *lil.Auth) error {
 }
 
 // DeleteAuth permanently deletes an authentication object from the system by ID.
-// The parent user object is not removed.
+// The parent user object is not removed. Returns ECONFLICT if it is the last
+// authentication object of the user.
 func (s *AuthService) DeleteAuth(ctx context.Context, id int) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -195,6 +215,7 @@ func findAuths(ctx context.Context, tx *Tx, filter lil.AuthFilter) (_ []*lil.Aut
 		    access_token,
 		    refresh_token,
 		    expiry,
+		    picture,
 		    created_at,
 		    updated_at,
 		    COUNT(*) OVER()
@@ -223,6 +244,7 @@ func findAuths(ctx context.Context, tx *Tx, filter lil.AuthFilter) (_ []*lil.Aut
 			&auth.AccessToken,
 			&auth.RefreshToken,
 			&expiry,
+			&auth.Picture,
 			(*NullTime)(&auth.CreatedAt),
 			(*NullTime)(&auth.UpdatedAt),
 			&n,
@@ -273,10 +295,11 @@ func createAuth(ctx context.Context, tx *Tx, auth *lil.Auth) error {
 			access_token,
 			refresh_token,
 			expiry,
+			picture,
 			created_at,
 			updated_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		auth.UserID,
 		auth.Source,
@@ -284,6 +307,7 @@ func createAuth(ctx context.Context, tx *Tx, auth *lil.Auth) error {
 		auth.AccessToken,
 		auth.RefreshToken,
 		expiry,
+		auth.Picture,
 		(*NullTime)(&auth.CreatedAt),
 		(*NullTime)(&auth.UpdatedAt),
 	)
@@ -301,9 +325,9 @@ func createAuth(ctx context.Context, tx *Tx, auth *lil.Auth) error {
 	return nil
 }
 
-// updateAuth updates tokens & expiry on exist auth object.
+// updateAuth updates tokens, expiry & picture on exist auth object.
 // Returns new state of the auth object.
-func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken string, expiry *time.Time) (*lil.Auth, error) {
+func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken string, expiry *time.Time, picture string) (*lil.Auth, error) {
 	// Fetch current object state.
 	auth, err := findAuthByID(ctx, tx, id)
 	if err != nil {
@@ -314,6 +338,7 @@ func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken s
 	auth.AccessToken = accessToken
 	auth.RefreshToken = refreshToken
 	auth.Expiry = expiry
+	auth.Picture = picture
 	auth.UpdatedAt = tx.now
 
 	// Perform basic field validation.
@@ -334,12 +359,14 @@ func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken s
 		SET access_token = ?,
 		    refresh_token = ?,
 		    expiry = ?,
+		    picture = ?,
 		    updated_at = ?
 		WHERE id = ?
 	`,
 		auth.AccessToken,
 		auth.RefreshToken,
 		expiryStr,
+		auth.Picture,
 		(*NullTime)(&auth.UpdatedAt),
 		id,
 	); err != nil {
@@ -349,15 +376,24 @@ func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken s
 	return auth, nil
 }
 
-// deleteAuth permanently removes an auth object by ID.
+// deleteAuth permanently removes an auth object by ID. Returns ECONFLICT if
+// it is the last auth object of the user.
 func deleteAuth(ctx context.Context, tx *Tx, id int) error {
 	// Verify object exists & that the user is the owner of the auth.
-	if auth, err := findAuthByID(ctx, tx, id); err != nil {
+	auth, err := findAuthByID(ctx, tx, id)
+	if err != nil {
 		return err
 	} else if auth.UserID != lil.UserIDFromContext(ctx) {
 		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to delete this auth.")
 	}
 
+	// Users must keep at least one way to log in.
+	if _, n, err := findAuths(ctx, tx, lil.AuthFilter{UserID: &auth.UserID}); err != nil {
+		return err
+	} else if n <= 1 {
+		return lil.Errorf(lil.ECONFLICT, "You cannot unlink your last login provider.")
+	}
+
 	// Remove row from database.
 	if _, err := tx.ExecContext(ctx, `DELETE FROM auths WHERE id = ?`, id); err != nil {
 		return FormatError(err)
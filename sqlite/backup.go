@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup file names are made of a prefix, the UTC time of the backup and an
+// extension so that they sort chronologically.
+const (
+	backupPrefix     = "lild-"
+	backupTimeLayout = "20060102T150405Z"
+	backupExt        = ".sqlite"
+)
+
+// Backup writes a consistent copy of the database to path while it stays
+// available to other connections. The file must not exist. The copy is
+// written to a temporary file first so that path never holds a partial copy.
+func (db *DB) Backup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Create the file beforehand so that only the owner can read it as it
+	// holds every user & token. VACUUM INTO accepts an empty file.
+	if f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	// VACUUM INTO reads from a single transaction so the copy is consistent
+	// even though the database is written concurrently.
+	if _, err := db.db.ExecContext(ctx, `VACUUM INTO ?`, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// BackupToDir writes a backup named after the current time into dir and
+// removes the oldest backups of the directory so that at most retain remain.
+// A zero retain keeps every backup. Returns the path of the new backup.
+func (db *DB) BackupToDir(ctx context.Context, dir string, retain int) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, backupPrefix+db.Now().UTC().Format(backupTimeLayout)+backupExt)
+	if err := db.Backup(ctx, path); err != nil {
+		return "", err
+	} else if err := rotateBackups(dir, retain); err != nil {
+		return path, fmt.Errorf("rotate backups: %w", err)
+	}
+	return path, nil
+}
+
+// rotateBackups removes the oldest backups of dir, keeping the retain most
+// recent ones. Other files are left untouched.
+func rotateBackups(dir string, retain int) error {
+	if retain <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupExt) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for len(names) > retain {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// backup runs in a goroutine and periodically writes a backup to BackupDir.
+func (db *DB) backup() {
+	ticker := time.NewTicker(db.BackupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if path, err := db.BackupToDir(db.ctx, db.BackupDir, db.BackupRetain); err != nil {
+			log.Printf("sqlite: cannot back up database: %s", err)
+		} else {
+			log.Printf("sqlite: backed up database: path=%q", path)
+		}
+	}
+}
+
+// Verify checks the integrity of the database and ensures that every
+// migration it has applied is known to this version, so that it can be
+// served once pending migrations are executed.
+func (db *DB) Verify(ctx context.Context) error {
+	var result string
+	if err := db.db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	} else if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	// A database without migrations was not created by lild.
+	var n int
+	if err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'`).Scan(&n); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("no migrations table")
+	}
+
+	known, err := fs.Glob(migrationFS, "migration/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(known)
+
+	rows, err := db.db.QueryContext(ctx, `SELECT name FROM migrations ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		} else if i := sort.SearchStrings(known, name); i == len(known) || known[i] != name {
+			return fmt.Errorf("unknown migration %q, the database was written by a newer version", name)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,170 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kriive/lil"
+	"github.com/kriive/lil/sqlite"
+)
+
+func TestDB_Backup(t *testing.T) {
+	// Ensure a backup holds the data of the database & can be verified.
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		user := &lil.User{Name: "susy"}
+		if err := sqlite.NewUserService(db).CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "backup.sqlite")
+		if err := db.Backup(context.Background(), path); err != nil {
+			t.Fatal(err)
+		} else if fi, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+			t.Fatalf("Mode=%v, want %v", got, want)
+		}
+
+		other := sqlite.NewDB(path)
+		other.NoMigrate = true
+		if err := other.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer MustCloseDB(t, other)
+
+		if err := other.Verify(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if u, err := sqlite.NewUserService(other).FindUserByID(context.Background(), user.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := u.Name, "susy"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		}
+	})
+
+	// Ensure existing files are not overwritten.
+	t.Run("ErrExists", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		path := filepath.Join(t.TempDir(), "backup.sqlite")
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		} else if err := db.Backup(context.Background(), path); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+// Ensure a database cannot be locked for a restore while it is open.
+func TestLock(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "db")
+	db := sqlite.NewDB(dsn)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sqlite.Lock(dsn); err != sqlite.ErrLocked {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	MustCloseDB(t, db)
+
+	lock, err := sqlite.Lock(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+
+	if err := sqlite.NewDB(dsn).Open(); err == nil || !strings.Contains(err.Error(), sqlite.ErrLocked.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure only the most recent backups of a directory are retained.
+func TestDB_BackupToDir(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	db.Now = func() time.Time { return now }
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := db.BackupToDir(context.Background(), dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+		now = now.Add(time.Hour)
+	}
+
+	if got, want := filepath.Base(paths[0]), "lild-20220101T000000Z.sqlite"; got != want {
+		t.Fatalf("name=%v, want %v", got, want)
+	} else if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest backup to be removed: %v", err)
+	}
+	for _, name := range []string{paths[1], paths[2], filepath.Join(dir, "notes.txt")} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDB_Verify(t *testing.T) {
+	// Ensure databases written by a newer version are rejected.
+	t.Run("ErrUnknownMigration", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		path := filepath.Join(t.TempDir(), "backup.sqlite")
+		if err := db.Backup(context.Background(), path); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := conn.Exec(`INSERT INTO migrations (name) VALUES ('migration/999999999.sql')`); err != nil {
+			t.Fatal(err)
+		} else if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		other := sqlite.NewDB(path)
+		other.NoMigrate = true
+		if err := other.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer MustCloseDB(t, other)
+
+		if err := other.Verify(context.Background()); err == nil || !strings.Contains(err.Error(), "999999999") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	// Ensure databases not created by lild are rejected.
+	t.Run("ErrNoMigrations", func(t *testing.T) {
+		db := sqlite.NewDB(":memory:")
+		db.NoMigrate = true
+		if err := db.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer MustCloseDB(t, db)
+
+		if err := db.Verify(context.Background()); err == nil || err.Error() != "no migrations table" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kriive/lil/dbtest"
+)
+
+// FindArchivedShorts returns the shorts moved to the archive by SweepShorts,
+// ordered by key.
+func (db *DB) FindArchivedShorts(ctx context.Context) ([]*dbtest.ArchivedShort, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			key,
+			url,
+			owner_id,
+			team_id,
+			activates_at,
+			expires_at,
+			status,
+			moderated_at,
+			created_at,
+			updated_at,
+			archived_at,
+			(
+				SELECT COUNT(*)
+				FROM clicks_archive
+				WHERE short_key = shorts_archive.key AND archived_at = shorts_archive.archived_at
+			)
+		FROM shorts_archive
+		ORDER BY key ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a := make([]*dbtest.ArchivedShort, 0)
+	for rows.Next() {
+		var short dbtest.ArchivedShort
+		var teamID sql.NullInt64
+		var activatesAt, expiresAt, moderatedAt time.Time
+		if err := rows.Scan(
+			&short.Key,
+			(*DBUrl)(&short.URL),
+			&short.OwnerID,
+			&teamID,
+			(*NullTime)(&activatesAt),
+			(*NullTime)(&expiresAt),
+			&short.Status,
+			(*NullTime)(&moderatedAt),
+			(*NullTime)(&short.CreatedAt),
+			(*NullTime)(&short.UpdatedAt),
+			(*NullTime)(&short.ArchivedAt),
+			&short.Clicks,
+		); err != nil {
+			return nil, err
+		}
+
+		if teamID.Valid {
+			v := int(teamID.Int64)
+			short.TeamID = &v
+		}
+		if !activatesAt.IsZero() {
+			short.ActivatesAt = &activatesAt
+		}
+		if !expiresAt.IsZero() {
+			short.ExpiresAt = &expiresAt
+		}
+		if !moderatedAt.IsZero() {
+			short.ModeratedAt = &moderatedAt
+		}
+		a = append(a, &short)
+	}
+	return a, rows.Err()
+}
@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked is returned when a database is locked by another process.
+var ErrLocked = errors.New("database is locked by another process")
+
+// ErrLockUnsupported is returned when advisory locks are not supported on
+// the platform.
+var ErrLockUnsupported = errors.New("database locks are not supported on this platform")
+
+// lockExt is the extension of the lock file kept next to a database.
+const lockExt = ".lock"
+
+// Lock takes an exclusive lock on the database at dsn. It fails with
+// ErrLocked while the database is open, in this process or another, so that
+// its files can be replaced safely. Closing the returned file releases it.
+// Fails with ErrLockUnsupported on platforms without advisory locks, as it
+// cannot tell whether the database is in use.
+func Lock(dsn string) (*os.File, error) {
+	return lockFile(dsn+lockExt, true)
+}
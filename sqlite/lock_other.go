@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package sqlite
+
+import "os"
+
+// lockFile returns ErrLockUnsupported as advisory locks are not supported on
+// this platform.
+func lockFile(path string, exclusive bool) (*os.File, error) {
+	return nil, ErrLockUnsupported
+}
@@ -0,0 +1,17 @@
+-- short lifetime
+ALTER TABLE shorts ADD COLUMN activates_at TEXT;
+ALTER TABLE shorts ADD COLUMN expires_at TEXT;
+
+CREATE INDEX shorts_expires_at_idx ON shorts (expires_at);
+
+-- expired shorts are moved here by the sweeper when archiving is enabled.
+CREATE TABLE shorts_archive (
+	key          TEXT NOT NULL,
+	url          TEXT NOT NULL,
+	owner_id     INTEGER NOT NULL,
+	activates_at TEXT,
+	expires_at   TEXT,
+	created_at   TEXT NOT NULL,
+	updated_at   TEXT NOT NULL,
+	archived_at  TEXT NOT NULL
+);
@@ -0,0 +1,24 @@
+-- click analytics
+CREATE TABLE clicks (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	short_key  TEXT NOT NULL REFERENCES shorts (key) ON DELETE CASCADE,
+	referrer   TEXT NOT NULL,
+	user_agent TEXT NOT NULL,
+	ip_hash    TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX clicks_short_key_idx ON clicks (short_key, created_at);
+
+-- clicks of the shorts moved to shorts_archive by the sweeper. Clicks of
+-- deleted shorts are removed along with them.
+CREATE TABLE clicks_archive (
+	short_key   TEXT NOT NULL,
+	referrer    TEXT NOT NULL,
+	user_agent  TEXT NOT NULL,
+	ip_hash     TEXT NOT NULL,
+	created_at  TEXT NOT NULL,
+	archived_at TEXT NOT NULL
+);
+
+CREATE INDEX clicks_archive_short_key_idx ON clicks_archive (short_key, archived_at);
@@ -0,0 +1,2 @@
+-- avatar picture reported by the authentication source
+ALTER TABLE auths ADD COLUMN picture TEXT NOT NULL DEFAULT '';
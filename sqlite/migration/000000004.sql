@@ -0,0 +1,25 @@
+-- teams sharing the ownership of shorts
+CREATE TABLE teams (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE team_members (
+	team_id    INTEGER NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+	user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	role       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+
+	PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX team_members_user_id_idx ON team_members (user_id);
+
+-- shorts of a deleted team go back to their owner.
+ALTER TABLE shorts ADD COLUMN team_id INTEGER REFERENCES teams (id) ON DELETE SET NULL;
+CREATE INDEX shorts_team_id_idx ON shorts (team_id);
+
+ALTER TABLE shorts_archive ADD COLUMN team_id INTEGER;
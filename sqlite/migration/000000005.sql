@@ -0,0 +1,27 @@
+-- moderation. The moderation date tells the short statuses set by
+-- administrators apart.
+ALTER TABLE users ADD COLUMN suspended_at TEXT;
+
+ALTER TABLE shorts ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE shorts ADD COLUMN moderated_at TEXT;
+CREATE INDEX shorts_status_idx ON shorts (status);
+
+ALTER TABLE shorts_archive ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE shorts_archive ADD COLUMN moderated_at TEXT;
+
+-- destination host of shorts, searched by administrators.
+ALTER TABLE shorts ADD COLUMN host TEXT NOT NULL DEFAULT '';
+UPDATE shorts SET host = lil_url_host(url);
+CREATE INDEX shorts_host_idx ON shorts (host);
+
+-- actions performed with administrator privileges. The actor is NULL for
+-- maintenance tools.
+CREATE TABLE audit_log (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_id   INTEGER REFERENCES users (id) ON DELETE SET NULL,
+	action     TEXT NOT NULL,
+	target     TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,14 @@
+-- named api tokens. Only a hash of the secret is stored and scopes are
+-- separated by spaces.
+CREATE TABLE tokens (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name        TEXT NOT NULL,
+	scopes      TEXT NOT NULL,
+	secret_hash TEXT NOT NULL UNIQUE,
+	expires_at  TEXT,
+	created_at  TEXT NOT NULL,
+	updated_at  TEXT NOT NULL
+);
+
+CREATE INDEX tokens_user_id_idx ON tokens (user_id);
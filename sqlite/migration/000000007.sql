@@ -0,0 +1,13 @@
+-- server-side browser sessions. The cookie only holds the secret, of which
+-- only a hash is stored.
+CREATE TABLE sessions (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	secret_hash  TEXT NOT NULL UNIQUE,
+	ip_address   TEXT NOT NULL,
+	user_agent   TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	last_seen_at TEXT NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
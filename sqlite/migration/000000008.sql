@@ -0,0 +1,5 @@
+-- CSRF tokens are tied to the server-side session. Existing sessions get a
+-- random token so their forms keep working once reloaded.
+ALTER TABLE sessions ADD COLUMN csrf_token TEXT NOT NULL DEFAULT '';
+
+UPDATE sessions SET csrf_token = lower(hex(randomblob(32)));
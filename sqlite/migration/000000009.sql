@@ -0,0 +1,3 @@
+-- Administrators used to be listed by email in the configuration. They are
+-- now granted with "lild user grant-admin".
+ALTER TABLE users ADD COLUMN admin INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Legacy API keys are stored hashed like token secrets. Existing keys keep
+-- working but cannot be read back anymore.
+ALTER TABLE users RENAME COLUMN api_key TO api_key_hash;
+
+UPDATE users SET api_key_hash = lil_sha256(api_key_hash);
@@ -0,0 +1,4 @@
+-- emails confirmed by login providers. Logins are only linked to users by
+-- confirmed emails. Existing emails may have been changed by their user, so
+-- they are confirmed on the next login with a provider reporting them.
+ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0;
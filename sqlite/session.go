@@ -0,0 +1,275 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kriive/lil"
+)
+
+// Ensure service implements interface.
+var _ lil.SessionService = (*SessionService)(nil)
+
+// SessionService represents a service for managing browser sessions.
+type SessionService struct {
+	db *DB
+}
+
+// NewSessionService returns a new instance of SessionService.
+func NewSessionService(db *DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// FindSessionBySecret retrieves the session matching a secret along with its
+// user. Returns EUNAUTHORIZED if the secret is unknown or the session has
+// expired.
+func (s *SessionService) FindSessionBySecret(ctx context.Context, secret string) (*lil.Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a, _, err := querySessions(ctx, tx, []string{"secret_hash = ?"}, []any{hashSecret(secret)}, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 || a[0].IsExpired(tx.now) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "Invalid or expired session.")
+	}
+
+	session := a[0]
+	if session.User, err = findUserByID(ctx, tx, session.UserID); err != nil {
+		return nil, fmt.Errorf("attach session user: %w", err)
+	}
+	return session, nil
+}
+
+// FindSessions retrieves the sessions of the current user, most recently used
+// first. Administrators can list the sessions of any user.
+func (s *SessionService) FindSessions(ctx context.Context, filter lil.SessionFilter) ([]*lil.Session, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	return findSessions(ctx, tx, filter)
+}
+
+// CreateSession creates a new session for session.UserID and sets its secret.
+func (s *SessionService) CreateSession(ctx context.Context, session *lil.Session) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createSession(ctx, tx, session); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TouchSession marks a session as used at the current time.
+func (s *SessionService) TouchSession(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET last_seen_at = ? WHERE id = ?`, (*NullTime)(&tx.now), id); err != nil {
+		return FormatError(err)
+	}
+	return tx.Commit()
+}
+
+// RevokeSession permanently deletes a session. Returns ENOTFOUND if the
+// session does not exist or does not belong to the current user.
+func (s *SessionService) RevokeSession(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeSession(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserSessions deletes every session of a user. Returns EUNAUTHORIZED
+// unless the current user is that user or an administrator.
+func (s *SessionService) RevokeUserSessions(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserSessions(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findSessions returns a list of active sessions matching a filter. The
+// filter is restricted to the current user unless the caller is an
+// administrator.
+func findSessions(ctx context.Context, tx *Tx, filter lil.SessionFilter) (_ []*lil.Session, n int, err error) {
+	// Build WHERE clause.
+	where, args := []string{"1 = 1"}, []any{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.UserID; v != nil {
+		where, args = append(where, "user_id = ?"), append(args, *v)
+	}
+
+	// Restrict to the sessions of the current user.
+	if !lil.AdminFromContext(ctx) {
+		where, args = append(where, "user_id = ?"), append(args, lil.UserIDFromContext(ctx))
+	}
+
+	// Skip expired sessions.
+	idleSince := tx.now.Add(-lil.SessionIdleTimeout)
+	where, args = append(where, "last_seen_at > ?"), append(args, (*NullTime)(&idleSince))
+
+	return querySessions(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+// querySessions executes a query against the sessions table with the given
+// WHERE conditions.
+func querySessions(ctx context.Context, tx *Tx, where []string, args []any, limit, offset int) (_ []*lil.Session, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+		    id,
+		    user_id,
+		    csrf_token,
+		    ip_address,
+		    user_agent,
+		    created_at,
+		    last_seen_at,
+		    COUNT(*) OVER()
+		FROM sessions
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY last_seen_at DESC, id DESC
+		`+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	sessions := make([]*lil.Session, 0)
+	for rows.Next() {
+		var session lil.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.CSRFToken,
+			&session.IPAddress,
+			&session.UserAgent,
+			(*NullTime)(&session.CreatedAt),
+			(*NullTime)(&session.LastSeenAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, n, nil
+}
+
+// createSession creates a new session. Sets the new database ID, secret & CSRF
+// token on session.
+func createSession(ctx context.Context, tx *Tx, session *lil.Session) (err error) {
+	// Set timestamps to the current time.
+	session.CreatedAt = tx.now
+	session.LastSeenAt = session.CreatedAt
+
+	// Perform basic field validation.
+	if err := session.Validate(); err != nil {
+		return err
+	}
+
+	// Generate the secret & CSRF token. Only the hash of the secret is stored.
+	if session.Secret, err = generateAPIKey(); err != nil {
+		return err
+	} else if session.CSRFToken, err = generateAPIKey(); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO sessions (
+			user_id,
+			secret_hash,
+			csrf_token,
+			ip_address,
+			user_agent,
+			created_at,
+			last_seen_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		session.UserID,
+		hashSecret(session.Secret),
+		session.CSRFToken,
+		session.IPAddress,
+		session.UserAgent,
+		(*NullTime)(&session.CreatedAt),
+		(*NullTime)(&session.LastSeenAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	session.ID = int(id)
+
+	return nil
+}
+
+// revokeSession permanently removes a session by ID. Returns ENOTFOUND if the
+// session does not belong to the current user.
+func revokeSession(ctx context.Context, tx *Tx, id int) error {
+	if a, _, err := findSessions(ctx, tx, lil.SessionFilter{ID: &id}); err != nil {
+		return err
+	} else if len(a) == 0 {
+		return lil.Errorf(lil.ENOTFOUND, "Session not found.")
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditSessionRevoke, strconv.Itoa(id)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// revokeUserSessions removes every session of a user. Returns EUNAUTHORIZED
+// if the current user is neither that user nor an administrator.
+func revokeUserSessions(ctx context.Context, tx *Tx, userID int) error {
+	if userID != lil.UserIDFromContext(ctx) && !lil.AdminFromContext(ctx) {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to revoke the sessions of this user.")
+	} else if _, err := findUserByID(ctx, tx, userID); err != nil {
+		return err
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditUserSessionsRevoke, strconv.Itoa(userID)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
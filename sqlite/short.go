@@ -2,10 +2,13 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/kriive/lil"
+	"github.com/kriive/lil/dbmetrics"
 )
 
 var _ lil.ShortService = (*ShortService)(nil)
@@ -35,6 +38,8 @@ func (s *ShortService) FindShortByKey(ctx context.Context, key string) (*lil.Sho
 	return short, err
 }
 
+// SearchShort retrieves a Short by key regardless of its owner. The owner &
+// team are not attached as redirects do not need them.
 func (s *ShortService) SearchShort(ctx context.Context, key string) (*lil.Short, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -45,11 +50,10 @@ func (s *ShortService) SearchShort(ctx context.Context, key string) (*lil.Short,
 	short, err := findShortByKey(ctx, tx, key, true)
 	if err != nil {
 		return nil, err
-	} else if err := attachShortAssociations(ctx, tx, short); err != nil {
+	} else if err := short.CheckActive(tx.now); err != nil {
 		return nil, err
 	}
-
-	return short, err
+	return short, nil
 }
 
 func findShortByKey(ctx context.Context, tx *Tx, key string, all bool) (*lil.Short, error) {
@@ -72,13 +76,36 @@ func findShorts(ctx context.Context, tx *Tx, filter lil.ShortFilter, all bool) (
 	}
 
 	if v := filter.URL; v != nil {
-		where, args = append(where, "url = ?"), append(args, *v)
+		where, args = append(where, "url = ?"), append(args, (*DBUrl)(v))
+	}
+	if v := filter.OwnerID; v != nil {
+		where, args = append(where, "owner_id = ?"), append(args, *v)
+	}
+	if v := filter.TeamID; v != nil {
+		where, args = append(where, "team_id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+	if v := filter.After; v != nil {
+		where, args = append(where, "key > ?"), append(args, *v)
+	}
+	if v := filter.Host; v != nil {
+		where, args = append(where, "host = ?"), append(args, strings.ToLower(*v))
 	}
 
-	// Limit shorts to those the owner has created.
-	if !all {
+	// Limit shorts to those the owner has created or shares with one of
+	// their teams, unless the caller is an administrator.
+	if !all && !lil.AdminFromContext(ctx) {
 		userID := lil.UserIDFromContext(ctx)
-		where, args = append(where, "owner_id = ?"), append(args, userID)
+		where = append(where, "(owner_id = ? OR team_id IN (SELECT team_id FROM team_members WHERE user_id = ?))")
+		args = append(args, userID, userID)
+	}
+
+	// Paging after a key requires shorts to be ordered by key.
+	order := "created_at ASC, key ASC"
+	if filter.After != nil {
+		order = "key ASC"
 	}
 
 	rows, err := tx.QueryContext(ctx, `
@@ -86,11 +113,17 @@ func findShorts(ctx context.Context, tx *Tx, filter lil.ShortFilter, all bool) (
 				key,
 				url,
 				owner_id,
+				team_id,
+				activates_at,
+				expires_at,
+				status,
+				moderated_at,
 				created_at,
 				updated_at,
 				COUNT(*) OVER()
 			FROM shorts
 			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY `+order+`
 			`+FormatLimitOffset(filter.Limit, filter.Offset),
 		args...,
 	)
@@ -102,16 +135,38 @@ func findShorts(ctx context.Context, tx *Tx, filter lil.ShortFilter, all bool) (
 	shorts := make([]*lil.Short, 0)
 	for rows.Next() {
 		var short lil.Short
+		var teamID sql.NullInt64
+		var activatesAt, expiresAt, moderatedAt time.Time
 		if err := rows.Scan(
 			&short.Key,
 			(*DBUrl)(&short.URL),
 			&short.OwnerID,
+			&teamID,
+			(*NullTime)(&activatesAt),
+			(*NullTime)(&expiresAt),
+			&short.Status,
+			(*NullTime)(&moderatedAt),
 			(*NullTime)(&short.CreatedAt),
 			(*NullTime)(&short.UpdatedAt),
 			&n,
 		); err != nil {
 			return nil, 0, err
 		}
+
+		if teamID.Valid {
+			v := int(teamID.Int64)
+			short.TeamID = &v
+		}
+		if !activatesAt.IsZero() {
+			short.ActivatesAt = &activatesAt
+		}
+		if !expiresAt.IsZero() {
+			short.ExpiresAt = &expiresAt
+		}
+		if !moderatedAt.IsZero() {
+			short.ModeratedAt = &moderatedAt
+		}
+
 		shorts = append(shorts, &short)
 	}
 	if err := rows.Err(); err != nil {
@@ -131,7 +186,61 @@ func (s *ShortService) FindShorts(ctx context.Context, filter lil.ShortFilter) (
 	}
 	defer tx.Rollback()
 
-	return findShorts(ctx, tx, filter, false)
+	shorts, n, err := findShorts(ctx, tx, filter, false)
+	if err != nil {
+		return shorts, n, err
+	}
+
+	// Attach owners & teams so the shared shorts can be told apart.
+	for _, short := range shorts {
+		if err := attachShortAssociations(ctx, tx, short); err != nil {
+			return shorts, n, err
+		}
+	}
+	return shorts, n, nil
+}
+
+// shortPageSize is the number of shorts read at a time by ForEachShort.
+const shortPageSize = 500
+
+// Calls fn for every Short matching filter, ordered by key. Shorts are
+// read from a single transaction so that none is skipped or repeated while
+// others are changed. Offset & limit are ignored. Stops at the first error
+// returned by fn and returns it.
+func (s *ShortService) ForEachShort(ctx context.Context, filter lil.ShortFilter, fn func(*lil.Short) error) error {
+	// SQLite transactions read from a single snapshot of the database.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Read page after page, starting after the last key of the previous one.
+	var after string
+	if filter.After != nil {
+		after = *filter.After
+	}
+	filter.After, filter.Offset, filter.Limit = &after, 0, shortPageSize
+
+	for {
+		shorts, _, err := findShorts(ctx, tx, filter, false)
+		if err != nil {
+			return err
+		}
+
+		for _, short := range shorts {
+			if err := attachShortAssociations(ctx, tx, short); err != nil {
+				return err
+			} else if err := fn(short); err != nil {
+				return err
+			}
+		}
+
+		if len(shorts) < filter.Limit {
+			return nil
+		}
+		after = shorts[len(shorts)-1].Key
+	}
 }
 
 // Creates a new Short.
@@ -146,8 +255,12 @@ func (s *ShortService) CreateShort(ctx context.Context, short *lil.Short) error
 		return err
 	} else if attachShortAssociations(ctx, tx, short); err != nil {
 		return err
+	} else if err := tx.Commit(); err != nil {
+		return err
 	}
-	return tx.Commit()
+
+	dbmetrics.ShortCreateCount.Inc()
+	return nil
 }
 
 func createShort(ctx context.Context, tx *Tx, short *lil.Short) error {
@@ -157,9 +270,25 @@ func createShort(ctx context.Context, tx *Tx, short *lil.Short) error {
 	}
 	short.OwnerID = ownerID
 
+	// Shorts can only be added to teams the user can edit.
+	if err := checkShortTeam(ctx, tx, short.TeamID); err != nil {
+		return err
+	}
+
+	// New shorts are active unless the user asks otherwise. Moderation only
+	// happens through UpdateShort().
+	if short.Status == "" {
+		short.Status = lil.ShortStatusActive
+	}
+	short.ModeratedAt = nil
+
 	short.CreatedAt = tx.now
 	short.UpdatedAt = short.CreatedAt
 
+	// Lifetime timestamps are stored with second precision.
+	short.ActivatesAt = truncateTime(short.ActivatesAt)
+	short.ExpiresAt = truncateTime(short.ExpiresAt)
+
 	if err := short.Validate(); err != nil {
 		return err
 	}
@@ -169,14 +298,24 @@ func createShort(ctx context.Context, tx *Tx, short *lil.Short) error {
 				url,
 				key,
 				owner_id,
+				team_id,
+				activates_at,
+				expires_at,
+				status,
+				host,
 				created_at,
 				updated_at
 			)
-			VALUES (?, ?, ?, ?, ?)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		(*DBUrl)(&short.URL),
 		short.Key,
 		short.OwnerID,
+		short.TeamID,
+		(*NullTime)(short.ActivatesAt),
+		(*NullTime)(short.ExpiresAt),
+		short.Status,
+		urlHost(&short.URL),
 		(*NullTime)(&short.CreatedAt),
 		(*NullTime)(&short.UpdatedAt),
 	)
@@ -187,6 +326,110 @@ func createShort(ctx context.Context, tx *Tx, short *lil.Short) error {
 	return nil
 }
 
+// Updates an existing Short. Returns ENOTFOUND if the Short does not exist.
+// Returns EUNAUTHORIZED if the user cannot edit the Short, or if it has been
+// moderated by an administrator.
+func (s *ShortService) UpdateShort(ctx context.Context, key string, upd lil.ShortUpdate) (*lil.Short, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	short, err := updateShort(ctx, tx, key, upd)
+	if err != nil {
+		return nil, err
+	} else if err := attachShortAssociations(ctx, tx, short); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return short, nil
+}
+
+func updateShort(ctx context.Context, tx *Tx, key string, upd lil.ShortUpdate) (*lil.Short, error) {
+	// Fetch current object state.
+	short, err := findShortByKey(ctx, tx, key, false)
+	if err != nil {
+		return nil, err
+	} else if err := attachShortTeam(ctx, tx, short); err != nil {
+		return nil, err
+	} else if !lil.CanEditShort(ctx, short) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "Only the owner can update a short.")
+	} else if short.ModeratedAt != nil && !lil.AdminFromContext(ctx) {
+		return nil, lil.ErrShortModerated
+	}
+
+	// Update fields.
+	if v := upd.URL; v != nil {
+		short.URL = *v
+	}
+	if v := upd.ActivatesAt; v != nil {
+		short.ActivatesAt = truncateTime(v)
+	}
+	if v := upd.ExpiresAt; v != nil {
+		short.ExpiresAt = truncateTime(v)
+	}
+	if v := upd.TeamID; v != nil {
+		if *v == 0 {
+			short.TeamID, short.Team = nil, nil
+		} else if err := checkShortTeam(ctx, tx, v); err != nil {
+			return nil, err
+		} else {
+			short.TeamID = v
+		}
+	}
+
+	// Statuses set by administrators moderate the short, which its owner
+	// can no longer change until an administrator enables it again.
+	action := lil.AuditShortUpdate
+	if v := upd.Status; v != nil && *v != short.Status {
+		if lil.AdminFromContext(ctx) && *v == lil.ShortStatusActive {
+			short.ModeratedAt, action = nil, lil.AuditShortEnable
+		} else if lil.AdminFromContext(ctx) {
+			moderatedAt := tx.now
+			short.ModeratedAt, action = &moderatedAt, shortStatusAuditAction(*v)
+		}
+		short.Status = *v
+	}
+
+	// Set last updated date to current time.
+	short.UpdatedAt = tx.now
+
+	if err := short.Validate(); err != nil {
+		return nil, err
+	} else if err := createAdminAuditEntry(ctx, tx, action, short.Key); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+			UPDATE shorts
+			SET url = ?,
+			    team_id = ?,
+			    activates_at = ?,
+			    expires_at = ?,
+			    status = ?,
+			    moderated_at = ?,
+			    host = ?,
+			    updated_at = ?
+			WHERE key = ?
+	`,
+		(*DBUrl)(&short.URL),
+		short.TeamID,
+		(*NullTime)(short.ActivatesAt),
+		(*NullTime)(short.ExpiresAt),
+		short.Status,
+		(*NullTime)(short.ModeratedAt),
+		urlHost(&short.URL),
+		(*NullTime)(&short.UpdatedAt),
+		key,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return short, nil
+}
+
 // Permanently removes a Short. Returns a ENOTFOUND if the key
 // does not belong to any Short. Returns a ENOTAUTHORIZED if the
 // short does not belong to the current user.
@@ -207,8 +450,14 @@ func (s *ShortService) DeleteShort(ctx context.Context, key string) error {
 func deleteShort(ctx context.Context, tx *Tx, key string) error {
 	if short, err := findShortByKey(ctx, tx, key, false); err != nil {
 		return err
+	} else if err := attachShortTeam(ctx, tx, short); err != nil {
+		return err
 	} else if !lil.CanEditShort(ctx, short) {
 		return lil.Errorf(lil.EUNAUTHORIZED, "Only the owner can delete a short.")
+	} else if short.ModeratedAt != nil && !lil.AdminFromContext(ctx) {
+		return lil.ErrShortModerated
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditShortDelete, key); err != nil {
+		return err
 	}
 
 	if _, err := tx.ExecContext(ctx, `DELETE FROM shorts WHERE key = ?`, key); err != nil {
@@ -218,10 +467,138 @@ func deleteShort(ctx context.Context, tx *Tx, key string) error {
 	return nil
 }
 
-// attachShortAssociations is a helper function to look up and attach the owner user to the short.
+// attachShortAssociations is a helper function to look up and attach the owner user
+// and the team to the short.
 func attachShortAssociations(ctx context.Context, tx *Tx, short *lil.Short) (err error) {
 	if short.Owner, err = findUserByID(ctx, tx, short.OwnerID); err != nil {
 		return fmt.Errorf("attach short user: %w", err)
 	}
+	return attachShortTeam(ctx, tx, short)
+}
+
+// attachShortTeam is a helper function to look up and attach the team of the
+// short, if any, along with its members.
+func attachShortTeam(ctx context.Context, tx *Tx, short *lil.Short) (err error) {
+	if short.TeamID == nil {
+		return nil
+	} else if short.Team, err = findTeamByID(ctx, tx, *short.TeamID); err != nil {
+		return fmt.Errorf("attach short team: %w", err)
+	}
+	return nil
+}
+
+// shortStatusAuditAction returns the audit action recorded when an
+// administrator moves a short to status.
+func shortStatusAuditAction(status string) string {
+	switch status {
+	case lil.ShortStatusDisabled:
+		return lil.AuditShortDisable
+	case lil.ShortStatusFlagged:
+		return lil.AuditShortFlag
+	}
+	return lil.AuditShortEnable
+}
+
+// checkShortTeam returns EUNAUTHORIZED unless the current user can add shorts
+// to the team. A nil team ID is always allowed.
+func checkShortTeam(ctx context.Context, tx *Tx, teamID *int) error {
+	if teamID == nil {
+		return nil
+	}
+
+	team, err := findTeamByID(ctx, tx, *teamID)
+	if lil.ErrorCode(err) == lil.ENOTFOUND {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to add shorts to this team.")
+	} else if err != nil {
+		return err
+	} else if !lil.CanEditTeamShorts(lil.UserIDFromContext(ctx), team) && !lil.AdminFromContext(ctx) {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to add shorts to this team.")
+	}
 	return nil
 }
+
+// truncateTime returns a copy of t in UTC with second precision, as stored in
+// the database. Returns nil if t is nil.
+func truncateTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.UTC().Truncate(time.Second)
+	return &v
+}
+
+// SweepShorts removes shorts which expired before the grace period. If
+// archive is true, the shorts are moved to the archive table instead of
+// being discarded. Returns the number of removed shorts.
+func (db *DB) SweepShorts(ctx context.Context, grace time.Duration, archive bool) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := sweepShorts(ctx, tx, tx.now.Add(-grace), archive)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func sweepShorts(ctx context.Context, tx *Tx, before time.Time, archive bool) (int, error) {
+	if archive {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO shorts_archive (
+				key,
+				url,
+				owner_id,
+				team_id,
+				activates_at,
+				expires_at,
+				status,
+				moderated_at,
+				created_at,
+				updated_at,
+				archived_at
+			)
+			SELECT key, url, owner_id, team_id, activates_at, expires_at, status, moderated_at, created_at, updated_at, ?
+			FROM shorts
+			WHERE expires_at < ?
+		`,
+			(*NullTime)(&tx.now),
+			(*NullTime)(&before),
+		); err != nil {
+			return 0, FormatError(err)
+		}
+
+		// Keep the clicks, which are otherwise deleted along with the shorts.
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO clicks_archive (
+				short_key,
+				referrer,
+				user_agent,
+				ip_hash,
+				created_at,
+				archived_at
+			)
+			SELECT short_key, referrer, user_agent, ip_hash, created_at, ?
+			FROM clicks
+			WHERE short_key IN (SELECT key FROM shorts WHERE expires_at < ?)
+		`,
+			(*NullTime)(&tx.now),
+			(*NullTime)(&before),
+		); err != nil {
+			return 0, FormatError(err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM shorts WHERE expires_at < ?`, (*NullTime)(&before))
+	if err != nil {
+		return 0, FormatError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -7,31 +7,82 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/kriive/lil"
-	_ "modernc.org/sqlite"
+	"github.com/kriive/lil/dbmetrics"
+	sqlitedriver "modernc.org/sqlite"
 )
 
 //go:embed migration/*.sql
 var migrationFS embed.FS
 
+func init() {
+	// Migrations hash existing secrets with the same function as hashSecret.
+	sqlitedriver.MustRegisterDeterministicScalarFunction("lil_sha256", 1, func(ctx *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return hashSecret(v), nil
+		case []byte:
+			return hashSecret(string(v)), nil
+		default:
+			return nil, fmt.Errorf("lil_sha256: unexpected argument type %T", v)
+		}
+	})
+
+	// Migrations fill the host of existing shorts with the same function as
+	// urlHost.
+	sqlitedriver.MustRegisterDeterministicScalarFunction("lil_url_host", 1, func(ctx *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		v, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("lil_url_host: unexpected argument type %T", args[0])
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return "", nil
+		}
+		return urlHost(u), nil
+	})
+}
+
 // DB represents the database connection.
 type DB struct {
 	db     *sql.DB
+	lock   *os.File        // shared lock held while open
 	ctx    context.Context // background context
 	cancel func()          // cancel background context
 
 	// Datasource name.
 	DSN string
 
+	// If set, pending migrations are not executed on Open(). This allows
+	// the migration state of a database to be inspected without changing it.
+	NoMigrate bool
+
 	// Returns the current time. Defaults to time.Now().
 	// Can be mocked for tests.
 	Now func() time.Time
+
+	// Expired shorts are swept every SweepInterval once they have been
+	// expired for longer than SweepGracePeriod. If SweepArchive is set, they
+	// are archived along with their clicks instead of deleted. A zero interval
+	// disables the sweeper.
+	SweepInterval    time.Duration
+	SweepGracePeriod time.Duration
+	SweepArchive     bool
+
+	// A backup is written to BackupDir every BackupInterval, keeping the
+	// BackupRetain most recent ones. A zero interval disables backups and a
+	// zero retain keeps every backup.
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupRetain   int
 }
 
 // NewDB returns a new instance of DB associated with the given datasource name.
@@ -49,12 +100,18 @@ func (db *DB) Open() (err error) {
 	// Ensure a DSN is set before attempting to open the database.
 	if db.DSN == "" {
 		return fmt.Errorf("dsn required")
+	} else if db.BackupInterval > 0 && db.BackupDir == "" {
+		return fmt.Errorf("backup dir required")
 	}
 
-	// Make the parent directory unless using an in-memory db.
+	// Make the parent directory unless using an in-memory db. A shared lock
+	// is held while the database is open so that it cannot be restored from
+	// under us. Without lock support, restoring fails instead.
 	if db.DSN != ":memory:" {
 		if err := os.MkdirAll(filepath.Dir(db.DSN), 0700); err != nil {
 			return err
+		} else if db.lock, err = lockFile(db.DSN+lockExt, false); err != nil && err != ErrLockUnsupported {
+			return fmt.Errorf("lock: %w", err)
 		}
 	}
 
@@ -77,13 +134,25 @@ func (db *DB) Open() (err error) {
 		return fmt.Errorf("foreign keys pragma: %w", err)
 	}
 
-	if err := db.migrate(); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+	if !db.NoMigrate {
+		if err := db.migrate(); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
 	}
 
 	// Monitor stats in background goroutine.
 	go db.monitor()
 
+	// Remove expired shorts in background goroutine, if enabled.
+	if db.SweepInterval > 0 {
+		go db.sweep()
+	}
+
+	// Back up the database in background goroutine, if enabled.
+	if db.BackupInterval > 0 {
+		go db.backup()
+	}
+
 	return nil
 }
 
@@ -118,6 +187,54 @@ func (db *DB) migrate() error {
 	return nil
 }
 
+// Migration represents an embedded migration file & whether it has been
+// executed against the database.
+type Migration struct {
+	Name    string
+	Applied bool
+}
+
+// Migrations returns every embedded migration in execution order.
+func (db *DB) Migrations(ctx context.Context) ([]Migration, error) {
+	names, err := fs.Glob(migrationFS, "migration/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	// The migrations table does not exist until the first migration runs.
+	var n int
+	if err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'`).Scan(&n); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]bool)
+	if n != 0 {
+		rows, err := db.db.QueryContext(ctx, `SELECT name FROM migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			applied[name] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, len(names))
+	for i, name := range names {
+		migrations[i] = Migration{Name: name, Applied: applied[name]}
+	}
+	return migrations, nil
+}
+
 // migrate runs a single migration file within a transaction. On success, the
 // migration file name is saved to the "migrations" table to prevent re-running.
 func (db *DB) migrateFile(name string) error {
@@ -155,11 +272,17 @@ func (db *DB) Close() error {
 	// Cancel background context.
 	db.cancel()
 
-	// Close database.
+	// Close database, then release the lock.
+	var err error
 	if db.db != nil {
-		return db.db.Close()
+		err = db.db.Close()
 	}
-	return nil
+	if db.lock != nil {
+		if e := db.lock.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 // BeginTx starts a transaction and returns a wrapper Tx type. This type
@@ -190,6 +313,28 @@ func (db *DB) monitor() {
 			return
 		case <-ticker.C:
 		}
+
+		dbmetrics.UpdateStats(db.db.Stats())
+	}
+}
+
+// sweep runs in a goroutine and periodically removes expired shorts.
+func (db *DB) sweep() {
+	ticker := time.NewTicker(db.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if n, err := db.SweepShorts(db.ctx, db.SweepGracePeriod, db.SweepArchive); err != nil {
+			log.Printf("sqlite: cannot sweep expired shorts: %s", err)
+		} else if n > 0 {
+			log.Printf("sqlite: swept %d expired shorts", n)
+		}
 	}
 }
 
@@ -243,6 +388,12 @@ func (u DBUrl) Value() (driver.Value, error) {
 	return (*url.URL)(&u).String(), nil
 }
 
+// urlHost returns the lowercase host of u, without the port. Stored
+// alongside shorts so that they can be searched by host.
+func urlHost(u *url.URL) string {
+	return strings.ToLower(u.Hostname())
+}
+
 // FormatLimitOffset returns a SQL string for a given limit & offset.
 // Clauses are only added if limit and/or offset are greater than zero.
 func FormatLimitOffset(limit, offset int) string {
@@ -1,11 +1,14 @@
 package sqlite_test
 
 import (
+	"context"
 	"flag"
+	"github.com/kriive/lil/dbtest"
 	"github.com/kriive/lil/sqlite"
-	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 var dump = flag.Bool("dump", false, "save work data")
@@ -16,6 +19,41 @@ func TestDB(t *testing.T) {
 	MustCloseDB(t, db)
 }
 
+// Ensure the services pass the tests shared by every database.
+func TestDB_Services(t *testing.T) {
+	dbtest.Run(t, MustOpenServices)
+}
+
+// Ensure the migration state of a database can be inspected.
+func TestDB_Migrations(t *testing.T) {
+	db := sqlite.NewDB(":memory:")
+	db.NoMigrate = true
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer MustCloseDB(t, db)
+
+	if a, err := db.Migrations(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(a) == 0 {
+		t.Fatal("expected migrations")
+	} else if a[0].Applied {
+		t.Fatalf("expected %s to be pending", a[0].Name)
+	}
+
+	db2 := MustOpenDB(t)
+	defer MustCloseDB(t, db2)
+	if a, err := db2.Migrations(context.Background()); err != nil {
+		t.Fatal(err)
+	} else {
+		for _, m := range a {
+			if !m.Applied {
+				t.Fatalf("expected %s to be applied", m.Name)
+			}
+		}
+	}
+}
+
 // MustOpenDB returns a new, open DB. Fatal on error.
 func MustOpenDB(tb testing.TB) *sqlite.DB {
 	tb.Helper()
@@ -24,7 +62,7 @@ func MustOpenDB(tb testing.TB) *sqlite.DB {
 	// If the -dump flag is set, generate a temp file for the database.
 	dsn := ":memory:"
 	if *dump {
-		dir, err := ioutil.TempDir("", "")
+		dir, err := os.MkdirTemp("", "")
 		if err != nil {
 			tb.Fatal(err)
 		}
@@ -45,4 +83,26 @@ func MustCloseDB(tb testing.TB, db *sqlite.DB) {
 	if err := db.Close(); err != nil {
 		tb.Fatal(err)
 	}
-}
\ No newline at end of file
+}
+// MustOpenServices returns the services of a new, open DB which is closed at
+// the end of the test.
+func MustOpenServices(tb testing.TB) *dbtest.DB {
+	tb.Helper()
+
+	db := MustOpenDB(tb)
+	tb.Cleanup(func() { MustCloseDB(tb, db) })
+
+	return &dbtest.DB{
+		AuditService:       sqlite.NewAuditService(db),
+		AuthService:        sqlite.NewAuthService(db),
+		ClickService:       sqlite.NewClickService(db),
+		SessionService:     sqlite.NewSessionService(db),
+		ShortService:       sqlite.NewShortService(db),
+		TeamService:        sqlite.NewTeamService(db),
+		TokenService:       sqlite.NewTokenService(db),
+		UserService:        sqlite.NewUserService(db),
+		SetNow:             func(now func() time.Time) { db.Now = now },
+		SweepShorts:        db.SweepShorts,
+		FindArchivedShorts: db.FindArchivedShorts,
+	}
+}
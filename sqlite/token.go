@@ -0,0 +1,285 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kriive/lil"
+)
+
+// Ensure service implements interface.
+var _ lil.TokenService = (*TokenService)(nil)
+
+// TokenService represents a service for managing API tokens.
+type TokenService struct {
+	db *DB
+}
+
+// NewTokenService returns a new instance of TokenService.
+func NewTokenService(db *DB) *TokenService {
+	return &TokenService{db: db}
+}
+
+// FindTokenByID retrieves a token by ID. Returns ENOTFOUND if the token does
+// not exist or does not belong to the current user.
+func (s *TokenService) FindTokenByID(ctx context.Context, id int) (*lil.Token, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findTokenByID(ctx, tx, id)
+}
+
+// FindTokens retrieves the tokens of the current user. Administrators can
+// list the tokens of any user.
+func (s *TokenService) FindTokens(ctx context.Context, filter lil.TokenFilter) ([]*lil.Token, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	return findTokens(ctx, tx, filter)
+}
+
+// FindTokenBySecret retrieves the token matching a secret along with its user.
+// Returns EUNAUTHORIZED if the secret is unknown or the token has expired.
+func (s *TokenService) FindTokenBySecret(ctx context.Context, secret string) (*lil.Token, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token, err := findTokenBySecret(ctx, tx, secret)
+	if err != nil {
+		return nil, err
+	} else if token.IsExpired(tx.now) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "API token has expired.")
+	} else if token.User, err = findUserByID(ctx, tx, token.UserID); err != nil {
+		return nil, fmt.Errorf("attach token user: %w", err)
+	}
+	return token, nil
+}
+
+// CreateToken creates a new token for the current user and sets its secret.
+func (s *TokenService) CreateToken(ctx context.Context, token *lil.Token) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createToken(ctx, tx, token); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeToken permanently deletes a token. Returns ENOTFOUND if the token does
+// not exist or does not belong to the current user.
+func (s *TokenService) RevokeToken(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeToken(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findTokenByID is a helper function to fetch a token of the current user by
+// ID. Returns ENOTFOUND if token does not exist.
+func findTokenByID(ctx context.Context, tx *Tx, id int) (*lil.Token, error) {
+	a, _, err := findTokens(ctx, tx, lil.TokenFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, lil.Errorf(lil.ENOTFOUND, "Token not found.")
+	}
+	return a[0], nil
+}
+
+// findTokenBySecret is a helper function to fetch a token by its secret,
+// regardless of the current user. Returns EUNAUTHORIZED if no token matches.
+func findTokenBySecret(ctx context.Context, tx *Tx, secret string) (*lil.Token, error) {
+	a, _, err := queryTokens(ctx, tx, []string{"secret_hash = ?"}, []any{hashSecret(secret)}, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "Invalid API token.")
+	}
+	return a[0], nil
+}
+
+// findTokens returns a list of tokens matching a filter. The filter is
+// restricted to the current user unless the caller is an administrator.
+func findTokens(ctx context.Context, tx *Tx, filter lil.TokenFilter) (_ []*lil.Token, n int, err error) {
+	// Build WHERE clause.
+	where, args := []string{"1 = 1"}, []any{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.UserID; v != nil {
+		where, args = append(where, "user_id = ?"), append(args, *v)
+	}
+
+	// Restrict to the tokens of the current user.
+	if !lil.AdminFromContext(ctx) {
+		where, args = append(where, "user_id = ?"), append(args, lil.UserIDFromContext(ctx))
+	}
+
+	return queryTokens(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+// queryTokens executes a query against the tokens table with the given
+// WHERE conditions.
+func queryTokens(ctx context.Context, tx *Tx, where []string, args []any, limit, offset int) (_ []*lil.Token, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+		    id,
+		    user_id,
+		    name,
+		    scopes,
+		    expires_at,
+		    created_at,
+		    updated_at,
+		    COUNT(*) OVER()
+		FROM tokens
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id ASC
+		`+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	tokens := make([]*lil.Token, 0)
+	for rows.Next() {
+		var token lil.Token
+		var scopes string
+		var expiresAt time.Time
+		if err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			&scopes,
+			(*NullTime)(&expiresAt),
+			(*NullTime)(&token.CreatedAt),
+			(*NullTime)(&token.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		token.Scopes = strings.Fields(scopes)
+		if !expiresAt.IsZero() {
+			token.ExpiresAt = &expiresAt
+		}
+
+		tokens = append(tokens, &token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return tokens, n, nil
+}
+
+// createToken creates a new token for the current user. Sets the new database
+// ID & secret on token.
+func createToken(ctx context.Context, tx *Tx, token *lil.Token) (err error) {
+	userID := lil.UserIDFromContext(ctx)
+	if userID == 0 {
+		return lil.Errorf(lil.EUNAUTHORIZED, "You must be logged in to create a token.")
+	}
+	token.UserID = userID
+
+	// A token cannot be used to grant more than it has been granted itself.
+	for _, scope := range token.Scopes {
+		if !lil.CanUseScope(ctx, scope) {
+			return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to grant the %q scope.", scope)
+		}
+	}
+
+	// Set timestamps to the current time.
+	token.CreatedAt = tx.now
+	token.UpdatedAt = token.CreatedAt
+
+	// Perform basic field validation.
+	if err := token.Validate(); err != nil {
+		return err
+	} else if token.ExpiresAt != nil && !token.ExpiresAt.After(tx.now) {
+		return lil.Errorf(lil.EINVALID, "Expiration must be in the future.")
+	}
+
+	// Generate the secret. Only its hash is stored.
+	if token.Secret, err = generateAPIKey(); err != nil {
+		return err
+	}
+	token.Secret = lil.TokenPrefix + token.Secret
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO tokens (
+			user_id,
+			name,
+			scopes,
+			secret_hash,
+			expires_at,
+			created_at,
+			updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		token.UserID,
+		token.Name,
+		strings.Join(token.Scopes, " "),
+		hashSecret(token.Secret),
+		(*NullTime)(token.ExpiresAt),
+		(*NullTime)(&token.CreatedAt),
+		(*NullTime)(&token.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = int(id)
+
+	return nil
+}
+
+// revokeToken permanently removes a token by ID. Returns ENOTFOUND if the
+// token does not belong to the current user.
+func revokeToken(ctx context.Context, tx *Tx, id int) error {
+	if _, err := findTokenByID(ctx, tx, id); err != nil {
+		return err
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditTokenRevoke, strconv.Itoa(id)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tokens WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// hashSecret returns the hash of a token or session secret as stored in the
+// database. Secrets are random so a plain SHA-256 is enough.
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
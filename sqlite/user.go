@@ -7,7 +7,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kriive/lil"
 )
@@ -94,6 +96,28 @@ func (s *UserService) UpdateUser(ctx context.Context, id int, upd lil.UserUpdate
 	return user, nil
 }
 
+// RotateAPIKey replaces the API key of a user with a new random key. The
+// previous key stops working immediately. Returns EUNAUTHORIZED if current
+// user is not the user being updated or if the request is authenticated by an
+// API token lacking a scope. Returns ENOTFOUND if user does not exist.
+func (s *UserService) RotateAPIKey(ctx context.Context, id int) (*lil.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := rotateAPIKey(ctx, tx, id)
+	if err != nil {
+		return user, err
+	} else if err := attachUserAuths(ctx, tx, user); err != nil {
+		return user, err
+	} else if err := tx.Commit(); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
 // DeleteUser permanently deletes a user and all owned dials.
 // Returns EUNAUTHORIZED if current user is not the user being deleted.
 // Returns ENOTFOUND if user does not exist.
@@ -134,6 +158,23 @@ func findUserByEmail(ctx context.Context, tx *Tx, email string) (*lil.User, erro
 	return a[0], nil
 }
 
+// verifyUserEmail marks the email of a user as confirmed by a login provider.
+func verifyUserEmail(ctx context.Context, tx *Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET email_verified = ? WHERE id = ?`, true, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// releaseUserEmail removes the email of a user, unless it is verified, so
+// that a login provider which confirmed it can assign it to another user.
+func releaseUserEmail(ctx context.Context, tx *Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET email = NULL WHERE id = ? AND NOT email_verified`, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
 // findUsers returns a list of users matching a filter. Also returns a count of
 // total matching users which may differ if filter.Limit is set.
 func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.User, n int, err error) {
@@ -146,7 +187,7 @@ func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.Use
 		where, args = append(where, "email = ?"), append(args, *v)
 	}
 	if v := filter.APIKey; v != nil {
-		where, args = append(where, "api_key = ?"), append(args, *v)
+		where, args = append(where, "api_key_hash = ?"), append(args, hashSecret(*v))
 	}
 
 	// Execute query to fetch user rows.
@@ -155,7 +196,9 @@ func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.Use
 		    id,
 		    name,
 		    email,
-		    api_key,
+		    email_verified,
+		    admin,
+		    suspended_at,
 		    created_at,
 		    updated_at,
 		    COUNT(*) OVER()
@@ -174,12 +217,15 @@ func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.Use
 	users := make([]*lil.User, 0)
 	for rows.Next() {
 		var email sql.NullString
+		var suspendedAt time.Time
 		var user lil.User
 		if err := rows.Scan(
 			&user.ID,
 			&user.Name,
 			&email,
-			&user.APIKey,
+			&user.EmailVerified,
+			&user.Admin,
+			(*NullTime)(&suspendedAt),
 			(*NullTime)(&user.CreatedAt),
 			(*NullTime)(&user.UpdatedAt),
 			&n,
@@ -190,6 +236,9 @@ func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.Use
 		if email.Valid {
 			user.Email = email.String
 		}
+		if !suspendedAt.IsZero() {
+			user.SuspendedAt = &suspendedAt
+		}
 
 		users = append(users, &user)
 	}
@@ -202,7 +251,7 @@ func findUsers(ctx context.Context, tx *Tx, filter lil.UserFilter) (_ []*lil.Use
 
 // createUser creates a new user. Sets the new database ID to user.ID and sets
 // the timestamps to the current time.
-func createUser(ctx context.Context, tx *Tx, user *lil.User) error {
+func createUser(ctx context.Context, tx *Tx, user *lil.User) (err error) {
 	// Set timestamps to the current time.
 	user.CreatedAt = tx.now
 	user.UpdatedAt = user.CreatedAt
@@ -217,29 +266,33 @@ func createUser(ctx context.Context, tx *Tx, user *lil.User) error {
 	var email *string
 	if user.Email != "" {
 		email = &user.Email
+	} else {
+		user.EmailVerified = false
 	}
 
-	// Generate random API key.
-	apiKey := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, apiKey); err != nil {
+	// Generate random API key. Only its hash is stored.
+	if user.APIKey, err = generateAPIKey(); err != nil {
 		return err
 	}
-	user.APIKey = hex.EncodeToString(apiKey)
 
 	// Execute insertion query.
 	result, err := tx.ExecContext(ctx, `
 		INSERT INTO users (
 			name,
 			email,
-			api_key,
+			email_verified,
+			api_key_hash,
+			admin,
 			created_at,
 			updated_at
 		)
-		VALUES (?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`,
 		user.Name,
 		email,
-		user.APIKey,
+		user.EmailVerified,
+		hashSecret(user.APIKey),
+		user.Admin,
 		(*NullTime)(&user.CreatedAt),
 		(*NullTime)(&user.UpdatedAt),
 	)
@@ -263,7 +316,7 @@ func updateUser(ctx context.Context, tx *Tx, id int, upd lil.UserUpdate) (*lil.U
 	user, err := findUserByID(ctx, tx, id)
 	if err != nil {
 		return user, err
-	} else if user.ID != lil.UserIDFromContext(ctx) {
+	} else if user.ID != lil.UserIDFromContext(ctx) && !lil.AdminFromContext(ctx) {
 		return nil, lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to update this user.")
 	}
 
@@ -271,8 +324,30 @@ func updateUser(ctx context.Context, tx *Tx, id int, upd lil.UserUpdate) (*lil.U
 	if v := upd.Name; v != nil {
 		user.Name = *v
 	}
-	if v := upd.Email; v != nil {
-		user.Email = *v
+	if v := upd.Email; v != nil && *v != user.Email {
+		user.Email, user.EmailVerified = *v, false
+	}
+
+	// Only administrators can suspend users or reinstate them.
+	action := lil.AuditUserUpdate
+	if v := upd.Suspended; v != nil {
+		if !lil.AdminFromContext(ctx) {
+			return nil, lil.Errorf(lil.EUNAUTHORIZED, "Only administrators can suspend a user.")
+		} else if *v {
+			suspendedAt := tx.now
+			user.SuspendedAt, action = &suspendedAt, lil.AuditUserSuspend
+		} else {
+			user.SuspendedAt, action = nil, lil.AuditUserUnsuspend
+		}
+	}
+
+	// Administrator rights are only changed by maintenance tools.
+	if v := upd.Admin; v != nil {
+		if !lil.AdminFromContext(ctx) {
+			return nil, lil.Errorf(lil.EUNAUTHORIZED, "Only administrators can grant administrator rights.")
+		} else if user.Admin, action = *v, lil.AuditUserGrantAdmin; !*v {
+			action = lil.AuditUserRevokeAdmin
+		}
 	}
 
 	// Set last updated date to current time.
@@ -281,6 +356,8 @@ func updateUser(ctx context.Context, tx *Tx, id int, upd lil.UserUpdate) (*lil.U
 	// Perform basic field validation.
 	if err := user.Validate(); err != nil {
 		return user, err
+	} else if err := createAdminAuditEntry(ctx, tx, action, strconv.Itoa(user.ID)); err != nil {
+		return user, err
 	}
 
 	// Email is nullable and has a UNIQUE constraint so ensure we store blank
@@ -295,11 +372,17 @@ func updateUser(ctx context.Context, tx *Tx, id int, upd lil.UserUpdate) (*lil.U
 		UPDATE users
 		SET name = ?,
 		    email = ?,
+		    email_verified = ?,
+		    admin = ?,
+		    suspended_at = ?,
 		    updated_at = ?
 		WHERE id = ?
 	`,
 		user.Name,
 		email,
+		user.EmailVerified,
+		user.Admin,
+		(*NullTime)(user.SuspendedAt),
 		(*NullTime)(&user.UpdatedAt),
 		id,
 	); err != nil {
@@ -309,23 +392,120 @@ func updateUser(ctx context.Context, tx *Tx, id int, upd lil.UserUpdate) (*lil.U
 	return user, nil
 }
 
+// rotateAPIKey generates a new API key for a user. Returns EUNAUTHORIZED if
+// current user is not the user being updated.
+func rotateAPIKey(ctx context.Context, tx *Tx, id int) (*lil.User, error) {
+	// Fetch current object state.
+	user, err := findUserByID(ctx, tx, id)
+	if err != nil {
+		return user, err
+	} else if user.ID != lil.UserIDFromContext(ctx) && !lil.AdminFromContext(ctx) {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to update this user.")
+	} else if token := lil.TokenFromContext(ctx); token != nil && !token.HasAllScopes() {
+		return nil, lil.Errorf(lil.EUNAUTHORIZED, "Only API tokens with every scope can rotate the API key.")
+	}
+
+	if user.APIKey, err = generateAPIKey(); err != nil {
+		return user, err
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditUserRotateKey, strconv.Itoa(user.ID)); err != nil {
+		return user, err
+	}
+	user.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET api_key_hash = ?,
+		    updated_at = ?
+		WHERE id = ?
+	`,
+		hashSecret(user.APIKey),
+		(*NullTime)(&user.UpdatedAt),
+		id,
+	); err != nil {
+		return user, FormatError(err)
+	}
+
+	return user, nil
+}
+
+// generateAPIKey returns a new random API key.
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 // deleteUser permanently removes a user by ID. Returns EUNAUTHORIZED if current
 // user is not the one being deleted.
 func deleteUser(ctx context.Context, tx *Tx, id int) error {
 	// Verify object exists.
 	if user, err := findUserByID(ctx, tx, id); err != nil {
 		return err
-	} else if user.ID != lil.UserIDFromContext(ctx) {
+	} else if user.ID != lil.UserIDFromContext(ctx) && !lil.AdminFromContext(ctx) {
 		return lil.Errorf(lil.EUNAUTHORIZED, "You are not allowed to delete this user.")
+	} else if err := createAdminAuditEntry(ctx, tx, lil.AuditUserDelete, strconv.Itoa(id)); err != nil {
+		return err
+	} else if err := releaseUserTeams(ctx, tx, id); err != nil {
+		return err
 	}
 
-	// Remove row from database.
+	// Remove row from database. Remaining shorts are deleted along with it.
 	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id); err != nil {
 		return FormatError(err)
 	}
 	return nil
 }
 
+// releaseUserTeams prepares the teams of a user for the deletion of the user.
+// Team shorts created by the user are handed over to another owner of their
+// team and teams the user is the only member of are deleted. Returns EINVALID
+// if the user is the last owner of a team with other members.
+func releaseUserTeams(ctx context.Context, tx *Tx, id int) error {
+	var name string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT t.name
+		FROM team_members m
+		JOIN teams t ON t.id = m.team_id
+		WHERE m.user_id = ? AND m.role = ?
+		AND NOT EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = m.team_id AND o.user_id <> m.user_id AND o.role = ?)
+		AND EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = m.team_id AND o.user_id <> m.user_id)
+		ORDER BY t.id
+		LIMIT 1
+	`, id, lil.TeamRoleOwner, lil.TeamRoleOwner).Scan(&name); err == nil {
+		return lil.Errorf(lil.EINVALID, "Make another member owner of the %q team before deleting this user.", name)
+	} else if err != sql.ErrNoRows {
+		return FormatError(err)
+	}
+
+	// Hand team shorts over to the oldest remaining owner of their team.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE shorts
+		SET owner_id = (
+			SELECT o.user_id FROM team_members o
+			WHERE o.team_id = shorts.team_id AND o.user_id <> ? AND o.role = ?
+			ORDER BY o.created_at, o.user_id
+			LIMIT 1
+		),
+		    updated_at = ?
+		WHERE owner_id = ?
+		AND EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = shorts.team_id AND o.user_id <> ? AND o.role = ?)
+	`, id, lil.TeamRoleOwner, (*NullTime)(&tx.now), id, id, lil.TeamRoleOwner); err != nil {
+		return FormatError(err)
+	}
+
+	// Nobody else can reach the teams the user is the only member of.
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM teams
+		WHERE id IN (SELECT team_id FROM team_members WHERE user_id = ?)
+		AND NOT EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = teams.id AND o.user_id <> ?)
+	`, id, id); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
 // attachUserAuths attaches OAuth objects associated with the user.
 func attachUserAuths(ctx context.Context, tx *Tx, user *lil.User) (err error) {
 	if user.Auths, _, err = findAuths(ctx, tx, lil.AuthFilter{UserID: &user.ID}); err != nil {
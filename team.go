@@ -0,0 +1,144 @@
+package lil
+
+import (
+	"context"
+	"time"
+)
+
+// Team membership roles. Owners manage the team & its members, editors can
+// create & edit the team's shorts and viewers can only list them.
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleEditor = "editor"
+	TeamRoleViewer = "viewer"
+)
+
+// Team represents a group of users sharing the ownership of shorts.
+type Team struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+
+	// Members of the team along with their role.
+	Members []*TeamMember `json:"members"`
+
+	// Timestamps for team creation & last update.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate returns an error if the team contains invalid fields.
+// This only performs basic validation.
+func (t *Team) Validate() error {
+	if t.Name == "" {
+		return Errorf(EINVALID, "Team name required.")
+	}
+	return nil
+}
+
+// MemberRole returns the role of the user in the team. Returns an empty
+// string if the user is not a member.
+func (t *Team) MemberRole(userID int) string {
+	for _, m := range t.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// TeamMember represents the membership of a user in a team.
+type TeamMember struct {
+	TeamID int   `json:"teamID"`
+	UserID int   `json:"userID"`
+	User   *User `json:"user,omitempty"`
+
+	// Either TeamRoleOwner, TeamRoleEditor or TeamRoleViewer.
+	Role string `json:"role"`
+
+	// Timestamps for membership creation & last update.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate returns an error if the membership contains invalid fields.
+func (m *TeamMember) Validate() error {
+	if m.TeamID == 0 {
+		return Errorf(EINVALID, "Team required.")
+	} else if m.UserID == 0 {
+		return Errorf(EINVALID, "User required.")
+	} else if !IsValidTeamRole(m.Role) {
+		return Errorf(EINVALID, "Invalid team role.")
+	}
+	return nil
+}
+
+// IsValidTeamRole returns true if role is one of the team roles.
+func IsValidTeamRole(role string) bool {
+	switch role {
+	case TeamRoleOwner, TeamRoleEditor, TeamRoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanEditTeam returns true if the current user owns the team.
+func CanEditTeam(ctx context.Context, team *Team) bool {
+	return team.MemberRole(UserIDFromContext(ctx)) == TeamRoleOwner || AdminFromContext(ctx)
+}
+
+// CanEditTeamShorts returns true if the user can create & edit the shorts
+// of the team.
+func CanEditTeamShorts(userID int, team *Team) bool {
+	switch team.MemberRole(userID) {
+	case TeamRoleOwner, TeamRoleEditor:
+		return true
+	}
+	return false
+}
+
+// TeamService represents a service for managing teams & their members.
+type TeamService interface {
+	// Retrieves a single team by ID along with its members. Returns
+	// ENOTFOUND if the team does not exist or the current user is not a
+	// member.
+	FindTeamByID(ctx context.Context, id int) (*Team, error)
+
+	// Retrieves the teams of the current user. Also returns the total count
+	// of matching teams which may differ from returned results if
+	// filter.Limit is specified.
+	FindTeams(ctx context.Context, filter TeamFilter) ([]*Team, int, error)
+
+	// Creates a new team. The current user becomes its owner.
+	CreateTeam(ctx context.Context, team *Team) error
+
+	// Updates a team. Returns EUNAUTHORIZED if the current user does not
+	// own the team.
+	UpdateTeam(ctx context.Context, id int, upd TeamUpdate) (*Team, error)
+
+	// Permanently deletes a team. Its shorts are kept by their owners.
+	// Returns EUNAUTHORIZED if the current user does not own the team.
+	DeleteTeam(ctx context.Context, id int) error
+
+	// Adds a user to a team or changes the role of an existing member.
+	// Returns EUNAUTHORIZED if the current user does not own the team.
+	SetTeamMember(ctx context.Context, teamID, userID int, role string) (*TeamMember, error)
+
+	// Removes a user from a team. Members can remove themselves, otherwise
+	// returns EUNAUTHORIZED if the current user does not own the team.
+	RemoveTeamMember(ctx context.Context, teamID, userID int) error
+}
+
+// TeamFilter represents a filter passed to FindTeams().
+type TeamFilter struct {
+	ID   *int    `json:"id"`
+	Name *string `json:"name"`
+
+	// Restrict to subset of results.
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// TeamUpdate represents a set of fields to be updated via UpdateTeam().
+type TeamUpdate struct {
+	Name *string `json:"name"`
+}
@@ -0,0 +1,157 @@
+package lil
+
+import (
+	"context"
+	"time"
+)
+
+// TokenPrefix starts the secret of every API token. It tells tokens apart
+// from the legacy API key of the user.
+const TokenPrefix = "lil_"
+
+// Token scopes. A token can only be used on the routes covered by its scopes.
+const (
+	ScopeShortsRead  = "shorts:read"
+	ScopeShortsWrite = "shorts:write"
+	ScopeTeamsRead   = "teams:read"
+	ScopeTeamsWrite  = "teams:write"
+	ScopeUserRead    = "user:read"
+	ScopeUserWrite   = "user:write"
+	ScopeAdmin       = "admin"
+)
+
+// Scopes lists every token scope.
+var Scopes = []string{
+	ScopeShortsRead,
+	ScopeShortsWrite,
+	ScopeTeamsRead,
+	ScopeTeamsWrite,
+	ScopeUserRead,
+	ScopeUserWrite,
+	ScopeAdmin,
+}
+
+// Token represents a named API token. A user can hold several tokens, each
+// restricted to a set of scopes.
+type Token struct {
+	ID int `json:"id"`
+
+	// User the token acts on behalf of.
+	UserID int   `json:"userID"`
+	User   *User `json:"-"`
+
+	// Name given by the user to recognize the token.
+	Name string `json:"name"`
+
+	// Scopes granted to the token.
+	Scopes []string `json:"scopes"`
+
+	// Secret used as a bearer token. Only a hash is stored so the secret is
+	// only set when the token is created.
+	Secret string `json:"secret,omitempty"`
+
+	// Optional expiration of the token.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// Timestamps for token creation & last update.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate returns an error if the token contains invalid fields.
+// This only performs basic validation.
+func (t *Token) Validate() error {
+	if t.UserID == 0 {
+		return Errorf(EINVALID, "User required.")
+	} else if t.Name == "" {
+		return Errorf(EINVALID, "Token name required.")
+	} else if len(t.Scopes) == 0 {
+		return Errorf(EINVALID, "At least one scope is required.")
+	}
+
+	for _, scope := range t.Scopes {
+		if !IsValidScope(scope) {
+			return Errorf(EINVALID, "Invalid scope: %q.", scope)
+		}
+	}
+	return nil
+}
+
+// HasScope returns true if the token has been granted the scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllScopes returns true if the token has been granted every scope, as
+// the legacy API key is.
+func (t *Token) HasAllScopes() bool {
+	for _, scope := range Scopes {
+		if !t.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsExpired returns true if the token cannot be used anymore at the given time.
+func (t *Token) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// IsValidScope returns true if scope is one of the token scopes.
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseScope returns true if the current request is allowed to act within
+// the scope. Requests not authenticated by a token are not restricted.
+func CanUseScope(ctx context.Context, scope string) bool {
+	if token := TokenFromContext(ctx); token != nil {
+		return token.HasScope(scope)
+	}
+	return true
+}
+
+// TokenService represents a service for managing API tokens.
+type TokenService interface {
+	// Retrieves a single token by ID. Returns ENOTFOUND if the token does
+	// not exist or does not belong to the current user.
+	FindTokenByID(ctx context.Context, id int) (*Token, error)
+
+	// Retrieves the tokens of the current user. Administrators can list the
+	// tokens of any user. Secrets are never returned.
+	FindTokens(ctx context.Context, filter TokenFilter) ([]*Token, int, error)
+
+	// Retrieves the token matching a secret along with its user. Returns
+	// EUNAUTHORIZED if the secret is unknown or the token has expired.
+	FindTokenBySecret(ctx context.Context, secret string) (*Token, error)
+
+	// Creates a new token for the current user and sets token.Secret.
+	// Returns EUNAUTHORIZED if the request is authenticated by a token which
+	// lacks one of the requested scopes.
+	CreateToken(ctx context.Context, token *Token) error
+
+	// Permanently revokes a token. Returns ENOTFOUND if the token does not
+	// exist or does not belong to the current user.
+	RevokeToken(ctx context.Context, id int) error
+}
+
+// TokenFilter represents a filter passed to FindTokens().
+type TokenFilter struct {
+	ID     *int `json:"id"`
+	UserID *int `json:"userID"`
+
+	// Restrict to subset of results.
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
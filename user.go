@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// ErrUserSuspended is returned when a suspended user tries to log in.
+var ErrUserSuspended = Errorf(EUNAUTHORIZED, "Your account has been suspended.")
+
 // User represents a user in the system. Users are typically
 // created via OAuth using the AuthService but users can
 // also be create directly for testing.
@@ -14,10 +17,24 @@ type User struct {
 	// User's preferred name & email.
 	Name string `json:"name"`
 	Email string `json:"email"`
+
+	// Set if a login provider confirmed the email. Logins of other providers
+	// are only linked to users by verified emails. Changing the email clears
+	// it.
+	EmailVerified bool `json:"emailVerified"`
 	
-	// Randomly generated API key for use with the CLI.
+	// Randomly generated API key for use with the CLI. Only a hash is stored
+	// so the key is only set when the user is created or the key rotated.
 	APIKey string `json:"-"`
 
+	// Set if the user is a site administrator. Administrators are granted
+	// with "lild user grant-admin" and cannot be promoted through the API.
+	Admin bool `json:"admin"`
+
+	// Set when an administrator suspends the user. Suspended users
+	// cannot log in nor use their API key.
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
+
 	// Timestamps for user creation & last update.
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -35,6 +52,11 @@ func (u *User) Validate() error {
 	return nil
 }
 
+// IsSuspended returns true if the user has been suspended.
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
 // AvatarURL returns a URL to the avatar image for the user.
 // This loops over all auth providers to find the first 
 // available avatar.
@@ -63,10 +85,17 @@ type UserService interface {
 	
 	// Updates a user object. Returns EUNAUTHORIZED if current user is not
 	// the user that is being updated. Returns ENOTFOUND id user does not
-	// exist.
+	// exist. Only administrators can suspend users.
 	UpdateUser(ctx context.Context, id int, upd UserUpdate) (*User, error)
 	
-	// Permanently deletes a user and all owned dials. Returns EUNAUTHORIZED
+	// Replaces the API key of a user with a new random key. Returns
+	// EUNAUTHORIZED if current user is not the user being updated or if the
+	// request is authenticated by an API token lacking a scope.
+	RotateAPIKey(ctx context.Context, id int) (*User, error)
+	
+	// Permanently deletes a user and all owned shorts. Team shorts are handed
+	// over to another owner of the team instead. Returns EINVALID if the user
+	// is the last owner of a team with other members. Returns EUNAUTHORIZED
 	// if current user is not the user being deleted. Returns ENOTFOUND if
 	// user does not exist.
 	DeleteUser(ctx context.Context, id int) error
@@ -88,4 +117,10 @@ type UserFilter struct {
 type UserUpdate struct {
 	Name *string `json:"name"`
 	Email *string `json:"email"`
+
+	// Suspends or reinstates the user. Administrators only.
+	Suspended *bool `json:"suspended"`
+
+	// Grants or revokes administrator rights. Only set by maintenance tools.
+	Admin *bool `json:"-"`
 }